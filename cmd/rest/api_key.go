@@ -0,0 +1,93 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/pabloeclair/rest-subscription/internal/sbscrb/config"
+	"github.com/pabloeclair/rest-subscription/internal/sbscrb/models"
+	"github.com/pabloeclair/rest-subscription/internal/sbscrb/repositories"
+	"github.com/pabloeclair/rest-subscription/internal/sbscrb/rest"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+const apiKeyUsage = `usage:
+  rest-subscribe api-key create -name <name> -scopes <scope,...>
+  rest-subscribe api-key list
+  rest-subscribe api-key revoke -id <id>`
+
+// apiKeyCommand manages the API keys from the command line, e.g. to create
+// the first admin key.
+func apiKeyCommand(args []string) error {
+	if len(args) == 0 {
+		return errors.New(apiKeyUsage)
+	}
+	cfg, err := config.Load(nil)
+	if err != nil {
+		return err
+	}
+	if err := cfg.Postgres.Validate(); err != nil {
+		return err
+	}
+	db, err := gorm.Open(postgres.Open(cfg.Postgres.DSN()), &gorm.Config{})
+	if err != nil {
+		return err
+	}
+	if err := db.AutoMigrate(&models.APIKey{}); err != nil {
+		return err
+	}
+	repo := &repositories.GormAPIKeyRepository{Db: db}
+
+	fs := flag.NewFlagSet("api-key "+args[0], flag.ContinueOnError)
+	switch args[0] {
+	case "create":
+		name := fs.String("name", "", "the name of the key")
+		scopes := fs.String("scopes", "", "the comma-separated scopes: "+strings.Join(models.APIKeyScopes, ", "))
+		if err := fs.Parse(args[1:]); err != nil {
+			return err
+		}
+		key, err := rest.CreateAPIKey(repo, *name, strings.Split(*scopes, ","))
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Created the API key %d '%s' with the scopes %s\n", key.ID, key.Name, strings.Join(key.Scopes, ","))
+		fmt.Println("The key is shown only once, keep it safe:")
+		fmt.Println(key.Key)
+	case "list":
+		keys, err := repo.FindAll()
+		if err != nil {
+			return err
+		}
+		tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "ID\tNAME\tPREFIX\tSCOPES\tLAST USED\tREVOKED")
+		for _, k := range keys {
+			fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%s\t%s\n", k.ID, k.Name, k.Prefix, k.Scopes, formatTime(k.LastUsedAt), formatTime(k.RevokedAt))
+		}
+		return tw.Flush()
+	case "revoke":
+		id := fs.Uint("id", 0, "the id of the key")
+		if err := fs.Parse(args[1:]); err != nil {
+			return err
+		}
+		if err := repo.Revoke(*id, time.Now()); err != nil {
+			return fmt.Errorf("failed to revoke the API key %d: %w", *id, err)
+		}
+		fmt.Printf("Revoked the API key %d\n", *id)
+	default:
+		return errors.New(apiKeyUsage)
+	}
+	return nil
+}
+
+func formatTime(t *time.Time) string {
+	if t == nil {
+		return "-"
+	}
+	return t.Format(time.RFC3339)
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/pabloeclair/rest-subscription/internal/sbscrb/config"
+	"github.com/pabloeclair/rest-subscription/internal/sbscrb/rest"
+)
+
+// checkConfigCommand prints the effective configuration with the secrets
+// redacted and fails if it is not valid. It takes the same flags as the
+// server.
+func checkConfigCommand(args []string) error {
+	cfg, loadErr := config.Load(args)
+	if loadErr != nil && cfg == nil {
+		return loadErr
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	for _, setting := range cfg.Redacted() {
+		fmt.Fprintf(tw, "%s\t%s\n", setting[0], setting[1])
+	}
+	tw.Flush()
+
+	if err := errors.Join(loadErr, cfg.Validate()); err != nil {
+		return err
+	}
+	// the JWKS and policy files are checked by applying the configuration
+	if err := rest.Configure(cfg); err != nil {
+		return err
+	}
+	fmt.Println("The configuration is valid")
+	return nil
+}
@@ -4,21 +4,58 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"maps"
 	"net/http"
+	"os"
 	"os/signal"
+	"slices"
 	"syscall"
 	"time"
 
 	"github.com/fatih/color"
 	_ "github.com/joho/godotenv/autoload"
+	"github.com/pabloeclair/rest-subscription/internal/sbscrb/cache"
+	"github.com/pabloeclair/rest-subscription/internal/sbscrb/certs"
+	"github.com/pabloeclair/rest-subscription/internal/sbscrb/config"
+	"github.com/pabloeclair/rest-subscription/internal/sbscrb/events"
+	"github.com/pabloeclair/rest-subscription/internal/sbscrb/lifecycle"
 	"github.com/pabloeclair/rest-subscription/internal/sbscrb/models"
+	"github.com/pabloeclair/rest-subscription/internal/sbscrb/outbox"
+	"github.com/pabloeclair/rest-subscription/internal/sbscrb/ratelimit"
+	"github.com/pabloeclair/rest-subscription/internal/sbscrb/repositories"
 	"github.com/pabloeclair/rest-subscription/internal/sbscrb/rest"
+	"github.com/pabloeclair/rest-subscription/internal/sbscrb/scheduler"
+	"github.com/pabloeclair/rest-subscription/internal/sbscrb/webhooks"
+	"github.com/pabloeclair/rest-subscription/internal/sbscrb/workers"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 )
 
 func main() {
-	shutdownDuration, postgresTimeout, serverAddrs, err := rest.Init()
+	if len(os.Args) > 1 {
+		var command func(args []string) error
+		switch os.Args[1] {
+		case "api-key":
+			command = apiKeyCommand
+		case "check-config":
+			command = checkConfigCommand
+		}
+		if command != nil {
+			if err := command(os.Args[2:]); err != nil {
+				color.Red(err.Error())
+				os.Exit(1)
+			}
+			return
+		}
+	}
+
+	cfg, err := config.Load(os.Args[1:])
+	if err == nil {
+		err = cfg.Validate()
+	}
+	if err == nil {
+		err = rest.Configure(cfg)
+	}
 	if err != nil {
 		color.Red(err.Error())
 		return
@@ -29,30 +66,196 @@ func main() {
 	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, syscall.SIGINT)
 	defer stop()
 
+	// the shutdown phases run in the reverse order of the registration, the
+	// early returns stop what is started
+	lc := lifecycle.New(time.Duration(cfg.Server.ShutdownDuration), time.Duration(cfg.Server.ShutdownDrain))
+	defer lc.Shutdown()
+
 	// connecton to db
-	<-time.After(postgresTimeout)
-	db, err := gorm.Open(postgres.Open(rest.DSN), &gorm.Config{})
+	<-time.After(time.Duration(cfg.Postgres.Timeout))
+	db, err := gorm.Open(postgres.Open(cfg.Postgres.DSN()), &gorm.Config{})
 	if err != nil {
+		color.Red("ERROR: database: " + err.Error())
 		return
 	}
-
-	db.AutoMigrate(&models.SubscribeDto{})
-	defer func() {
+	rest.DB = db
+	lc.OnShutdown("database", func(ctx context.Context) error {
 		sqlDB, err := db.DB()
 		if err != nil {
+			return err
+		}
+		return sqlDB.Close()
+	})
+
+	db.AutoMigrate(
+		&models.Subscribe{},
+		&models.ScheduledJob{},
+		&models.Webhook{},
+		&models.WebhookDelivery{},
+		&models.OutboxEvent{},
+		&models.OutboxCursor{},
+		&models.OutboxDeadLetter{},
+		&models.Service{},
+		&models.ServiceAlias{},
+		&models.APIKey{},
+		&models.IdempotencyKey{},
+	)
+	// the constraint only backs the 'reject' policy
+	if err := repositories.EnsureOverlapConstraint(db, rest.OverlapPolicy == rest.OverlapReject); err != nil {
+		color.Yellow("WARN: failed to set up the constraint against overlapping subscribes: " + err.Error())
+	}
+	mapped, err := (&repositories.GormServiceRepository{Db: db}).MapSubscribes()
+	if err != nil {
+		color.Red("ERROR: services: " + err.Error())
+		return
+	}
+	if mapped > 0 {
+		log.Printf("Linked %d subscribes to the service catalogue", mapped)
+	}
+
+	switch cfg.Subscribes.Cache.Store {
+	case "memory":
+		rest.SubscribeCache = cache.NewMemoryStore(cfg.Subscribes.Cache.Size)
+	case "redis":
+		redisStore, err := cache.NewRedisStore(cfg.Subscribes.Cache.RedisURL)
+		if err != nil {
+			color.Red("ERROR: subscribe cache: " + err.Error())
 			return
 		}
-		sqlDB.Close()
-	}()
+		lc.OnShutdown("subscribe cache", func(ctx context.Context) error {
+			return redisStore.Close()
+		})
+		rest.SubscribeCache = redisStore
+	}
+
+	// starting jobs
+	// the expiration goes through the cache to drop the expired subscribes from it
+	expirationWorker := workers.NewExpirationWorker(rest.NewSubscribeRepository(db))
+
+	sched := scheduler.New(&scheduler.PgLocker{Db: db})
+	if err := sched.Register(
+		"expire-subscribes",
+		fmt.Sprintf("@every %s", rest.ExpirationInterval),
+		expirationWorker.Job,
+	); err != nil {
+		color.Red(err.Error())
+		return
+	}
+
+	webhookRepo := &repositories.GormWebhookRepository{Db: db}
+	deliveryRepo := &repositories.GormWebhookDeliveryRepository{Db: db}
+	dispatcher := &webhooks.Dispatcher{Webhooks: webhookRepo, Deliveries: deliveryRepo}
+	events.Listen(dispatcher.Dispatch)
+	deliverer := webhooks.NewDeliverer(webhookRepo, deliveryRepo)
+	if err := sched.Register("deliver-webhooks", "@every 5s", deliverer.Job); err != nil {
+		color.Red(err.Error())
+		return
+	}
+
+	// the 'webhooks' publisher passes the events to the dispatcher
+	publishers := map[string]outbox.Publisher{"webhooks": outbox.EmitPublisher{}}
+	for _, name := range rest.OutboxPublishers {
+		switch name {
+		case "log":
+			publishers[name] = outbox.LogPublisher{}
+		case "http":
+			publishers[name] = outbox.NewHTTPPublisher(rest.OutboxHTTPURL)
+		case "nats":
+			natsPublisher, err := outbox.NewNATSPublisher(rest.OutboxNATSURL, rest.OutboxNATSSubjectPrefix)
+			if err != nil {
+				color.Red("ERROR: outbox: " + err.Error())
+				return
+			}
+			lc.OnShutdown("nats publisher", func(ctx context.Context) error {
+				return natsPublisher.Close()
+			})
+			publishers[name] = natsPublisher
+		}
+	}
+	outboxRepo := &repositories.GormOutboxRepository{Db: db}
+	publisherNames := slices.Sorted(maps.Keys(publishers))
+	if err := outboxRepo.CreateCursors(publisherNames); err != nil {
+		color.Red("ERROR: outbox: " + err.Error())
+		return
+	}
+	for name, publisher := range publishers {
+		relay := outbox.NewRelay(outboxRepo, name, publisher)
+		if err := sched.Register("relay-outbox-"+name, "@every 1s", relay.Job); err != nil {
+			color.Red(err.Error())
+			return
+		}
+	}
+
+	if err := sched.Register("cleanup-outbox", "@hourly", func(ctx context.Context) error {
+		_, err := outboxRepo.Cleanup(publisherNames, time.Now().Add(-rest.OutboxRetention))
+		return err
+	}); err != nil {
+		color.Red(err.Error())
+		return
+	}
+
+	idempotencyRepo := &repositories.GormIdempotencyRepository{Db: db}
+	if err := sched.Register("cleanup-idempotency-keys", "@hourly", func(ctx context.Context) error {
+		_, err := idempotencyRepo.Cleanup(time.Now().Add(-rest.IdempotencyTTL))
+		return err
+	}); err != nil {
+		color.Red(err.Error())
+		return
+	}
+
+	if rest.RateLimitStore == "postgres" {
+		db.AutoMigrate(&models.RateLimitBucket{})
+		pgStore := &ratelimit.PgStore{Db: db}
+		rest.Limiter.Store = pgStore
+		if err := sched.Register("cleanup-rate-limits", "@hourly", func(ctx context.Context) error {
+			_, err := pgStore.Cleanup(time.Now().Add(-24 * time.Hour))
+			return err
+		}); err != nil {
+			color.Red(err.Error())
+			return
+		}
+	} else {
+		rest.Limiter.Store = ratelimit.NewMemoryStore()
+	}
+
+	lc.OnShutdown("workers", lc.StopWorkers)
+	lc.Go("scheduler", sched.Run)
 
 	// starting server
 	mux := http.NewServeMux()
 	mux.HandleFunc("POST /api/v1/subscribes", rest.Create)
+	mux.HandleFunc("POST /api/v1/subscribes/import", rest.Import)
+	mux.HandleFunc("GET /api/v1/subscribes/export", rest.Export)
 	mux.HandleFunc("GET /api/v1/subscribes/{id}", rest.GetById)
 	mux.HandleFunc("GET /api/v1/subscribe", rest.GetList)
 	mux.HandleFunc("PUT /api/v1/subscribes/{id}", rest.UpdatePut)
 	mux.HandleFunc("PATCH /api/v1/subscribes/{id}", rest.UpdatePatch)
 	mux.HandleFunc("DELETE /api/v1/subscribes/{id}", rest.Delete)
+	mux.HandleFunc("GET /api/v1/users/{user_id}", rest.GetUser)
+	mux.HandleFunc("GET /api/v1/users/{user_id}/subscribes", rest.GetUserSubscribes)
+	mux.HandleFunc("POST /api/v1/users/{user_id}/subscribes", rest.CreateUserSubscribe)
+	mux.HandleFunc("DELETE /api/v1/users/{user_id}/subscribes", rest.DeleteUserSubscribes)
+	mux.HandleFunc("GET /api/v1/users/{user_id}/spending", rest.GetUserSpending)
+	mux.HandleFunc("GET /api/v1/analytics/mrr", rest.GetAnalyticsMRR)
+	mux.HandleFunc("GET /api/v1/analytics/movements", rest.GetAnalyticsMovements)
+	mux.HandleFunc("GET /api/v1/analytics/retention", rest.GetAnalyticsRetention)
+	mux.HandleFunc("POST /api/v1/services", rest.CreateService)
+	mux.HandleFunc("GET /api/v1/services", rest.GetServices)
+	mux.HandleFunc("GET /api/v1/services/{id}", rest.GetServiceById)
+	mux.HandleFunc("PUT /api/v1/services/{id}", rest.UpdateService)
+	mux.HandleFunc("DELETE /api/v1/services/{id}", rest.DeleteService)
+	mux.HandleFunc("POST /api/v1/api-keys", rest.PostAPIKey)
+	mux.HandleFunc("GET /api/v1/api-keys", rest.GetAPIKeys)
+	mux.HandleFunc("DELETE /api/v1/api-keys/{id}", rest.RevokeAPIKey)
+	mux.HandleFunc("POST /api/v1/webhooks", rest.CreateWebhook)
+	mux.HandleFunc("GET /api/v1/webhooks", rest.GetWebhooks)
+	mux.HandleFunc("GET /api/v1/webhooks/{id}", rest.GetWebhookById)
+	mux.HandleFunc("PUT /api/v1/webhooks/{id}", rest.UpdateWebhook)
+	mux.HandleFunc("DELETE /api/v1/webhooks/{id}", rest.DeleteWebhook)
+	mux.HandleFunc("GET /api/v1/webhooks/{id}/deliveries", rest.GetWebhookDeliveries)
+	mux.HandleFunc("GET /api/v1/admin/workers/expiration", rest.ExpirationStatus(expirationWorker))
+	mux.HandleFunc("GET /api/v1/admin/jobs", rest.JobsMetrics(sched))
+	mux.HandleFunc("GET /api/v1/admin/metrics", rest.GetMetrics)
 	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
 		errDto := models.NewFullExceptionDto(
 			http.StatusNotFound,
@@ -62,27 +265,90 @@ func main() {
 		errDto.Write(w)
 	})
 
+	// the middlewares from the innermost to the outermost
+	var handler http.Handler = mux
+	// the idempotency key is completed or released by the handler itself,
+	// so it is held until a timed out handler finishes
+	handler = rest.IdempotencyMiddleware(idempotencyRepo, handler)
+	handler = rest.TimeoutMiddleware(rest.HandlerTimeout, mux, handler)
+	handler = rest.CacheControlMiddleware(rest.CacheControl, mux, handler)
+	handler = rest.BodyLimitMiddleware(rest.BodyLimit, mux, handler)
+	handler = rest.RBACMiddleware(rest.Policy, mux, handler)
+	handler = rest.RateLimitMiddleware(rest.Limiter, mux, handler)
+	handler = rest.AuthMiddleware(rest.Verifier, &repositories.GormAPIKeyRepository{Db: db}, handler)
+	// the bad credentials are limited before the API keys are looked up
+	handler = rest.IPRateLimitMiddleware(rest.IPLimiter, handler)
+	// the preflights are answered without the authentication
+	handler = rest.CORSMiddleware(rest.CORS, handler)
+	handler = rest.SecurityHeadersMiddleware(handler)
+	handler = rest.RecoveryMiddleware(handler)
+	handler = rest.LoggingMiddleware(handler)
+	// the logging reads the error responses before the compression
+	handler = rest.CompressionMiddleware(rest.Compress, handler)
+	handler = rest.RequestIDMiddleware(handler)
+
+	// the probes are answered without the authentication and the logs
+	probes := http.NewServeMux()
+	probes.HandleFunc("GET /healthz", rest.Healthz)
+	probes.HandleFunc("GET /readyz", rest.Readyz(lc))
+	probes.Handle("/", handler)
+
 	s := &http.Server{
-		Handler: rest.LoggingMiddleware(mux),
-		Addr:    serverAddrs,
+		Handler:           probes,
+		Addr:              cfg.Server.Addr,
+		ReadHeaderTimeout: time.Duration(cfg.Server.ReadHeaderTimeout),
+		ReadTimeout:       time.Duration(cfg.Server.ReadTimeout),
+		WriteTimeout:      time.Duration(cfg.Server.WriteTimeout),
+		IdleTimeout:       time.Duration(cfg.Server.IdleTimeout),
+	}
+
+	if cfg.Server.TLS.Enabled() {
+		reloader, err := certs.NewReloader(cfg.Server.TLS.CertFile, cfg.Server.TLS.KeyFile)
+		if err != nil {
+			color.Red("ERROR: tls: " + err.Error())
+			return
+		}
+		s.TLSConfig, err = certs.ServerConfig(reloader, cfg.Server.TLS.ClientCAFile, cfg.Server.TLS.ClientAuth)
+		if err != nil {
+			color.Red("ERROR: tls: " + err.Error())
+			return
+		}
+		lc.Go("certificate reload", func(ctx context.Context) {
+			reloader.Run(ctx, time.Duration(cfg.Server.TLS.ReloadInterval))
+		})
 	}
 
+	lc.OnShutdown("http server", s.Shutdown)
+	serveErr := make(chan error, 1)
 	go func() {
-		if err := s.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			log.Fatalln(models.RedString("ERROR: ", err.Error()))
+		var err error
+		if s.TLSConfig != nil {
+			// the certificate is served by the TLS config
+			err = s.ListenAndServeTLS("", "")
+		} else {
+			err = s.ListenAndServe()
+		}
+		if err != nil && err != http.ErrServerClosed {
+			serveErr <- err
 		}
 	}()
+	lc.SetReady(true)
 
 	// shutting down server
-	log.Printf("Server starting on %s", serverAddrs)
-	<-ctx.Done()
+	log.Printf("Server starting on %s", cfg.Server.Addr)
+	failed := false
+	select {
+	case <-ctx.Done():
+	case err := <-serveErr:
+		log.Println(models.RedString("ERROR: ", err.Error()))
+		failed = true
+	}
+	// the second signal kills the process
+	stop()
 
 	log.Println("Server shutting down...")
-	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownDuration)
-	defer cancel()
-
-	if err := s.Shutdown(shutdownCtx); err != nil {
-		log.Fatal(models.RedString("ERROR: shutdown: ", err.Error()))
+	if err := lc.Shutdown(); err != nil || failed {
+		os.Exit(1)
 	}
-
+	log.Println("Server stopped")
 }
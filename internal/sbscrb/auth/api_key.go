@@ -0,0 +1,28 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+)
+
+// APIKeyPrefix marks the API keys, so they are told apart from JWTs.
+const APIKeyPrefix = "sk_"
+
+// NewAPIKey generates a random API key and returns it with its hash.
+func NewAPIKey() (key string, hash string) {
+	b := make([]byte, 32)
+	rand.Read(b)
+	key = APIKeyPrefix + hex.EncodeToString(b)
+	return key, HashAPIKey(key)
+}
+
+func HashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+func IsAPIKey(token string) bool {
+	return strings.HasPrefix(token, APIKeyPrefix)
+}
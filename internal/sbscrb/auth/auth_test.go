@@ -0,0 +1,181 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var now = time.Date(2025, time.September, 1, 12, 0, 0, 0, time.UTC)
+
+func segment(t *testing.T, v any) string {
+	b, err := json.Marshal(v)
+	assert.NoError(t, err)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func sign(t *testing.T, alg, kid string, key any, claims map[string]any) string {
+	signed := segment(t, map[string]string{"alg": alg, "kid": kid, "typ": "JWT"}) + "." + segment(t, claims)
+	digest := sha256.Sum256([]byte(signed))
+
+	var signature []byte
+	switch alg {
+	case "HS256":
+		mac := hmac.New(sha256.New, key.([]byte))
+		mac.Write([]byte(signed))
+		signature = mac.Sum(nil)
+	case "RS256":
+		var err error
+		signature, err = rsa.SignPKCS1v15(rand.Reader, key.(*rsa.PrivateKey), crypto.SHA256, digest[:])
+		assert.NoError(t, err)
+	case "ES256":
+		r, s, err := ecdsa.Sign(rand.Reader, key.(*ecdsa.PrivateKey), digest[:])
+		assert.NoError(t, err)
+		signature = make([]byte, 64)
+		r.FillBytes(signature[:32])
+		s.FillBytes(signature[32:])
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func claims(sub string, roles ...string) map[string]any {
+	return map[string]any{"sub": sub, "exp": now.Add(time.Hour).Unix(), "roles": roles, "aud": "subscriptions"}
+}
+
+func TestVerifyHS256(t *testing.T) {
+	secret := []byte("secret")
+	v := &Verifier{Secret: secret, Audience: "subscriptions", Now: func() time.Time { return now }}
+
+	t.Run("Success", func(t *testing.T) {
+		c, err := v.Verify(sign(t, "HS256", "", secret, claims("user-1", "admin")))
+		assert.NoError(t, err)
+		assert.Equal(t, "user-1", c.Subject)
+		assert.Equal(t, []string{"admin"}, c.Roles)
+	})
+
+	t.Run("ErrInvalidToken", func(t *testing.T) {
+		_, err := v.Verify(sign(t, "HS256", "", []byte("other"), claims("user-1")))
+		assert.ErrorIs(t, err, ErrInvalidToken)
+	})
+
+	t.Run("ErrExpiredToken", func(t *testing.T) {
+		c := claims("user-1")
+		c["exp"] = now.Add(-time.Hour).Unix()
+		_, err := v.Verify(sign(t, "HS256", "", secret, c))
+		assert.ErrorIs(t, err, ErrExpiredToken)
+	})
+
+	t.Run("WrongAudience", func(t *testing.T) {
+		c := claims("user-1")
+		c["aud"] = []string{"billing"}
+		_, err := v.Verify(sign(t, "HS256", "", secret, c))
+		assert.ErrorIs(t, err, ErrInvalidToken)
+	})
+
+	t.Run("ErrMalformedToken", func(t *testing.T) {
+		_, err := v.Verify("not-a-token")
+		assert.ErrorIs(t, err, ErrMalformedToken)
+	})
+}
+
+func TestVerifyJWKS(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+
+	b64 := func(i *big.Int) string { return base64.RawURLEncoding.EncodeToString(i.Bytes()) }
+	jwks := map[string]any{"keys": []map[string]string{
+		{"kty": "RSA", "kid": "rsa-1", "n": b64(rsaKey.N), "e": b64(big.NewInt(int64(rsaKey.E)))},
+		{"kty": "EC", "kid": "ec-1", "crv": "P-256", "x": b64(ecKey.X), "y": b64(ecKey.Y)},
+	}}
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	b, err := json.Marshal(jwks)
+	assert.NoError(t, err)
+	assert.NoError(t, os.WriteFile(path, b, 0o600))
+
+	keys, err := LoadJWKS(path)
+	assert.NoError(t, err)
+	v := &Verifier{Keys: keys, Now: func() time.Time { return now }}
+
+	c, err := v.Verify(sign(t, "RS256", "rsa-1", rsaKey, claims("user-1")))
+	assert.NoError(t, err)
+	assert.Equal(t, "user-1", c.Subject)
+
+	c, err = v.Verify(sign(t, "ES256", "ec-1", ecKey, claims("user-2")))
+	assert.NoError(t, err)
+	assert.Equal(t, "user-2", c.Subject)
+
+	_, err = v.Verify(sign(t, "RS256", "unknown", rsaKey, claims("user-1")))
+	assert.ErrorIs(t, err, ErrUnknownKey)
+
+	// HS256 is rejected without a shared secret
+	_, err = v.Verify(sign(t, "HS256", "", []byte("secret"), claims("user-1")))
+	assert.ErrorIs(t, err, ErrUnknownKey)
+}
+
+func TestPolicyGrant(t *testing.T) {
+	policy := DefaultPolicy()
+	assert.NoError(t, policy.Validate())
+
+	support := &Principal{Subject: "user-1", Roles: []string{"support"}}
+	policy.Grant(support)
+	assert.True(t, support.Has(PermSubscribesRead+AnySuffix))
+	assert.False(t, support.Has(PermSubscribesDelete))
+
+	finance := &Principal{Subject: "user-2", Roles: []string{"finance"}}
+	policy.Grant(finance)
+	assert.True(t, finance.Has(PermAnalyticsRead))
+	assert.False(t, finance.Has(PermSubscribesRead))
+	assert.False(t, finance.Has(PermReportsRead+AnySuffix))
+
+	unknown := &Principal{Subject: "user-3", Roles: []string{"guest"}}
+	policy.Grant(unknown)
+	assert.Empty(t, unknown.Permissions)
+
+	// the scopes are not the roles of the users
+	claimed := &Principal{Subject: "user-4", Roles: []string{ScopeRole("subscribes:read"), "subscribes:read"}}
+	policy.Grant(claimed)
+	assert.Empty(t, claimed.Permissions)
+
+	service := &Principal{Subject: "api-key:1", Roles: ScopeRoles([]string{"subscribes:read"}), APIKey: true}
+	policy.Grant(service)
+	assert.True(t, service.Has(PermSubscribesRead+AnySuffix))
+}
+
+func TestLoadPolicy(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "policy.json")
+
+	t.Run("Merge", func(t *testing.T) {
+		assert.NoError(t, os.WriteFile(path, []byte(`{
+			"roles": {"support": ["subscribes:read:any", "subscribes:delete:any"]},
+			"routes": {"GET /api/v1/analytics/mrr": "reports:read:any"}
+		}`), 0o600))
+
+		policy, err := LoadPolicy(path)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"subscribes:read:any", "subscribes:delete:any"}, policy.Roles["support"])
+		assert.Equal(t, "reports:read:any", policy.Routes["GET /api/v1/analytics/mrr"])
+		assert.Equal(t, PermAnalyticsRead, policy.Routes["GET /api/v1/analytics/movements"])
+	})
+
+	t.Run("UnknownPermission", func(t *testing.T) {
+		assert.NoError(t, os.WriteFile(path, []byte(`{"roles": {"support": ["subscribes:purge"]}}`), 0o600))
+
+		_, err := LoadPolicy(path)
+		assert.ErrorContains(t, err, "subscribes:purge")
+	})
+}
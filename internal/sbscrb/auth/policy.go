@@ -0,0 +1,180 @@
+package auth
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+)
+
+// The permissions of the routes. A permission allows the access to the own
+// subscribes, the same permission with the ':any' suffix allows the access
+// to the subscribes of any user.
+const (
+	PermSubscribesRead   = "subscribes:read"
+	PermSubscribesWrite  = "subscribes:write"
+	PermSubscribesDelete = "subscribes:delete"
+	PermReportsRead      = "reports:read"
+	PermAnalyticsRead    = "analytics:read"
+	PermServicesRead     = "services:read"
+	PermServicesWrite    = "services:write"
+	PermAdmin            = "admin"
+
+	AnySuffix = ":any"
+)
+
+var Permissions = []string{
+	PermSubscribesRead, PermSubscribesRead + AnySuffix,
+	PermSubscribesWrite, PermSubscribesWrite + AnySuffix,
+	PermSubscribesDelete, PermSubscribesDelete + AnySuffix,
+	PermReportsRead, PermReportsRead + AnySuffix,
+	PermAnalyticsRead,
+	PermServicesRead,
+	PermServicesWrite,
+	PermAdmin,
+}
+
+// Policy is the role→permission matrix and the permissions required by the
+// route patterns of the mux.
+type Policy struct {
+	Roles  map[string][]string `json:"roles"`
+	Routes map[string]string   `json:"routes"`
+}
+
+func DefaultPolicy() *Policy {
+	return &Policy{
+		Roles: map[string][]string{
+			"admin": Permissions,
+			"support": {
+				PermSubscribesRead, PermSubscribesRead + AnySuffix,
+				PermReportsRead, PermReportsRead + AnySuffix,
+				PermServicesRead,
+			},
+			// the totals without the subscribes of the users, the reports of
+			// the other users are per user so finance reads only its own
+			"finance": {
+				PermReportsRead,
+				PermAnalyticsRead,
+				PermServicesRead,
+			},
+			"read-only": {
+				PermSubscribesRead,
+				PermReportsRead,
+				PermServicesRead,
+			},
+			RoleUser: {
+				PermSubscribesRead,
+				PermSubscribesWrite,
+				PermSubscribesDelete,
+				PermReportsRead,
+				PermServicesRead,
+			},
+			// the scopes of the services, see ScopeRole
+			ScopeRole("subscribes:read"): {
+				PermSubscribesRead, PermSubscribesRead + AnySuffix,
+				PermReportsRead, PermReportsRead + AnySuffix,
+				PermAnalyticsRead,
+				PermServicesRead,
+			},
+			ScopeRole("subscribes:write"): {
+				PermSubscribesWrite, PermSubscribesWrite + AnySuffix,
+				PermSubscribesDelete, PermSubscribesDelete + AnySuffix,
+				PermServicesRead,
+			},
+			ScopeRole("admin"): Permissions,
+		},
+		Routes: map[string]string{
+			"POST /api/v1/subscribes":                   PermSubscribesWrite,
+			"POST /api/v1/subscribes/import":            PermSubscribesWrite,
+			"GET /api/v1/subscribes/export":             PermSubscribesRead,
+			"GET /api/v1/subscribes/{id}":               PermSubscribesRead,
+			"GET /api/v1/subscribe":                     PermSubscribesRead,
+			"PUT /api/v1/subscribes/{id}":               PermSubscribesWrite,
+			"PATCH /api/v1/subscribes/{id}":             PermSubscribesWrite,
+			"DELETE /api/v1/subscribes/{id}":            PermSubscribesDelete,
+			"GET /api/v1/users/{user_id}":               PermReportsRead,
+			"GET /api/v1/users/{user_id}/subscribes":    PermSubscribesRead,
+			"POST /api/v1/users/{user_id}/subscribes":   PermSubscribesWrite,
+			"DELETE /api/v1/users/{user_id}/subscribes": PermSubscribesDelete,
+			"GET /api/v1/users/{user_id}/spending":      PermReportsRead,
+			"GET /api/v1/analytics/mrr":                 PermAnalyticsRead,
+			"GET /api/v1/analytics/movements":           PermAnalyticsRead,
+			"GET /api/v1/analytics/retention":           PermAnalyticsRead,
+			"POST /api/v1/services":                     PermServicesWrite,
+			"GET /api/v1/services":                      PermServicesRead,
+			"GET /api/v1/services/{id}":                 PermServicesRead,
+			"PUT /api/v1/services/{id}":                 PermServicesWrite,
+			"DELETE /api/v1/services/{id}":              PermServicesWrite,
+			"POST /api/v1/api-keys":                     PermAdmin,
+			"GET /api/v1/api-keys":                      PermAdmin,
+			"DELETE /api/v1/api-keys/{id}":              PermAdmin,
+			"POST /api/v1/webhooks":                     PermAdmin,
+			"GET /api/v1/webhooks":                      PermAdmin,
+			"GET /api/v1/webhooks/{id}":                 PermAdmin,
+			"PUT /api/v1/webhooks/{id}":                 PermAdmin,
+			"DELETE /api/v1/webhooks/{id}":              PermAdmin,
+			"GET /api/v1/webhooks/{id}/deliveries":      PermAdmin,
+			"GET /api/v1/admin/workers/expiration":      PermAdmin,
+			"GET /api/v1/admin/jobs":                    PermAdmin,
+			"GET /api/v1/admin/metrics":                 PermAdmin,
+		},
+	}
+}
+
+// LoadPolicy reads a JSON policy file over the default policy, the roles
+// and the routes of the file replace the default ones with the same name.
+func LoadPolicy(path string) (*Policy, error) {
+	policy := DefaultPolicy()
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var file Policy
+	if err := json.Unmarshal(b, &file); err != nil {
+		return nil, fmt.Errorf("policy: %w", err)
+	}
+	for role, permissions := range file.Roles {
+		policy.Roles[role] = permissions
+	}
+	for route, permission := range file.Routes {
+		policy.Routes[route] = permission
+	}
+	if err := policy.Validate(); err != nil {
+		return nil, err
+	}
+	return policy, nil
+}
+
+func (p *Policy) Validate() error {
+	for role, permissions := range p.Roles {
+		for _, perm := range permissions {
+			if !slices.Contains(Permissions, perm) {
+				return fmt.Errorf("policy: unknown permission '%s' of the role '%s'. The permissions can only be '%s'",
+					perm, role, strings.Join(Permissions, "', '"))
+			}
+		}
+	}
+	for route, perm := range p.Routes {
+		if !slices.Contains(Permissions, perm) {
+			return fmt.Errorf("policy: unknown permission '%s' of the route '%s'", perm, route)
+		}
+	}
+	return nil
+}
+
+// Grant sets the permissions of the principal by its roles. The scope
+// roles are granted only to the services, a JWT cannot claim them.
+func (p *Policy) Grant(principal *Principal) {
+	principal.Permissions = nil
+	for _, role := range principal.Roles {
+		if strings.HasPrefix(role, ScopeRolePrefix) && !principal.APIKey {
+			continue
+		}
+		for _, perm := range p.Roles[role] {
+			if !slices.Contains(principal.Permissions, perm) {
+				principal.Permissions = append(principal.Permissions, perm)
+			}
+		}
+	}
+}
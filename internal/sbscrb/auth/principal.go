@@ -0,0 +1,55 @@
+package auth
+
+import (
+	"context"
+	"slices"
+)
+
+const (
+	// RoleUser is the role of a JWT without the 'roles' claim.
+	RoleUser = "user"
+	// ScopeRolePrefix separates the roles of the service scopes from the
+	// roles of the users.
+	ScopeRolePrefix = "scope:"
+)
+
+// ScopeRole is the role of a scope of an API key or a client certificate.
+func ScopeRole(scope string) string {
+	return ScopeRolePrefix + scope
+}
+
+// ScopeRoles maps the scopes to their roles.
+func ScopeRoles(scopes []string) []string {
+	roles := make([]string, 0, len(scopes))
+	for _, scope := range scopes {
+		roles = append(roles, ScopeRole(scope))
+	}
+	return roles
+}
+
+// Principal is the authenticated caller of a request, either a user with
+// a JWT or a service with an API key or a client certificate. The scopes
+// of a service are used as its roles, see ScopeRole.
+type Principal struct {
+	Subject string
+	Roles   []string
+	// APIKey is set for the services, they do not own subscribes
+	APIKey bool
+	// Permissions are granted to the roles by the policy
+	Permissions []string
+}
+
+func (p *Principal) Has(permission string) bool {
+	return slices.Contains(p.Permissions, permission)
+}
+
+type principalKey struct{}
+
+func WithPrincipal(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+func FromContext(ctx context.Context) (*Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(*Principal)
+	return p, ok
+}
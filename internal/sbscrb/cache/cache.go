@@ -0,0 +1,39 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"sync/atomic"
+	"time"
+)
+
+// Store keeps the values by the keys until their TTL ends. A missing key
+// is not an error.
+type Store interface {
+	Get(ctx context.Context, key string) ([]byte, bool, error)
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	// Add sets the value only if the key is not set and reports whether it
+	// has been set
+	Add(ctx context.Context, key string, value []byte, ttl time.Duration) (bool, error)
+	Delete(ctx context.Context, keys ...string) error
+}
+
+// Metrics are the counters of a cache, the errors are the failures of the
+// store which were served from the source.
+type Metrics struct {
+	Hits   atomic.Int64
+	Misses atomic.Int64
+	Errors atomic.Int64
+}
+
+func (m *Metrics) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Hits   int64 `json:"hits"`
+		Misses int64 `json:"misses"`
+		Errors int64 `json:"errors"`
+	}{
+		Hits:   m.Hits.Load(),
+		Misses: m.Misses.Load(),
+		Errors: m.Errors.Load(),
+	})
+}
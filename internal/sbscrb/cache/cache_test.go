@@ -0,0 +1,149 @@
+package cache
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryStore(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2025, time.July, 26, 0, 0, 0, 0, time.UTC)
+	s := NewMemoryStore(2)
+	s.now = func() time.Time { return now }
+
+	t.Run("TTL", func(t *testing.T) {
+		assert.NoError(t, s.Set(ctx, "a", []byte("1"), time.Minute))
+		value, ok, err := s.Get(ctx, "a")
+		assert.NoError(t, err)
+		assert.True(t, ok)
+		assert.Equal(t, []byte("1"), value)
+
+		now = now.Add(time.Minute)
+		_, ok, _ = s.Get(ctx, "a")
+		assert.False(t, ok)
+		assert.Equal(t, 0, s.Len())
+	})
+
+	t.Run("LRU", func(t *testing.T) {
+		s.Set(ctx, "a", []byte("1"), time.Minute)
+		s.Set(ctx, "b", []byte("2"), time.Minute)
+		// 'a' becomes the recently used one
+		s.Get(ctx, "a")
+		s.Set(ctx, "c", []byte("3"), time.Minute)
+
+		_, ok, _ := s.Get(ctx, "b")
+		assert.False(t, ok)
+		_, ok, _ = s.Get(ctx, "a")
+		assert.True(t, ok)
+		_, ok, _ = s.Get(ctx, "c")
+		assert.True(t, ok)
+		assert.Equal(t, 2, s.Len())
+	})
+
+	t.Run("Add", func(t *testing.T) {
+		added, err := s.Add(ctx, "a", []byte("2"), time.Minute)
+		assert.NoError(t, err)
+		assert.False(t, added)
+		value, _, _ := s.Get(ctx, "a")
+		assert.Equal(t, []byte("1"), value)
+
+		// the expired value is replaced
+		s.Set(ctx, "d", []byte("1"), time.Second)
+		now = now.Add(time.Second)
+		added, err = s.Add(ctx, "d", []byte("2"), time.Minute)
+		assert.NoError(t, err)
+		assert.True(t, added)
+		value, _, _ = s.Get(ctx, "d")
+		assert.Equal(t, []byte("2"), value)
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		assert.NoError(t, s.Delete(ctx, "a", "missing"))
+		_, ok, _ := s.Get(ctx, "a")
+		assert.False(t, ok)
+	})
+}
+
+func TestRedisStore(t *testing.T) {
+	ctx := context.Background()
+	server := miniredis.RunT(t)
+	server.RequireAuth("secret")
+
+	s, err := NewRedisStore("redis://:secret@" + server.Addr() + "/1")
+	require.NoError(t, err)
+	defer s.Close()
+
+	_, ok, err := s.Get(ctx, "subscribe:1")
+	assert.NoError(t, err)
+	assert.False(t, ok)
+
+	assert.NoError(t, s.Set(ctx, "subscribe:1", []byte(`{"ID":1}`), time.Minute))
+	value, ok, err := s.Get(ctx, "subscribe:1")
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, []byte(`{"ID":1}`), value)
+	assert.Equal(t, time.Minute, server.DB(1).TTL("subscribe:1"))
+
+	added, err := s.Add(ctx, "subscribe:1", []byte(`{"ID":2}`), time.Minute)
+	assert.NoError(t, err)
+	assert.False(t, added)
+	added, err = s.Add(ctx, "subscribe:2", []byte(`{"ID":2}`), time.Minute)
+	assert.NoError(t, err)
+	assert.True(t, added)
+
+	assert.NoError(t, s.Delete(ctx, "subscribe:1", "subscribe:2"))
+	assert.False(t, server.DB(1).Exists("subscribe:1"))
+	assert.False(t, server.DB(1).Exists("subscribe:2"))
+}
+
+func TestRedisStorePool(t *testing.T) {
+	ctx := context.Background()
+	server := miniredis.RunT(t)
+
+	s, err := NewRedisStore("redis://" + server.Addr() + "?pool_size=3")
+	require.NoError(t, err)
+
+	var wg sync.WaitGroup
+	for i := range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			key := "subscribe:" + strconv.Itoa(i)
+			for range 10 {
+				assert.NoError(t, s.Set(ctx, key, []byte(key), time.Minute))
+				value, ok, err := s.Get(ctx, key)
+				assert.NoError(t, err)
+				assert.True(t, ok)
+				assert.Equal(t, []byte(key), value)
+			}
+		}()
+	}
+	wg.Wait()
+	assert.LessOrEqual(t, server.TotalConnectionCount(), 3)
+
+	assert.NoError(t, s.Close())
+	_, _, err = s.Get(ctx, "subscribe:1")
+	assert.ErrorContains(t, err, "closed")
+}
+
+func TestNewRedisStore(t *testing.T) {
+	_, err := NewRedisStore("http://cache.internal")
+	assert.Error(t, err)
+	_, err = NewRedisStore("redis://cache.internal/main")
+	assert.Error(t, err)
+
+	server := miniredis.RunT(t)
+	server.RequireAuth("secret")
+	s, err := NewRedisStore("redis://:wrong@" + server.Addr())
+	require.NoError(t, err)
+	defer s.Close()
+	_, _, err = s.Get(context.Background(), "subscribe:1")
+	assert.ErrorContains(t, err, "redis: ")
+}
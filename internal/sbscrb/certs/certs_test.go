@@ -0,0 +1,211 @@
+package certs
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"log"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testCert struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+}
+
+// newTestCert issues a certificate signed by the parent, or a self-signed
+// CA if the parent is nil.
+func newTestCert(t *testing.T, parent *testCert, commonName string) *testCert {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	serial, err := rand.Int(rand.Reader, big.NewInt(1<<62))
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: commonName, OrganizationalUnit: []string{"admin"}},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		DNSNames:     []string{"localhost"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	signer, signerKey := template, key
+	if parent == nil {
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+		template.KeyUsage |= x509.KeyUsageCertSign
+	} else {
+		signer, signerKey = parent.cert, parent.key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, signer, &key.PublicKey, signerKey)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return &testCert{
+		cert: cert,
+		key:  key,
+		pem:  pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+	}
+}
+
+func (c *testCert) keyPEM(t *testing.T) []byte {
+	der, err := x509.MarshalECPrivateKey(c.key)
+	require.NoError(t, err)
+	return pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der})
+}
+
+func (c *testCert) tlsCertificate(t *testing.T) tls.Certificate {
+	cert, err := tls.X509KeyPair(c.pem, c.keyPEM(t))
+	require.NoError(t, err)
+	return cert
+}
+
+// write saves the certificate and the key, the modification time is moved
+// forward to make the change visible on coarse file systems.
+func (c *testCert) write(t *testing.T, certFile, keyFile string, modTime time.Time) {
+	require.NoError(t, os.WriteFile(certFile, c.pem, 0o600))
+	require.NoError(t, os.WriteFile(keyFile, c.keyPEM(t), 0o600))
+	require.NoError(t, os.Chtimes(certFile, modTime, modTime))
+	require.NoError(t, os.Chtimes(keyFile, modTime, modTime))
+}
+
+func TestReloader(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+	ca := newTestCert(t, nil, "test-ca")
+	first := newTestCert(t, ca, "first")
+	first.write(t, certFile, keyFile, time.Now())
+
+	reloader, err := NewReloader(certFile, keyFile)
+	require.NoError(t, err)
+	cert, _ := reloader.GetCertificate(nil)
+	assert.Equal(t, first.cert.Raw, cert.Certificate[0])
+
+	t.Run("Unchanged", func(t *testing.T) {
+		reloaded, err := reloader.Reload()
+		assert.NoError(t, err)
+		assert.False(t, reloaded)
+	})
+
+	t.Run("Changed", func(t *testing.T) {
+		second := newTestCert(t, ca, "second")
+		second.write(t, certFile, keyFile, time.Now().Add(time.Minute))
+
+		reloaded, err := reloader.Reload()
+		assert.NoError(t, err)
+		assert.True(t, reloaded)
+		cert, _ := reloader.GetCertificate(nil)
+		assert.Equal(t, second.cert.Raw, cert.Certificate[0])
+	})
+
+	t.Run("InvalidKeepsCurrent", func(t *testing.T) {
+		current, _ := reloader.GetCertificate(nil)
+		require.NoError(t, os.WriteFile(keyFile, []byte("not a key"), 0o600))
+		later := time.Now().Add(2 * time.Minute)
+		require.NoError(t, os.Chtimes(keyFile, later, later))
+
+		_, err := reloader.Reload()
+		assert.Error(t, err)
+		cert, _ := reloader.GetCertificate(nil)
+		assert.Same(t, current, cert)
+	})
+}
+
+func TestServerConfigClientAuth(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+	caFile := filepath.Join(dir, "ca.crt")
+	ca := newTestCert(t, nil, "test-ca")
+	require.NoError(t, os.WriteFile(caFile, ca.pem, 0o600))
+	newTestCert(t, ca, "localhost").write(t, certFile, keyFile, time.Now())
+	client := newTestCert(t, ca, "billing")
+	stranger := newTestCert(t, newTestCert(t, nil, "other-ca"), "stranger")
+
+	reloader, err := NewReloader(certFile, keyFile)
+	require.NoError(t, err)
+	roots, err := LoadCertPool(caFile)
+	require.NoError(t, err)
+
+	get := func(t *testing.T, clientAuth string, clientCert *testCert) (string, error) {
+		tlsConfig, err := ServerConfig(reloader, caFile, clientAuth)
+		require.NoError(t, err)
+		// httptest.Server would replace the certificate of the reloader
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+		server := &http.Server{
+			Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if len(r.TLS.VerifiedChains) > 0 {
+					w.Write([]byte(r.TLS.VerifiedChains[0][0].Subject.CommonName))
+				}
+			}),
+			ErrorLog: log.New(io.Discard, "", 0),
+		}
+		go server.Serve(tls.NewListener(listener, tlsConfig))
+		defer server.Close()
+
+		clientConfig := &tls.Config{RootCAs: roots}
+		if clientCert != nil {
+			clientConfig.Certificates = []tls.Certificate{clientCert.tlsCertificate(t)}
+		}
+		httpClient := &http.Client{Transport: &http.Transport{TLSClientConfig: clientConfig}}
+		resp, err := httpClient.Get("https://" + listener.Addr().String())
+		if err != nil {
+			return "", err
+		}
+		defer resp.Body.Close()
+		b := make([]byte, 64)
+		n, _ := resp.Body.Read(b)
+		return string(b[:n]), nil
+	}
+
+	t.Run("None", func(t *testing.T) {
+		body, err := get(t, ClientAuthNone, nil)
+		assert.NoError(t, err)
+		assert.Equal(t, "", body)
+	})
+
+	t.Run("OptionalWithoutCert", func(t *testing.T) {
+		body, err := get(t, ClientAuthOptional, nil)
+		assert.NoError(t, err)
+		assert.Equal(t, "", body)
+	})
+
+	t.Run("RequireWithCert", func(t *testing.T) {
+		body, err := get(t, ClientAuthRequire, client)
+		assert.NoError(t, err)
+		assert.Equal(t, "billing", body)
+	})
+
+	t.Run("RequireWithoutCert", func(t *testing.T) {
+		_, err := get(t, ClientAuthRequire, nil)
+		assert.Error(t, err)
+	})
+
+	t.Run("UnknownCA", func(t *testing.T) {
+		_, err := get(t, ClientAuthRequire, stranger)
+		assert.Error(t, err)
+	})
+
+	t.Run("UnknownMode", func(t *testing.T) {
+		_, err := ServerConfig(reloader, caFile, "always")
+		assert.Error(t, err)
+	})
+}
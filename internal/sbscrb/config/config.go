@@ -0,0 +1,642 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"net/netip"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Config is the configuration of the service. It is loaded in the order
+// defaults → config file → environment variables → flags, each source
+// overriding the previous one.
+type Config struct {
+	Server      Server      `yaml:"server"`
+	Postgres    Postgres    `yaml:"postgres"`
+	Auth        Auth        `yaml:"auth"`
+	RateLimit   RateLimit   `yaml:"rate_limit"`
+	Outbox      Outbox      `yaml:"outbox"`
+	Subscribes  Subscribes  `yaml:"subscribes"`
+	Idempotency Idempotency `yaml:"idempotency"`
+	CORS        CORS        `yaml:"cors"`
+}
+
+type Server struct {
+	Addr             string   `yaml:"addr"`
+	ShutdownDuration Duration `yaml:"shutdown_duration"`
+	// ShutdownDrain is the time between failing the readiness and stopping
+	// the server, so the load balancers stop sending the requests
+	ShutdownDrain Duration `yaml:"shutdown_drain"`
+	// NotificationInternalError is appended to the messages of INTERNAL SERVER ERROR
+	NotificationInternalError string `yaml:"notification_internal_error"`
+	TLS                       TLS    `yaml:"tls"`
+
+	ReadHeaderTimeout Duration `yaml:"read_header_timeout"`
+	ReadTimeout       Duration `yaml:"read_timeout"`
+	WriteTimeout      Duration `yaml:"write_timeout"`
+	IdleTimeout       Duration `yaml:"idle_timeout"`
+	// HandlerTimeout bounds a request in the handler, 0 disables it.
+	// HandlerTimeoutRoutes override it by the route pattern
+	HandlerTimeout       Duration          `yaml:"handler_timeout"`
+	HandlerTimeoutRoutes map[string]string `yaml:"handler_timeout_routes"`
+	// BodyLimit is the largest request body, BodyLimitRoutes override it by
+	// the route pattern
+	BodyLimit       Size              `yaml:"body_limit"`
+	BodyLimitRoutes map[string]string `yaml:"body_limit_routes"`
+	// CompressionEncodings are the encodings of the responses in the order
+	// of the preference, the compression is disabled if empty
+	CompressionEncodings []string `yaml:"compression_encodings"`
+	CompressionMinSize   Size     `yaml:"compression_min_size"`
+	// CacheControl is the 'Cache-Control' header of the GET responses,
+	// CacheControlRoutes override it by the route pattern
+	CacheControl       string            `yaml:"cache_control"`
+	CacheControlRoutes map[string]string `yaml:"cache_control_routes"`
+	// TrustedProxies are the IPs and the CIDRs of the proxies whose
+	// 'Forwarded' and 'X-Forwarded-For' headers give the client IP
+	TrustedProxies []string `yaml:"trusted_proxies"`
+}
+
+// TLS enables HTTPS if the certificate and the key are set.
+type TLS struct {
+	CertFile string `yaml:"cert_file"`
+	KeyFile  string `yaml:"key_file"`
+	// ReloadInterval is how often the files are checked for a new certificate
+	ReloadInterval Duration `yaml:"reload_interval"`
+	// ClientAuth is 'none', 'optional' or 'require' client certificates
+	// signed by ClientCAFile
+	ClientAuth   string `yaml:"client_auth"`
+	ClientCAFile string `yaml:"client_ca_file"`
+	// ClientScopes are the comma-separated scopes of the client
+	// certificates by their common name, the other certificates have none
+	ClientScopes map[string]string `yaml:"client_scopes"`
+}
+
+func (t *TLS) Enabled() bool {
+	return t.CertFile != ""
+}
+
+type Postgres struct {
+	// URL replaces all other connection settings if set
+	URL      string `yaml:"url"`
+	Host     string `yaml:"host"`
+	Port     int    `yaml:"port"`
+	User     string `yaml:"user"`
+	Password string `yaml:"password"`
+	DB       string `yaml:"db"`
+	SSLMode  string `yaml:"sslmode"`
+	// RootCert is the CA of the server for the 'verify-ca' and 'verify-full' modes
+	RootCert string `yaml:"sslrootcert"`
+	// Timeout is the time to wait for the database before connecting
+	Timeout Duration `yaml:"timeout"`
+}
+
+type Auth struct {
+	JWTSecret   string `yaml:"jwt_secret"`
+	JWKSFile    string `yaml:"jwks_file"`
+	JWTIssuer   string `yaml:"jwt_issuer"`
+	JWTAudience string `yaml:"jwt_audience"`
+	PolicyFile  string `yaml:"policy_file"`
+}
+
+type RateLimit struct {
+	// Default is the limit of every route, e.g. "600/1m", or "off"
+	Default string `yaml:"default"`
+	// Routes are the limits by the route pattern
+	Routes map[string]string `yaml:"routes"`
+	Store  string            `yaml:"store"`
+	// IP is the limit of a client IP on all the routes before the
+	// authentication, e.g. "1200/1m", or "off"
+	IP string `yaml:"ip"`
+}
+
+type Outbox struct {
+	Publishers        []string `yaml:"publishers"`
+	HTTPURL           string   `yaml:"http_url"`
+	NATSURL           string   `yaml:"nats_url"`
+	NATSSubjectPrefix string   `yaml:"nats_subject_prefix"`
+	// Retention is the time the rows are kept after all the publishers
+	// have passed them
+	Retention Duration `yaml:"retention"`
+}
+
+type Subscribes struct {
+	ExpirationInterval Duration       `yaml:"expiration_interval"`
+	OverlapPolicy      string         `yaml:"overlap_policy"`
+	Cache              SubscribeCache `yaml:"cache"`
+}
+
+// SubscribeCache caches the subscribes read by id.
+type SubscribeCache struct {
+	// Store is 'off', 'memory' or 'redis'
+	Store string   `yaml:"store"`
+	TTL   Duration `yaml:"ttl"`
+	// Size is the largest number of the subscribes in the 'memory' store
+	Size     int    `yaml:"size"`
+	RedisURL string `yaml:"redis_url"`
+}
+
+type Idempotency struct {
+	TTL Duration `yaml:"ttl"`
+	// LockTimeout ends the claim of a request in progress, it must be
+	// longer than the handler timeout
+	LockTimeout Duration `yaml:"lock_timeout"`
+}
+
+// CORS is disabled while AllowedOrigins is empty.
+type CORS struct {
+	// AllowedOrigins are the origins like 'https://app.example.com', the
+	// subdomains like 'https://*.example.com' or '*' for any origin
+	AllowedOrigins   []string `yaml:"allowed_origins"`
+	AllowedMethods   []string `yaml:"allowed_methods"`
+	AllowedHeaders   []string `yaml:"allowed_headers"`
+	AllowCredentials bool     `yaml:"allow_credentials"`
+	MaxAge           Duration `yaml:"max_age"`
+}
+
+// Validate checks the origins, the credentials cannot be allowed for any
+// origin.
+func (c *CORS) Validate() error {
+	var errs error
+	for _, origin := range c.AllowedOrigins {
+		if origin == "*" {
+			if c.AllowCredentials {
+				errs = errors.Join(errs, errors.New("ERROR: 'CORS_ALLOW_CREDENTIALS' cannot be used with the origin '*'"))
+			}
+			continue
+		}
+		u, err := url.Parse(strings.Replace(origin, "://*.", "://", 1))
+		if err != nil || u.Scheme == "" || u.Host == "" || u.Path != "" || strings.Contains(u.Host, "*") {
+			errs = errors.Join(errs, fmt.Errorf("ERROR: incorrect origin '%s' in 'CORS_ALLOWED_ORIGINS', "+
+				"expected 'scheme://host[:port]', 'scheme://*.host' or '*'", origin))
+		}
+	}
+	if c.MaxAge < 0 {
+		errs = errors.Join(errs, errors.New("ERROR: 'CORS_MAX_AGE' must not be negative"))
+	}
+	return errs
+}
+
+// Default returns the configuration used when nothing else is set.
+func Default() *Config {
+	return &Config{
+		Server: Server{
+			Addr:             ":8000",
+			ShutdownDuration: Duration(10 * time.Second),
+			ShutdownDrain:    Duration(5 * time.Second),
+			TLS: TLS{
+				ReloadInterval: Duration(10 * time.Second),
+				ClientAuth:     "none",
+				ClientScopes:   map[string]string{},
+			},
+			ReadHeaderTimeout: Duration(5 * time.Second),
+			ReadTimeout:       Duration(time.Minute),
+			// the export streams a response for long
+			WriteTimeout:   Duration(5 * time.Minute),
+			IdleTimeout:    Duration(2 * time.Minute),
+			HandlerTimeout: Duration(30 * time.Second),
+			HandlerTimeoutRoutes: map[string]string{
+				"POST /api/v1/subscribes/import": "5m",
+			},
+			BodyLimit: 1 << 20,
+			BodyLimitRoutes: map[string]string{
+				"POST /api/v1/subscribes/import": "32MB",
+			},
+			CompressionEncodings: []string{"zstd", "br", "gzip"},
+			CompressionMinSize:   1 << 10,
+			CacheControl:         "private, no-cache",
+		},
+		Postgres: Postgres{
+			Host:    "localhost",
+			Port:    5432,
+			SSLMode: "disable",
+		},
+		RateLimit: RateLimit{
+			Default: "600/1m",
+			Routes:  map[string]string{},
+			Store:   "memory",
+			IP:      "1200/1m",
+		},
+		Outbox: Outbox{
+			Publishers:        []string{"log"},
+			NATSSubjectPrefix: "rest-subscription.",
+			Retention:         Duration(7 * 24 * time.Hour),
+		},
+		Subscribes: Subscribes{
+			ExpirationInterval: Duration(time.Minute),
+			OverlapPolicy:      "reject",
+			Cache: SubscribeCache{
+				Store: "off",
+				TTL:   Duration(time.Minute),
+				Size:  10000,
+			},
+		},
+		Idempotency: Idempotency{
+			TTL:         Duration(24 * time.Hour),
+			LockTimeout: Duration(10 * time.Minute),
+		},
+		CORS: CORS{
+			AllowedMethods: []string{"GET", "POST", "PUT", "PATCH", "DELETE"},
+			AllowedHeaders: []string{"Authorization", "Content-Type", "Idempotency-Key", "X-API-Key", "X-Request-ID"},
+			MaxAge:         Duration(10 * time.Minute),
+		},
+	}
+}
+
+// Load reads the configuration. The args are the command line arguments
+// without the program name, a single positional argument is the server
+// address. The config file is set by the flag '-config' or the environment
+// variable 'CONFIG_FILE'. The returned config is not nil even on errors
+// and is not validated.
+func Load(args []string) (*Config, error) {
+	cfg := Default()
+
+	fs := flag.NewFlagSet("rest-subscribe", flag.ContinueOnError)
+	configFile := fs.String("config", os.Getenv("CONFIG_FILE"), "the YAML or TOML config file")
+	flags := map[string]*string{}
+	for _, opt := range options {
+		flags[opt.flag()] = fs.String(opt.flag(), "", opt.usage+" (env "+opt.env+")")
+	}
+	if err := fs.Parse(args); err != nil {
+		return cfg, err
+	}
+
+	if *configFile != "" {
+		if err := cfg.loadFile(*configFile); err != nil {
+			return cfg, fmt.Errorf("ERROR: failed to load the config file '%s': %w", *configFile, err)
+		}
+	}
+
+	var errs error
+	for _, opt := range options {
+		v, ok, err := lookupEnv(opt.env)
+		if err != nil {
+			errs = errors.Join(errs, err)
+			continue
+		}
+		if !ok {
+			continue
+		}
+		if err := opt.set(cfg, v); err != nil {
+			errs = errors.Join(errs, fmt.Errorf("ERROR: the environment variable '%s': %w", opt.env, err))
+		}
+	}
+
+	switch fs.NArg() {
+	case 0:
+	case 1:
+		cfg.Server.Addr = fs.Arg(0)
+	default:
+		errs = errors.Join(errs, errors.New("ERROR: please provide at most one argument - server address (e.g. 'localhost:8080')"))
+	}
+	fs.Visit(func(f *flag.Flag) {
+		for _, opt := range options {
+			if opt.flag() != f.Name {
+				continue
+			}
+			if err := opt.set(cfg, *flags[f.Name]); err != nil {
+				errs = errors.Join(errs, fmt.Errorf("ERROR: the flag '-%s': %w", f.Name, err))
+			}
+		}
+	})
+
+	return cfg, errs
+}
+
+// lookupEnv reads the environment variable, or the file named by the
+// variable with the '_FILE' suffix, which is the usual way to pass secrets.
+func lookupEnv(name string) (string, bool, error) {
+	if v, ok := os.LookupEnv(name); ok && v != "" {
+		return v, true, nil
+	}
+	path, ok := os.LookupEnv(name + "_FILE")
+	if !ok || path == "" {
+		return "", false, nil
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return "", false, fmt.Errorf("ERROR: the environment variable '%s_FILE': %w", name, err)
+	}
+	return strings.TrimRight(string(b), "\r\n"), true, nil
+}
+
+// Validate checks the values which cannot be checked while parsing.
+func (c *Config) Validate() error {
+	var errs error
+	if c.Server.Addr == "" {
+		errs = errors.Join(errs, errors.New("ERROR: the server address is required"))
+	}
+	if c.Server.ShutdownDuration <= 0 {
+		errs = errors.Join(errs, errors.New("ERROR: 'SHUTDOWN_DURATION' must be positive"))
+	}
+
+	for _, timeout := range []struct {
+		env   string
+		value Duration
+	}{
+		{"SHUTDOWN_DRAIN", c.Server.ShutdownDrain},
+		{"SERVER_READ_HEADER_TIMEOUT", c.Server.ReadHeaderTimeout},
+		{"SERVER_READ_TIMEOUT", c.Server.ReadTimeout},
+		{"SERVER_WRITE_TIMEOUT", c.Server.WriteTimeout},
+		{"SERVER_IDLE_TIMEOUT", c.Server.IdleTimeout},
+		{"HANDLER_TIMEOUT", c.Server.HandlerTimeout},
+		{"OUTBOX_RETENTION", c.Outbox.Retention},
+	} {
+		if timeout.value < 0 {
+			errs = errors.Join(errs, fmt.Errorf("ERROR: '%s' must not be negative", timeout.env))
+		}
+	}
+	for pattern, timeout := range c.Server.HandlerTimeoutRoutes {
+		if d, err := ParseDuration(timeout); err != nil || d < 0 {
+			errs = errors.Join(errs, fmt.Errorf("ERROR: incorrect timeout '%s' of the route '%s' in 'HANDLER_TIMEOUT_ROUTES'", timeout, pattern))
+		}
+	}
+	if c.Server.BodyLimit <= 0 {
+		errs = errors.Join(errs, errors.New("ERROR: 'BODY_LIMIT' must be positive"))
+	}
+	for pattern, limit := range c.Server.BodyLimitRoutes {
+		if size, err := ParseSize(limit); err != nil || size <= 0 {
+			errs = errors.Join(errs, fmt.Errorf("ERROR: incorrect limit '%s' of the route '%s' in 'BODY_LIMIT_ROUTES'", limit, pattern))
+		}
+	}
+	for _, proxy := range c.Server.TrustedProxies {
+		if _, err := ParsePrefix(proxy); err != nil {
+			errs = errors.Join(errs, fmt.Errorf("ERROR: %w in 'TRUSTED_PROXIES', expected an IP or a CIDR, e.g. '10.0.0.0/8'", err))
+		}
+	}
+	if c.Server.CompressionMinSize < 0 {
+		errs = errors.Join(errs, errors.New("ERROR: 'COMPRESSION_MIN_SIZE' must not be negative"))
+	}
+	errs = errors.Join(errs, c.Server.TLS.Validate())
+	errs = errors.Join(errs, c.CORS.Validate())
+	errs = errors.Join(errs, c.Postgres.Validate())
+
+	if c.Auth.JWTSecret == "" && c.Auth.JWKSFile == "" {
+		errs = errors.Join(errs, errors.New("ERROR: 'JWT_SECRET' or 'JWT_JWKS_FILE' is required to verify bearer tokens"))
+	}
+	// the secret of the old .env is public
+	if c.Auth.JWTSecret == "change-me-jwt-secret" {
+		errs = errors.Join(errs, errors.New("ERROR: 'JWT_SECRET' has the placeholder value, set a secret of your own"))
+	}
+
+	if c.RateLimit.Store != "memory" && c.RateLimit.Store != "postgres" {
+		errs = errors.Join(errs, errors.New("ERROR: 'RATE_LIMIT_STORE' can only be 'memory' or 'postgres'"))
+	}
+
+	for _, name := range c.Outbox.Publishers {
+		switch name {
+		case "log":
+		case "http":
+			if c.Outbox.HTTPURL == "" {
+				errs = errors.Join(errs, errors.New("ERROR: 'OUTBOX_HTTP_URL' is required by the 'http' outbox publisher"))
+			}
+		case "nats":
+			if c.Outbox.NATSURL == "" {
+				errs = errors.Join(errs, errors.New("ERROR: 'OUTBOX_NATS_URL' is required by the 'nats' outbox publisher"))
+			}
+		default:
+			errs = errors.Join(errs, fmt.Errorf("ERROR: unknown outbox publisher '%s'. "+
+				"The publishers can only be 'log', 'http' and 'nats'", name))
+		}
+	}
+
+	if c.Subscribes.ExpirationInterval <= 0 {
+		errs = errors.Join(errs, errors.New("ERROR: 'EXPIRATION_INTERVAL' must be positive"))
+	}
+	switch c.Subscribes.OverlapPolicy {
+	case "reject", "warn", "allow":
+	default:
+		errs = errors.Join(errs, errors.New("ERROR: 'OVERLAP_POLICY' can only be 'reject', 'warn' or 'allow'"))
+	}
+	switch c.Subscribes.Cache.Store {
+	case "off":
+	case "memory":
+		if c.Subscribes.Cache.Size <= 0 {
+			errs = errors.Join(errs, errors.New("ERROR: 'SUBSCRIBE_CACHE_SIZE' must be positive"))
+		}
+	case "redis":
+		if c.Subscribes.Cache.RedisURL == "" {
+			errs = errors.Join(errs, errors.New("ERROR: 'SUBSCRIBE_CACHE_REDIS_URL' is required by the 'redis' subscribe cache"))
+		}
+	default:
+		errs = errors.Join(errs, errors.New("ERROR: 'SUBSCRIBE_CACHE_STORE' can only be 'off', 'memory' or 'redis'"))
+	}
+	if c.Subscribes.Cache.Store != "off" && c.Subscribes.Cache.TTL <= 0 {
+		errs = errors.Join(errs, errors.New("ERROR: 'SUBSCRIBE_CACHE_TTL' must be positive"))
+	}
+	if c.Idempotency.TTL <= 0 {
+		errs = errors.Join(errs, errors.New("ERROR: 'IDEMPOTENCY_TTL' must be positive"))
+	}
+	if c.Idempotency.LockTimeout <= c.maxHandlerTimeout() {
+		errs = errors.Join(errs, errors.New("ERROR: 'IDEMPOTENCY_LOCK_TIMEOUT' must be longer than 'HANDLER_TIMEOUT' and 'HANDLER_TIMEOUT_ROUTES'"))
+	}
+	return errs
+}
+
+// maxHandlerTimeout is the longest time a request may be handled.
+func (c *Config) maxHandlerTimeout() Duration {
+	longest := c.Server.HandlerTimeout
+	for _, timeout := range c.Server.HandlerTimeoutRoutes {
+		if d, err := ParseDuration(timeout); err == nil {
+			longest = max(longest, d)
+		}
+	}
+	return longest
+}
+
+// Validate checks that the TLS files are set together.
+func (t *TLS) Validate() error {
+	var errs error
+	if (t.CertFile == "") != (t.KeyFile == "") {
+		errs = errors.Join(errs, errors.New("ERROR: 'TLS_CERT_FILE' and 'TLS_KEY_FILE' must be set together"))
+	}
+	if t.ReloadInterval <= 0 {
+		errs = errors.Join(errs, errors.New("ERROR: 'TLS_RELOAD_INTERVAL' must be positive"))
+	}
+	switch t.ClientAuth {
+	case "none":
+		if t.ClientCAFile != "" {
+			errs = errors.Join(errs, errors.New("ERROR: 'TLS_CLIENT_CA_FILE' is set, but 'TLS_CLIENT_AUTH' is 'none'"))
+		}
+	case "optional", "require":
+		if !t.Enabled() {
+			errs = errors.Join(errs, errors.New("ERROR: 'TLS_CLIENT_AUTH' requires 'TLS_CERT_FILE' and 'TLS_KEY_FILE'"))
+		}
+		if t.ClientCAFile == "" {
+			errs = errors.Join(errs, errors.New("ERROR: 'TLS_CLIENT_AUTH' requires 'TLS_CLIENT_CA_FILE'"))
+		}
+	default:
+		errs = errors.Join(errs, errors.New("ERROR: 'TLS_CLIENT_AUTH' can only be 'none', 'optional' or 'require'"))
+	}
+	return errs
+}
+
+// Validate checks the connection settings of the database.
+func (p *Postgres) Validate() error {
+	var errs error
+	if p.URL == "" {
+		for _, required := range []struct{ env, value string }{
+			{"POSTGRES_USER", p.User},
+			{"POSTGRES_PASSWORD", p.Password},
+			{"POSTGRES_DB", p.DB},
+			{"POSTGRES_HOST", p.Host},
+		} {
+			if required.value == "" {
+				errs = errors.Join(errs, fmt.Errorf("ERROR: '%s' is required unless 'DATABASE_URL' is set", required.env))
+			}
+		}
+		if p.Port <= 0 || p.Port > 65535 {
+			errs = errors.Join(errs, errors.New("ERROR: 'POSTGRES_PORT' must be a port number"))
+		}
+		switch p.SSLMode {
+		case "disable", "allow", "prefer", "require", "verify-ca", "verify-full":
+		default:
+			errs = errors.Join(errs, fmt.Errorf("ERROR: unknown 'POSTGRES_SSLMODE' '%s'", p.SSLMode))
+		}
+	}
+	if p.Timeout < 0 {
+		errs = errors.Join(errs, errors.New("ERROR: 'POSTGRES_TIMEOUT' must not be negative"))
+	}
+	return errs
+}
+
+// DSN returns the connection string of the database.
+func (p *Postgres) DSN() string {
+	if p.URL != "" {
+		return p.URL
+	}
+	dsn := fmt.Sprintf("host=%s port=%d user=%s dbname=%s password=%s sslmode=%s",
+		dsnValue(p.Host), p.Port, dsnValue(p.User), dsnValue(p.DB), dsnValue(p.Password), dsnValue(p.SSLMode))
+	if p.RootCert != "" {
+		dsn += " sslrootcert=" + dsnValue(p.RootCert)
+	}
+	return dsn
+}
+
+// dsnValue quotes a value of the key=value connection string if needed.
+func dsnValue(s string) string {
+	if s != "" && !strings.ContainsAny(s, ` '\`) {
+		return s
+	}
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `'`, `\'`)
+	return "'" + s + "'"
+}
+
+// Duration is a time.Duration which is written either as a number of
+// seconds or as a Go duration, e.g. "1m30s".
+type Duration time.Duration
+
+func ParseDuration(s string) (Duration, error) {
+	s = strings.TrimSpace(s)
+	if n, err := strconv.Atoi(s); err == nil {
+		return Duration(time.Duration(n) * time.Second), nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("incorrect duration '%s', expected a number of seconds or e.g. '1m30s'", s)
+	}
+	return Duration(d), nil
+}
+
+func (d Duration) String() string {
+	return time.Duration(d).String()
+}
+
+func (d *Duration) UnmarshalText(b []byte) error {
+	parsed, err := ParseDuration(string(b))
+	if err != nil {
+		return err
+	}
+	*d = parsed
+	return nil
+}
+
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(d.String()), nil
+}
+
+// ParsePrefix parses a CIDR or a single IP as the prefix of its length.
+func ParsePrefix(s string) (netip.Prefix, error) {
+	s = strings.TrimSpace(s)
+	if addr, err := netip.ParseAddr(s); err == nil {
+		addr = addr.Unmap()
+		return netip.PrefixFrom(addr, addr.BitLen()), nil
+	}
+	prefix, err := netip.ParsePrefix(s)
+	if err != nil {
+		return netip.Prefix{}, fmt.Errorf("incorrect proxy '%s'", s)
+	}
+	return prefix.Masked(), nil
+}
+
+// Size is a number of bytes which is written either as a number or with
+// a 'KB', 'MB' or 'GB' suffix, e.g. "32MB".
+type Size int64
+
+var sizeUnits = []struct {
+	suffix string
+	bytes  int64
+}{
+	{"GB", 1 << 30},
+	{"MB", 1 << 20},
+	{"KB", 1 << 10},
+	{"B", 1},
+}
+
+func ParseSize(s string) (Size, error) {
+	s = strings.ToUpper(strings.TrimSpace(s))
+	unit := int64(1)
+	for _, u := range sizeUnits {
+		if n, ok := strings.CutSuffix(s, u.suffix); ok {
+			s, unit = strings.TrimSpace(n), u.bytes
+			break
+		}
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("incorrect size '%s', expected a number of bytes or e.g. '32MB'", s)
+	}
+	return Size(n * unit), nil
+}
+
+func (s Size) String() string {
+	for _, u := range sizeUnits {
+		if s != 0 && int64(s)%u.bytes == 0 {
+			return strconv.FormatInt(int64(s)/u.bytes, 10) + u.suffix
+		}
+	}
+	return "0"
+}
+
+func (s *Size) UnmarshalText(b []byte) error {
+	parsed, err := ParseSize(string(b))
+	if err != nil {
+		return err
+	}
+	*s = parsed
+	return nil
+}
+
+func (s Size) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// redactURL hides the password of the URL. The user without a password is
+// hidden too, it is a token in the NATS URLs.
+func redactURL(s string) string {
+	u, err := url.Parse(s)
+	if err != nil || u.Scheme == "" {
+		return redacted
+	}
+	if u.User != nil {
+		if _, ok := u.User.Password(); !ok {
+			u.User = url.User("xxxxx")
+		}
+	}
+	return u.Redacted()
+}
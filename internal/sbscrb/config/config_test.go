@@ -0,0 +1,261 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// clearEnv unsets the variables of the options for the test.
+func clearEnv(t *testing.T) {
+	for _, opt := range options {
+		for _, name := range []string{opt.env, opt.env + "_FILE"} {
+			if v, ok := os.LookupEnv(name); ok {
+				os.Unsetenv(name)
+				t.Cleanup(func() { os.Setenv(name, v) })
+			}
+		}
+	}
+	t.Setenv("CONFIG_FILE", "")
+}
+
+func writeFile(t *testing.T, name, content string) string {
+	path := filepath.Join(t.TempDir(), name)
+	assert.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+func TestLoadOrder(t *testing.T) {
+	clearEnv(t)
+	file := writeFile(t, "config.yaml", `
+server:
+  addr: ":9000"
+  shutdown_duration: 15
+postgres:
+  host: db.internal
+  user: admin
+  db: database
+  sslmode: require
+rate_limit:
+  routes:
+    GET /api/v1/subscribe: 60/1m
+subscribes:
+  expiration_interval: 1m30s
+`)
+	t.Setenv("POSTGRES_PORT", "6432")
+	t.Setenv("POSTGRES_SSLMODE", "verify-full")
+	t.Setenv("POSTGRES_SSLROOTCERT", "/etc/ssl/postgres/root.crt")
+	t.Setenv("POSTGRES_PASSWORD_FILE", writeFile(t, "password", "secret-password\n"))
+	t.Setenv("JWT_SECRET", "jwt-secret")
+	t.Setenv("SERVER_ADDR", ":9001")
+	t.Setenv("SHUTDOWN_DRAIN", "0")
+
+	cfg, err := Load([]string{"-config", file, "-addr", ":9002", "-outbox-publishers", "log, http", "-outbox-http-url", "http://localhost"})
+	assert.NoError(t, err)
+	assert.NoError(t, cfg.Validate())
+
+	assert.Equal(t, ":9002", cfg.Server.Addr)
+	assert.Equal(t, Duration(15*time.Second), cfg.Server.ShutdownDuration)
+	assert.Equal(t, Duration(0), cfg.Server.ShutdownDrain)
+	assert.Equal(t, "db.internal", cfg.Postgres.Host)
+	assert.Equal(t, 6432, cfg.Postgres.Port)
+	assert.Equal(t, "secret-password", cfg.Postgres.Password)
+	assert.Equal(t, "verify-full", cfg.Postgres.SSLMode)
+	assert.Equal(t, map[string]string{"GET /api/v1/subscribe": "60/1m"}, cfg.RateLimit.Routes)
+	assert.Equal(t, "600/1m", cfg.RateLimit.Default)
+	assert.Equal(t, Duration(90*time.Second), cfg.Subscribes.ExpirationInterval)
+	assert.Equal(t, Size(1<<20), cfg.Server.BodyLimit)
+	assert.Equal(t, []string{"log", "http"}, cfg.Outbox.Publishers)
+	assert.Equal(t,
+		"host=db.internal port=6432 user=admin dbname=database password=secret-password sslmode=verify-full "+
+			"sslrootcert=/etc/ssl/postgres/root.crt",
+		cfg.Postgres.DSN())
+}
+
+func TestLoadTOML(t *testing.T) {
+	clearEnv(t)
+	file := writeFile(t, "config.toml", `
+# the database
+[postgres]
+url = "postgres://admin:secret@db:5432/database?sslmode=disable" # inline comment
+
+[auth]
+jwt_secret = 'jwt-secret'
+
+[rate_limit]
+routes = { "GET /api/v1/subscribe" = "60/1m" }
+
+[outbox]
+publishers = [
+  "log",
+  "nats", # the broker
+]
+nats_url = "nats://localhost:4222"
+nats_subject_prefix = "billing\u002E"
+
+[idempotency]
+ttl = 3600
+`)
+	t.Setenv("CONFIG_FILE", file)
+
+	cfg, err := Load(nil)
+	assert.NoError(t, err)
+	assert.NoError(t, cfg.Validate())
+	assert.Equal(t, "postgres://admin:secret@db:5432/database?sslmode=disable", cfg.Postgres.DSN())
+	assert.Equal(t, "jwt-secret", cfg.Auth.JWTSecret)
+	assert.Equal(t, map[string]string{"GET /api/v1/subscribe": "60/1m"}, cfg.RateLimit.Routes)
+	assert.Equal(t, []string{"log", "nats"}, cfg.Outbox.Publishers)
+	assert.Equal(t, "billing.", cfg.Outbox.NATSSubjectPrefix)
+	assert.Equal(t, Duration(time.Hour), cfg.Idempotency.TTL)
+}
+
+func TestLoadErrors(t *testing.T) {
+	t.Run("Validation", func(t *testing.T) {
+		clearEnv(t)
+		t.Setenv("RATE_LIMIT_STORE", "redis")
+		t.Setenv("SHUTDOWN_DURATION", "abc")
+		// longer than 'HANDLER_TIMEOUT', but not than the timeout of the import
+		t.Setenv("IDEMPOTENCY_LOCK_TIMEOUT", "2m")
+
+		cfg, err := Load([]string{"localhost:8080"})
+		assert.ErrorContains(t, err, "'SHUTDOWN_DURATION'")
+		assert.Equal(t, "localhost:8080", cfg.Server.Addr)
+
+		err = cfg.Validate()
+		assert.ErrorContains(t, err, "'POSTGRES_USER' is required")
+		assert.ErrorContains(t, err, "'JWT_SECRET' or 'JWT_JWKS_FILE' is required")
+		assert.ErrorContains(t, err, "'RATE_LIMIT_STORE'")
+		assert.ErrorContains(t, err, "'IDEMPOTENCY_LOCK_TIMEOUT' must be longer than 'HANDLER_TIMEOUT' and 'HANDLER_TIMEOUT_ROUTES'")
+	})
+
+	t.Run("HandlerTimeoutRoutes", func(t *testing.T) {
+		clearEnv(t)
+		t.Setenv("HANDLER_TIMEOUT_ROUTES", "POST /api/v1/subscribes/import=5 minutes")
+
+		cfg, err := Load(nil)
+		assert.NoError(t, err)
+		assert.ErrorContains(t, cfg.Validate(), "incorrect timeout '5 minutes' of the route 'POST /api/v1/subscribes/import' in 'HANDLER_TIMEOUT_ROUTES'")
+	})
+
+	t.Run("PlaceholderJWTSecret", func(t *testing.T) {
+		clearEnv(t)
+		t.Setenv("JWT_SECRET", "change-me-jwt-secret")
+
+		cfg, err := Load(nil)
+		assert.NoError(t, err)
+		assert.ErrorContains(t, cfg.Validate(), "'JWT_SECRET' has the placeholder value")
+	})
+
+	t.Run("TLS", func(t *testing.T) {
+		clearEnv(t)
+		t.Setenv("TLS_KEY_FILE", "tls.key")
+		t.Setenv("TLS_CLIENT_AUTH", "require")
+
+		cfg, err := Load(nil)
+		assert.NoError(t, err)
+		err = cfg.Validate()
+		assert.ErrorContains(t, err, "'TLS_CERT_FILE' and 'TLS_KEY_FILE' must be set together")
+		assert.ErrorContains(t, err, "'TLS_CLIENT_AUTH' requires 'TLS_CERT_FILE'")
+		assert.ErrorContains(t, err, "'TLS_CLIENT_AUTH' requires 'TLS_CLIENT_CA_FILE'")
+	})
+
+	t.Run("SubscribeCache", func(t *testing.T) {
+		clearEnv(t)
+		t.Setenv("SUBSCRIBE_CACHE_STORE", "redis")
+		t.Setenv("SUBSCRIBE_CACHE_TTL", "0")
+
+		cfg, err := Load(nil)
+		assert.NoError(t, err)
+		err = cfg.Validate()
+		assert.ErrorContains(t, err, "'SUBSCRIBE_CACHE_REDIS_URL' is required")
+		assert.ErrorContains(t, err, "'SUBSCRIBE_CACHE_TTL' must be positive")
+	})
+
+	t.Run("CORS", func(t *testing.T) {
+		clearEnv(t)
+		t.Setenv("CORS_ALLOWED_ORIGINS", "*, https://*.example.com, example.com, https://app.example.com/path")
+		t.Setenv("CORS_ALLOW_CREDENTIALS", "true")
+
+		cfg, err := Load(nil)
+		assert.NoError(t, err)
+		assert.True(t, cfg.CORS.AllowCredentials)
+		err = cfg.Validate()
+		assert.ErrorContains(t, err, "'CORS_ALLOW_CREDENTIALS' cannot be used with the origin '*'")
+		assert.ErrorContains(t, err, "incorrect origin 'example.com'")
+		assert.ErrorContains(t, err, "incorrect origin 'https://app.example.com/path'")
+		assert.NotContains(t, err.Error(), "'https://*.example.com'")
+	})
+
+	t.Run("TrustedProxies", func(t *testing.T) {
+		clearEnv(t)
+		t.Setenv("TRUSTED_PROXIES", "10.0.0.0/8, 192.168.1.10, fd00::/8, proxy.local")
+
+		cfg, err := Load(nil)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"10.0.0.0/8", "192.168.1.10", "fd00::/8", "proxy.local"}, cfg.Server.TrustedProxies)
+		err = cfg.Validate()
+		assert.ErrorContains(t, err, "incorrect proxy 'proxy.local' in 'TRUSTED_PROXIES'")
+		assert.NotContains(t, err.Error(), "'192.168.1.10'")
+	})
+
+	t.Run("UnknownField", func(t *testing.T) {
+		clearEnv(t)
+		file := writeFile(t, "config.yml", "server:\n  address: ':9000'\n")
+		_, err := Load([]string{"-config", file})
+		assert.ErrorContains(t, err, "address")
+	})
+}
+
+func TestRedacted(t *testing.T) {
+	cfg := Default()
+	cfg.Postgres.URL = "postgres://admin:secret@db:5432/database"
+	cfg.Postgres.Password = "secret"
+	cfg.Subscribes.Cache.RedisURL = "redis://:secret@cache:6379/0"
+	cfg.Outbox.NATSURL = "nats://token@nats:4222"
+	cfg.Auth.JWTSecret = "jwt-secret"
+
+	settings := map[string]string{}
+	for _, setting := range cfg.Redacted() {
+		settings[setting[0]] = setting[1]
+	}
+	assert.Equal(t, "postgres://admin:xxxxx@db:5432/database", settings["DATABASE_URL"])
+	assert.Equal(t, redacted, settings["POSTGRES_PASSWORD"])
+	assert.Equal(t, "redis://:xxxxx@cache:6379/0", settings["SUBSCRIBE_CACHE_REDIS_URL"])
+	assert.Equal(t, "nats://xxxxx@nats:4222", settings["OUTBOX_NATS_URL"])
+	assert.Equal(t, redacted, settings["JWT_SECRET"])
+	assert.Equal(t, "1m0s", settings["EXPIRATION_INTERVAL"])
+	assert.Equal(t, "", settings["JWT_ISSUER"])
+}
+
+func TestParseSize(t *testing.T) {
+	for s, expected := range map[string]Size{"1024": 1024, "512KB": 512 << 10, "32 mb": 32 << 20, "1GB": 1 << 30} {
+		size, err := ParseSize(s)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, size)
+	}
+	_, err := ParseSize("1TB")
+	assert.Error(t, err)
+	assert.Equal(t, "32MB", Size(32<<20).String())
+	assert.Equal(t, "1000B", Size(1000).String())
+}
+
+func TestDSNQuoting(t *testing.T) {
+	p := Postgres{Host: "localhost", Port: 5432, User: "admin", DB: "database", Password: `it's a secret`, SSLMode: "disable"}
+	assert.Equal(t,
+		`host=localhost port=5432 user=admin dbname=database password='it\'s a secret' sslmode=disable`,
+		p.DSN())
+}
+
+func TestOptionUnsupportedType(t *testing.T) {
+	opt := option{env: "TLS", field: func(c *Config) any { return &c.Server.TLS }}
+	assert.ErrorContains(t, opt.set(Default(), "on"), "not supported")
+
+	// every option can be set from the environment
+	for _, opt := range options {
+		cfg := Default()
+		assert.NoError(t, opt.set(cfg, opt.get(cfg)), opt.env)
+	}
+}
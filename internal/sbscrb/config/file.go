@@ -0,0 +1,43 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// loadFile reads the YAML or TOML file over the config, by the extension.
+func (c *Config) loadFile(path string) error {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+	case ".toml":
+		// the TOML document is converted to YAML to share the field tags
+		doc := map[string]any{}
+		if err := toml.Unmarshal(b, &doc); err != nil {
+			return err
+		}
+		if b, err = yaml.Marshal(doc); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unknown config format '%s', expected '.yaml', '.yml' or '.toml'", filepath.Ext(path))
+	}
+
+	d := yaml.NewDecoder(bytes.NewReader(b))
+	d.KnownFields(true)
+	if err := d.Decode(c); err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package events
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/pabloeclair/rest-subscription/internal/sbscrb/models"
+)
+
+type Type string
+
+const (
+	SubscribeCreated Type = "subscribe.created"
+	SubscribeUpdated Type = "subscribe.updated"
+	SubscribeDeleted Type = "subscribe.deleted"
+	SubscribeExpired Type = "subscribe.expired"
+)
+
+type Event struct {
+	ID         string               `json:"id"`
+	Type       Type                 `json:"type"`
+	OccurredAt time.Time            `json:"occurred_at"`
+	Subscribe  *models.SubscribeDto `json:"subscribe"`
+}
+
+type Handler func(e Event) error
+
+var (
+	mu       sync.RWMutex
+	handlers []Handler
+)
+
+func New(t Type, subscribe *models.SubscribeDto) Event {
+	return Event{
+		ID:         NewID(),
+		Type:       t,
+		OccurredAt: time.Now().UTC(),
+		Subscribe:  subscribe,
+	}
+}
+
+func NewID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// Listen registers h to be called for every emitted event.
+func Listen(h Handler) {
+	mu.Lock()
+	defer mu.Unlock()
+	handlers = append(handlers, h)
+}
+
+// Emit calls the registered handlers synchronously in registration order
+// and returns the joined errors of the failed ones.
+func Emit(e Event) error {
+	mu.RLock()
+	defer mu.RUnlock()
+	var errs error
+	for _, h := range handlers {
+		errs = errors.Join(errs, h(e))
+	}
+	return errs
+}
+
+func LogHandler(e Event) error {
+	var id uint
+	if e.Subscribe != nil {
+		id = e.Subscribe.ID
+	}
+	log.Printf("EVENT: %s %s: subscribe id = %d", e.Type, e.ID, id)
+	return nil
+}
@@ -0,0 +1,108 @@
+package export
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/pabloeclair/rest-subscription/internal/sbscrb/models"
+)
+
+var Columns = []string{"id", "service_name", "price", "user_id", "start_date", "end_date", "expired_at"}
+
+type Writer interface {
+	Write(subscribe *models.SubscribeDto) error
+	// Close writes the trailing data of the format, it does not close the
+	// underlying writer.
+	Close() error
+}
+
+type Format struct {
+	ContentType string
+	Extension   string
+	New         func(w io.Writer) (Writer, error)
+}
+
+var Formats = map[string]Format{
+	"csv": {
+		ContentType: "text/csv; charset=utf-8",
+		Extension:   "csv",
+		New:         NewCSVWriter,
+	},
+	"ndjson": {
+		ContentType: "application/x-ndjson",
+		Extension:   "ndjson",
+		New:         NewNDJSONWriter,
+	},
+	"xlsx": {
+		ContentType: "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+		Extension:   "xlsx",
+		New:         NewXLSXWriter,
+	},
+}
+
+func record(s *models.SubscribeDto) []string {
+	price := ""
+	if s.Price != nil {
+		price = strconv.Itoa(*s.Price)
+	}
+	return []string{
+		strconv.FormatUint(uint64(s.ID), 10),
+		s.ServiceName,
+		price,
+		s.UserId,
+		formatTime(&s.StartDate),
+		formatTime(s.EndDate),
+		formatTime(s.ExpiredAt),
+	}
+}
+
+func formatTime(t *time.Time) string {
+	if t == nil || t.IsZero() {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
+
+type csvWriter struct {
+	w *csv.Writer
+}
+
+func NewCSVWriter(w io.Writer) (Writer, error) {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(Columns); err != nil {
+		return nil, err
+	}
+	return &csvWriter{cw}, nil
+}
+
+func (cw *csvWriter) Write(s *models.SubscribeDto) error {
+	return cw.w.Write(record(s))
+}
+
+func (cw *csvWriter) Close() error {
+	cw.w.Flush()
+	return cw.w.Error()
+}
+
+type ndjsonWriter struct {
+	enc *json.Encoder
+}
+
+func NewNDJSONWriter(w io.Writer) (Writer, error) {
+	return &ndjsonWriter{json.NewEncoder(w)}, nil
+}
+
+func (nw *ndjsonWriter) Write(s *models.SubscribeDto) error {
+	if err := nw.enc.Encode(s); err != nil {
+		return fmt.Errorf("ndjson: %w", err)
+	}
+	return nil
+}
+
+func (nw *ndjsonWriter) Close() error {
+	return nil
+}
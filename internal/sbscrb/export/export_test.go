@@ -0,0 +1,90 @@
+package export
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pabloeclair/rest-subscription/internal/sbscrb/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func testSubscribes() []*models.SubscribeDto {
+	price := 399
+	endDate := time.Date(2025, time.August, 26, 0, 0, 0, 0, time.UTC)
+	return []*models.SubscribeDto{
+		{
+			ID:          1,
+			ServiceName: "Kinopoisk",
+			Price:       &price,
+			UserId:      "6061fee-2bf1-aef6f-763675gre",
+			StartDate:   time.Date(2025, time.July, 26, 0, 0, 0, 0, time.UTC),
+			EndDate:     &endDate,
+		},
+		{
+			ID:          2,
+			ServiceName: "Tom & Jerry <Premium>",
+			Price:       &price,
+			UserId:      "708gr-26896-agrfrf-fr5655gre",
+			StartDate:   time.Date(2025, time.July, 15, 0, 0, 0, 0, time.UTC),
+		},
+	}
+}
+
+func writeAll(t *testing.T, format string) []byte {
+	var buf bytes.Buffer
+	w, err := Formats[format].New(&buf)
+	assert.NoError(t, err)
+	for _, s := range testSubscribes() {
+		assert.NoError(t, w.Write(s))
+	}
+	assert.NoError(t, w.Close())
+	return buf.Bytes()
+}
+
+func TestCSVWriter(t *testing.T) {
+	records, err := csv.NewReader(bytes.NewReader(writeAll(t, "csv"))).ReadAll()
+	assert.NoError(t, err)
+	assert.Len(t, records, 3)
+	assert.Equal(t, Columns, records[0])
+	assert.Equal(t, []string{"1", "Kinopoisk", "399", "6061fee-2bf1-aef6f-763675gre",
+		"2025-07-26T00:00:00Z", "2025-08-26T00:00:00Z", ""}, records[1])
+	assert.Equal(t, "", records[2][5])
+}
+
+func TestNDJSONWriter(t *testing.T) {
+	lines := strings.Split(strings.TrimSpace(string(writeAll(t, "ndjson"))), "\n")
+	assert.Len(t, lines, 2)
+	var s models.SubscribeDto
+	assert.NoError(t, json.Unmarshal([]byte(lines[1]), &s))
+	assert.Equal(t, uint(2), s.ID)
+}
+
+func TestXLSXWriter(t *testing.T) {
+	b := writeAll(t, "xlsx")
+	zr, err := zip.NewReader(bytes.NewReader(b), int64(len(b)))
+	assert.NoError(t, err)
+
+	names := []string{}
+	var sheet string
+	for _, f := range zr.File {
+		names = append(names, f.Name)
+		if f.Name == "xl/worksheets/sheet1.xml" {
+			rc, err := f.Open()
+			assert.NoError(t, err)
+			content, _ := io.ReadAll(rc)
+			rc.Close()
+			sheet = string(content)
+		}
+	}
+	assert.Contains(t, names, "[Content_Types].xml")
+	assert.Contains(t, names, "xl/workbook.xml")
+	assert.Contains(t, sheet, `<c r="A2"><v>1</v></c>`)
+	assert.Contains(t, sheet, `<t>Tom &amp; Jerry &lt;Premium&gt;</t>`)
+	assert.Equal(t, 3, strings.Count(sheet, "<row "))
+}
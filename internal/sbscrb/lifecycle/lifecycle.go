@@ -0,0 +1,136 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pabloeclair/rest-subscription/internal/sbscrb/models"
+)
+
+// Manager runs the background workers and shuts the service down in
+// order: the readiness fails, the load balancers are given the drain period
+// to stop sending requests, then the shutdown phases run in the reverse
+// order of their registration, like deferred calls.
+type Manager struct {
+	// PhaseTimeout bounds every shutdown phase
+	PhaseTimeout time.Duration
+	// Drain is the time between failing the readiness and the first phase
+	Drain time.Duration
+
+	ready atomic.Bool
+
+	mu     sync.Mutex
+	phases []phase
+	once   sync.Once
+	err    error
+
+	workersCtx  context.Context
+	stopWorkers context.CancelFunc
+	workers     sync.WaitGroup
+}
+
+type phase struct {
+	name string
+	stop func(ctx context.Context) error
+}
+
+func New(phaseTimeout, drain time.Duration) *Manager {
+	m := &Manager{PhaseTimeout: phaseTimeout, Drain: drain}
+	m.workersCtx, m.stopWorkers = context.WithCancel(context.Background())
+	return m
+}
+
+// Ready reports if the service accepts the requests.
+func (m *Manager) Ready() bool {
+	return m.ready.Load()
+}
+
+func (m *Manager) SetReady(ready bool) {
+	m.ready.Store(ready)
+}
+
+// OnShutdown registers a phase, the phases run in the reverse order.
+func (m *Manager) OnShutdown(name string, stop func(ctx context.Context) error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.phases = append(m.phases, phase{name: name, stop: stop})
+}
+
+// Go runs the worker until StopWorkers.
+func (m *Manager) Go(name string, run func(ctx context.Context)) {
+	m.workers.Add(1)
+	go func() {
+		defer m.workers.Done()
+		run(m.workersCtx)
+		log.Printf("lifecycle: worker '%s' stopped", name)
+	}()
+}
+
+// StopWorkers cancels the context of the workers and waits for them.
+func (m *Manager) StopWorkers(ctx context.Context) error {
+	m.stopWorkers()
+	done := make(chan struct{})
+	go func() {
+		m.workers.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Shutdown runs once, the next calls return the result of the first one.
+// A phase which does not finish in time is left behind and the next phase
+// starts.
+func (m *Manager) Shutdown() error {
+	m.once.Do(func() {
+		if m.ready.Swap(false) && m.Drain > 0 {
+			log.Printf("lifecycle: the readiness is failing, draining for %s", m.Drain)
+			time.Sleep(m.Drain)
+		}
+
+		m.mu.Lock()
+		phases := m.phases
+		m.mu.Unlock()
+		for i := len(phases) - 1; i >= 0; i-- {
+			if err := m.runPhase(phases[i]); err != nil {
+				m.err = errors.Join(m.err, fmt.Errorf("%s: %w", phases[i].name, err))
+			}
+		}
+		m.stopWorkers()
+	})
+	return m.err
+}
+
+func (m *Manager) runPhase(p phase) error {
+	log.Printf("lifecycle: %s: stopping...", p.name)
+	start := time.Now()
+	ctx, cancel := context.WithTimeout(context.Background(), m.PhaseTimeout)
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() {
+		done <- p.stop(ctx)
+	}()
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = fmt.Errorf("not stopped in %s", m.PhaseTimeout)
+	}
+
+	if err != nil {
+		log.Println(models.RedString("ERROR: lifecycle: ", p.name, ": ", err.Error()))
+		return err
+	}
+	log.Printf("lifecycle: %s: stopped in %s", p.name, time.Since(start).Round(time.Millisecond))
+	return nil
+}
@@ -0,0 +1,72 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestShutdownOrder(t *testing.T) {
+	m := New(time.Second, 10*time.Millisecond)
+
+	var (
+		mu    sync.Mutex
+		order []string
+	)
+	record := func(name string) {
+		mu.Lock()
+		defer mu.Unlock()
+		order = append(order, name)
+	}
+
+	m.OnShutdown("database", func(ctx context.Context) error {
+		record("database")
+		return nil
+	})
+	m.OnShutdown("workers", m.StopWorkers)
+	m.Go("expiration", func(ctx context.Context) {
+		<-ctx.Done()
+		record("worker")
+	})
+	m.OnShutdown("http", func(ctx context.Context) error {
+		assert.False(t, m.Ready())
+		record("http")
+		return nil
+	})
+	m.SetReady(true)
+
+	start := time.Now()
+	assert.NoError(t, m.Shutdown())
+	assert.GreaterOrEqual(t, time.Since(start), 10*time.Millisecond)
+	assert.Equal(t, []string{"http", "worker", "database"}, order)
+
+	// the next calls do nothing
+	assert.NoError(t, m.Shutdown())
+	assert.Len(t, order, 3)
+}
+
+func TestShutdownPhaseTimeout(t *testing.T) {
+	m := New(20*time.Millisecond, time.Hour)
+
+	closed := false
+	m.OnShutdown("database", func(ctx context.Context) error {
+		closed = true
+		return nil
+	})
+	m.OnShutdown("stuck", func(ctx context.Context) error {
+		select {}
+	})
+	m.OnShutdown("failing", func(ctx context.Context) error {
+		return errors.New("failed")
+	})
+
+	// the service was never ready, so there is no drain
+	err := m.Shutdown()
+	assert.ErrorContains(t, err, "failing: failed")
+	assert.ErrorContains(t, err, "stuck: not stopped in 20ms")
+	assert.True(t, closed)
+}
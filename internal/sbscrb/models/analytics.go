@@ -0,0 +1,87 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// AnalyticsBucket is the step of the analytics series in the calendar
+// units, the months are added like the Postgres intervals and AddDate do.
+type AnalyticsBucket struct {
+	Months int
+	Days   int
+}
+
+var AnalyticsBuckets = map[string]AnalyticsBucket{
+	"day":   {Days: 1},
+	"week":  {Days: 7},
+	"month": {Months: 1},
+}
+
+// Interval is the step of generate_series.
+func (b AnalyticsBucket) Interval() string {
+	if b.Months > 0 {
+		return fmt.Sprintf("%d month", b.Months)
+	}
+	return fmt.Sprintf("%d day", b.Days)
+}
+
+// Start is the start of the bucket of the time like date_trunc, the weeks
+// start on Monday.
+func (b AnalyticsBucket) Start(t time.Time) time.Time {
+	y, m, d := t.Date()
+	switch {
+	case b.Months > 0:
+		return time.Date(y, m, 1, 0, 0, 0, 0, t.Location())
+	case b.Days == 7:
+		return time.Date(y, m, d-(int(t.Weekday())+6)%7, 0, 0, 0, 0, t.Location())
+	}
+	return time.Date(y, m, d, 0, 0, 0, 0, t.Location())
+}
+
+// Count is the number of the buckets from the bucket of from to the bucket
+// of to.
+func (b AnalyticsBucket) Count(from, to time.Time) int {
+	from, to = b.Start(from), b.Start(to)
+	if b.Months > 0 {
+		return ((to.Year()-from.Year())*12+int(to.Month()-from.Month()))/b.Months + 1
+	}
+	days := int(to.Sub(from).Round(24*time.Hour) / (24 * time.Hour))
+	return days/b.Days + 1
+}
+
+type AnalyticsQuery struct {
+	From   time.Time
+	To     time.Time
+	Bucket string
+	// ServiceName limits the subscribes to a single service
+	ServiceName string
+	// ByService splits every bucket by service_name
+	ByService bool
+}
+
+type MRRPoint struct {
+	Bucket      time.Time `json:"bucket"`
+	ServiceName string    `json:"service_name,omitempty"`
+	MRR         int64     `json:"mrr"`
+	Subscribes  int64     `json:"subscribes"`
+}
+
+type MovementPoint struct {
+	Bucket      time.Time `json:"bucket"`
+	ServiceName string    `json:"service_name,omitempty"`
+	New         int64     `json:"new"`
+	Churned     int64     `json:"churned"`
+	NewMRR      int64     `json:"new_mrr"`
+	ChurnedMRR  int64     `json:"churned_mrr"`
+	NetMRR      int64     `json:"net_mrr" gorm:"-"`
+}
+
+type RetentionPoint struct {
+	Bucket      time.Time `json:"bucket"`
+	ServiceName string    `json:"service_name,omitempty"`
+	StartMRR    int64     `json:"start_mrr"`
+	RetainedMRR int64     `json:"retained_mrr"`
+	// NRR is nil when there is no revenue at the start of the bucket
+	NRR *float64 `json:"nrr" gorm:"-"`
+}
@@ -0,0 +1,38 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAnalyticsBucketCount(t *testing.T) {
+	date := func(y int, m time.Month, d int) time.Time {
+		return time.Date(y, m, d, 12, 0, 0, 0, time.UTC)
+	}
+
+	t.Run("Month", func(t *testing.T) {
+		month := AnalyticsBuckets["month"]
+		assert.Equal(t, "1 month", month.Interval())
+		// the calendar months, not the 28 days ones
+		assert.Equal(t, 1, month.Count(date(2025, time.January, 1), date(2025, time.January, 31)))
+		assert.Equal(t, 2, month.Count(date(2025, time.January, 31), date(2025, time.February, 1)))
+		assert.Equal(t, 12, month.Count(date(2025, time.January, 1), date(2025, time.December, 31)))
+		assert.Equal(t, 1000, month.Count(date(2000, time.January, 1), date(2083, time.April, 1)))
+	})
+
+	t.Run("Week", func(t *testing.T) {
+		week := AnalyticsBuckets["week"]
+		assert.Equal(t, "7 day", week.Interval())
+		// 2025-01-05 is a Sunday, 2025-01-06 is a Monday
+		assert.Equal(t, date(2024, time.December, 30).Truncate(24*time.Hour), week.Start(date(2025, time.January, 5)))
+		assert.Equal(t, 2, week.Count(date(2025, time.January, 5), date(2025, time.January, 6)))
+	})
+
+	t.Run("Day", func(t *testing.T) {
+		day := AnalyticsBuckets["day"]
+		assert.Equal(t, 1, day.Count(date(2025, time.March, 1), date(2025, time.March, 1)))
+		assert.Equal(t, 366, day.Count(date(2024, time.January, 1), date(2024, time.December, 31)))
+	})
+}
@@ -0,0 +1,70 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	ScopeSubscribesRead  = "subscribes:read"
+	ScopeSubscribesWrite = "subscribes:write"
+	ScopeAdmin           = "admin"
+)
+
+var APIKeyScopes = []string{ScopeSubscribesRead, ScopeSubscribesWrite, ScopeAdmin}
+
+// APIKey stores only the SHA-256 hash of the key, the prefix of the key
+// is kept to recognize it in the list.
+type APIKey struct {
+	ID         uint `gorm:"primaryKey"`
+	Name       string
+	Prefix     string
+	Hash       string `gorm:"uniqueIndex"`
+	Scopes     string
+	CreatedAt  time.Time
+	LastUsedAt *time.Time
+	RevokedAt  *time.Time
+}
+
+func (k *APIKey) ScopeList() []string {
+	return strings.Split(k.Scopes, ",")
+}
+
+func (k *APIKey) ToDto() *APIKeyDto {
+	return &APIKeyDto{
+		ID:         k.ID,
+		Name:       k.Name,
+		Prefix:     k.Prefix,
+		Scopes:     k.ScopeList(),
+		CreatedAt:  k.CreatedAt,
+		LastUsedAt: k.LastUsedAt,
+		RevokedAt:  k.RevokedAt,
+	}
+}
+
+type APIKeyDto struct {
+	ID         uint       `json:"id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Key        string     `json:"key,omitempty"`
+	Scopes     []string   `json:"scopes"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+}
+
+func (k *APIKeyDto) Validate() error {
+	if strings.TrimSpace(k.Name) == "" || len(k.Scopes) == 0 {
+		return errors.New("The fields 'name' and 'scopes' are required")
+	}
+	for _, s := range k.Scopes {
+		if !slices.Contains(APIKeyScopes, s) {
+			return fmt.Errorf("Unknown scope '%s'. The 'scopes' can only contain the values '%s'",
+				s, strings.Join(APIKeyScopes, "', '"))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,29 @@
+package models
+
+import (
+	"errors"
+	"time"
+)
+
+var ErrIdempotencyClaimLost = errors.New("the idempotency key has been claimed by another request")
+
+// IdempotencyKey is the stored response of a POST request. The response
+// is empty while the first request is in progress.
+type IdempotencyKey struct {
+	Key         string `gorm:"primaryKey"`
+	Fingerprint string
+	StatusCode  int
+	ContentType string
+	Body        []byte
+	CreatedAt   time.Time `gorm:"index"`
+	// LockedUntil ends the claim of a request in progress, a claim left by a
+	// killed process is taken over by a retry after it
+	LockedUntil time.Time
+	// Owner is the random token of the request holding the claim, a request
+	// whose claim has been taken over cannot complete or release it
+	Owner string
+}
+
+func (k *IdempotencyKey) InProgress() bool {
+	return k.StatusCode == 0
+}
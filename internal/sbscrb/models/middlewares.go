@@ -17,6 +17,11 @@ func NewLoggingResponseWriter(w http.ResponseWriter) *loggingResponseWriter {
 	return &loggingResponseWriter{w, http.StatusOK, "OK"}
 }
 
+// Unwrap lets http.ResponseController reach the Flusher of the wrapped writer.
+func (lrw *loggingResponseWriter) Unwrap() http.ResponseWriter {
+	return lrw.ResponseWriter
+}
+
 func (lrw *loggingResponseWriter) WriteHeader(code int) {
 	lrw.StatusCode = code
 	lrw.StatusMessage = http.StatusText(code)
@@ -24,9 +29,17 @@ func (lrw *loggingResponseWriter) WriteHeader(code int) {
 }
 
 func (lrw *loggingResponseWriter) Write(b []byte) (int, error) {
+	// only error responses are rewritten
+	if lrw.StatusCode < http.StatusBadRequest {
+		return lrw.ResponseWriter.Write(b)
+	}
+
+	// the other error bodies, like the report of a failed import, are
+	// passed as they are
 	var errFullDto FullExceptionDto
-	if err := json.Unmarshal(b, &errFullDto); err != nil {
-		return 0, err
+	if err := json.Unmarshal(b, &errFullDto); err != nil ||
+		errFullDto.StatusCode == 0 && errFullDto.ErrorMessage == "" {
+		return lrw.ResponseWriter.Write(b)
 	}
 	if errFullDto.ErrorMessage != "" {
 		lrw.StatusMessage += ": " + errFullDto.ErrorMessage + ": " + errFullDto.FullErrorMessage
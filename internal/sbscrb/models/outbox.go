@@ -0,0 +1,51 @@
+package models
+
+import "time"
+
+// OutboxEvent is an event written in the transaction of its change. The ids
+// are taken before the commit, so a later id may commit first. TxID is the
+// writing transaction, the rows are relayed by (TxID, ID) once every older
+// transaction has finished.
+type OutboxEvent struct {
+	ID        uint   `gorm:"primaryKey"`
+	TxID      uint64 `gorm:"not null;default:(pg_current_xact_id()::text::bigint);index"`
+	EventID   string `gorm:"uniqueIndex"`
+	EventType string
+	Payload   string
+	CreatedAt time.Time `gorm:"index"`
+}
+
+// OutboxCursor is the progress of a publisher in the outbox. The publishers
+// move independently, so an outage of one does not hold back the others.
+type OutboxCursor struct {
+	Publisher string `gorm:"primaryKey"`
+	// LastTxID and LastID are of the last passed row
+	LastTxID uint64
+	LastID   uint
+	// Attempts, NextAttemptAt and LastError are of the next row
+	Attempts      int
+	NextAttemptAt time.Time
+	LastError     string
+	UpdatedAt     time.Time
+}
+
+// Pass moves the cursor past the row and resets its attempts.
+func (c *OutboxCursor) Pass(row *OutboxEvent) {
+	c.LastTxID = row.TxID
+	c.LastID = row.ID
+	c.Attempts = 0
+	c.NextAttemptAt = time.Time{}
+	c.LastError = ""
+}
+
+// OutboxDeadLetter is a row skipped by a publisher, because it could not be
+// parsed or its attempts were exhausted.
+type OutboxDeadLetter struct {
+	ID        uint   `gorm:"primaryKey"`
+	OutboxID  uint   `gorm:"index"`
+	EventID   string `gorm:"index"`
+	Publisher string
+	Attempts  int
+	LastError string
+	CreatedAt time.Time
+}
@@ -0,0 +1,10 @@
+package models
+
+import "time"
+
+type RateLimitBucket struct {
+	Key       string  `gorm:"primaryKey"`
+	Tokens    float64 `gorm:"type:double precision"`
+	Allowed   bool
+	UpdatedAt time.Time `gorm:"autoUpdateTime:false;index"`
+}
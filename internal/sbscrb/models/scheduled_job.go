@@ -0,0 +1,9 @@
+package models
+
+import "time"
+
+type ScheduledJob struct {
+	Name      string `gorm:"primaryKey"`
+	LastTick  time.Time
+	UpdatedAt time.Time
+}
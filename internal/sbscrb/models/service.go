@@ -0,0 +1,137 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+var ErrServiceNotFound = errors.New("The service is not found in the catalogue")
+
+type Service struct {
+	ID           uint   `gorm:"primaryKey"`
+	Name         string `gorm:"uniqueIndex"`
+	Category     string
+	DefaultPrice *int
+	Website      string
+	Aliases      []ServiceAlias `gorm:"constraint:OnDelete:CASCADE"`
+	CreatedAt    time.Time
+}
+
+// ServiceAlias is a normalized name that resolves to the service. The name
+// of the service is always one of its aliases.
+type ServiceAlias struct {
+	Alias     string `gorm:"primaryKey"`
+	ServiceID uint   `gorm:"index"`
+}
+
+func NormalizeServiceName(name string) string {
+	return strings.ToLower(strings.Join(strings.Fields(name), " "))
+}
+
+func (s *Service) ToDto() *ServiceDto {
+	aliases := []string{}
+	for _, a := range s.Aliases {
+		if a.Alias != NormalizeServiceName(s.Name) {
+			aliases = append(aliases, a.Alias)
+		}
+	}
+	return &ServiceDto{
+		ID:           s.ID,
+		Name:         s.Name,
+		Aliases:      aliases,
+		Category:     s.Category,
+		DefaultPrice: s.DefaultPrice,
+		Website:      s.Website,
+		CreatedAt:    s.CreatedAt,
+	}
+}
+
+type ServiceDto struct {
+	ID           uint      `json:"id"`
+	Name         string    `json:"name"`
+	Aliases      []string  `json:"aliases"`
+	Category     string    `json:"category,omitempty"`
+	DefaultPrice *int      `json:"default_price,omitempty"`
+	Website      string    `json:"website,omitempty"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+func (s *ServiceDto) Validate() error {
+	if strings.TrimSpace(s.Name) == "" {
+		return errors.New("The field 'name' is required")
+	}
+	if s.DefaultPrice != nil && *s.DefaultPrice < 0 {
+		return errors.New("The field 'default_price' must not be negative")
+	}
+	if s.Website != "" {
+		u, err := url.Parse(s.Website)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return errors.New("The field 'website' must be an absolute http or https URL")
+		}
+	}
+	return nil
+}
+
+func (s *ServiceDto) ToDatabase() *Service {
+	service := &Service{
+		ID:           s.ID,
+		Name:         strings.TrimSpace(s.Name),
+		Category:     s.Category,
+		DefaultPrice: s.DefaultPrice,
+		Website:      s.Website,
+	}
+	seen := map[string]bool{}
+	for _, name := range append([]string{s.Name}, s.Aliases...) {
+		alias := NormalizeServiceName(name)
+		if alias == "" || seen[alias] {
+			continue
+		}
+		seen[alias] = true
+		service.Aliases = append(service.Aliases, ServiceAlias{Alias: alias})
+	}
+	return service
+}
+
+type ServiceFinder interface {
+	FindByID(id uint) (*Service, error)
+	// FindByAlias returns ErrServiceNotFound if no service has the alias.
+	FindByAlias(name string) (*Service, error)
+}
+
+// ValidateService resolves the service of the subscribe by 'service_id' or
+// by 'service_name' and replaces the name with the name from the catalogue.
+// The default price of the service is used when 'price' is missing.
+func (s *SubscribeDto) ValidateService(services ServiceFinder) error {
+	var (
+		service *Service
+		err     error
+	)
+	switch {
+	case s.ServiceID != nil:
+		service, err = services.FindByID(*s.ServiceID)
+		if errors.Is(err, ErrServiceNotFound) {
+			return fmt.Errorf("%w: service_id = %d", err, *s.ServiceID)
+		}
+	case s.ServiceName != "":
+		service, err = services.FindByAlias(s.ServiceName)
+		if errors.Is(err, ErrServiceNotFound) {
+			return fmt.Errorf("%w: '%s'", err, s.ServiceName)
+		}
+	default:
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	s.ServiceID = &service.ID
+	s.ServiceName = service.Name
+	if s.Price == nil && service.DefaultPrice != nil {
+		price := *service.DefaultPrice
+		s.Price = &price
+	}
+	return nil
+}
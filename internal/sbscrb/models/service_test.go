@@ -0,0 +1,52 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type stubServiceFinder map[string]*Service
+
+func (f stubServiceFinder) FindByID(id uint) (*Service, error) {
+	for _, s := range f {
+		if s.ID == id {
+			return s, nil
+		}
+	}
+	return nil, ErrServiceNotFound
+}
+
+func (f stubServiceFinder) FindByAlias(name string) (*Service, error) {
+	if s, ok := f[NormalizeServiceName(name)]; ok {
+		return s, nil
+	}
+	return nil, ErrServiceNotFound
+}
+
+func TestSubscribeDtoValidateService(t *testing.T) {
+	price := 299
+	kinopoisk := &Service{ID: 3, Name: "Kinopoisk", DefaultPrice: &price}
+	finder := stubServiceFinder{"kinopoisk": kinopoisk, "кинопоиск": kinopoisk}
+
+	t.Run("ByAlias", func(t *testing.T) {
+		dto := &SubscribeDto{ServiceName: "КиноПоиск"}
+		assert.NoError(t, dto.ValidateService(finder))
+		assert.Equal(t, "Kinopoisk", dto.ServiceName)
+		assert.Equal(t, uint(3), *dto.ServiceID)
+		assert.Equal(t, 299, *dto.Price)
+	})
+
+	t.Run("ByID", func(t *testing.T) {
+		id, own := uint(3), 100
+		dto := &SubscribeDto{ServiceID: &id, Price: &own}
+		assert.NoError(t, dto.ValidateService(finder))
+		assert.Equal(t, "Kinopoisk", dto.ServiceName)
+		assert.Equal(t, 100, *dto.Price)
+	})
+
+	t.Run("NotFound", func(t *testing.T) {
+		dto := &SubscribeDto{ServiceName: "Netflix"}
+		assert.ErrorIs(t, dto.ValidateService(finder), ErrServiceNotFound)
+	})
+}
@@ -12,6 +12,18 @@ type Subscribe struct {
 	UserId      string
 	StartDate   time.Time
 	EndDate     *time.Time
+	ExpiredAt   *time.Time
+	ServiceID   *uint `gorm:"index"`
+	// UpdatedAt is set by gorm on every change, it backs the conditional GET
+	UpdatedAt time.Time `gorm:"not null;default:now()"`
+}
+
+// ResetExpiration clears the expiration of a subscribe whose end date has
+// been cleared or moved after now, the expiration worker sets it again.
+func (s *Subscribe) ResetExpiration(now time.Time) {
+	if s.EndDate == nil || s.EndDate.After(now) {
+		s.ExpiredAt = nil
+	}
 }
 
 func (s *Subscribe) ToDto() *SubscribeDto {
@@ -22,6 +34,8 @@ func (s *Subscribe) ToDto() *SubscribeDto {
 		UserId:      s.UserId,
 		StartDate:   s.StartDate,
 		EndDate:     s.EndDate,
+		ExpiredAt:   s.ExpiredAt,
+		ServiceID:   s.ServiceID,
 	}
 }
 
@@ -32,6 +46,8 @@ type SubscribeDto struct {
 	UserId      string     `json:"user_id"`
 	StartDate   time.Time  `json:"start_date"`
 	EndDate     *time.Time `json:"end_date,omitempty"`
+	ExpiredAt   *time.Time `json:"expired_at,omitempty"`
+	ServiceID   *uint      `json:"service_id,omitempty"`
 }
 
 func (s *SubscribeDto) Validate() error {
@@ -56,5 +72,6 @@ func (s *SubscribeDto) ToDatabase() *Subscribe {
 		UserId:      s.UserId,
 		StartDate:   s.StartDate,
 		EndDate:     s.EndDate,
+		ServiceID:   s.ServiceID,
 	}
 }
@@ -0,0 +1,28 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSubscribeResetExpiration(t *testing.T) {
+	now := time.Date(2025, time.August, 1, 0, 0, 0, 0, time.UTC)
+	past, future := now.AddDate(0, -1, 0), now.AddDate(0, 1, 0)
+
+	for name, tc := range map[string]struct {
+		endDate *time.Time
+		expired bool
+	}{
+		"Cleared":  {endDate: nil, expired: false},
+		"Extended": {endDate: &future, expired: false},
+		"Ended":    {endDate: &past, expired: true},
+	} {
+		t.Run(name, func(t *testing.T) {
+			s := &Subscribe{EndDate: tc.endDate, ExpiredAt: &past}
+			s.ResetExpiration(now)
+			assert.Equal(t, tc.expired, s.ExpiredAt != nil)
+		})
+	}
+}
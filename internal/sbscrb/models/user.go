@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+// UserSummary is the summary of the subscribes of a user, the monthly
+// total is the sum of the prices of the active subscribes.
+type UserSummary struct {
+	UserId        string     `json:"user_id"`
+	Subscribes    int64      `json:"subscribes"`
+	Active        int64      `json:"active"`
+	MonthlyTotal  int64      `json:"monthly_total"`
+	EarliestStart *time.Time `json:"earliest_start,omitempty"`
+}
@@ -0,0 +1,123 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+)
+
+var WebhookEventTypes = []string{
+	"subscribe.created",
+	"subscribe.updated",
+	"subscribe.deleted",
+	"subscribe.expired",
+}
+
+type Webhook struct {
+	ID         uint `gorm:"primaryKey"`
+	URL        string
+	Secret     string
+	EventTypes string
+	CreatedAt  time.Time
+}
+
+func (wh *Webhook) ToDto() *WebhookDto {
+	return &WebhookDto{
+		ID:         wh.ID,
+		URL:        wh.URL,
+		EventTypes: strings.Split(wh.EventTypes, ","),
+		CreatedAt:  wh.CreatedAt,
+	}
+}
+
+func (wh *Webhook) Accepts(eventType string) bool {
+	return slices.Contains(strings.Split(wh.EventTypes, ","), eventType)
+}
+
+type WebhookDto struct {
+	ID         uint      `json:"id"`
+	URL        string    `json:"url"`
+	Secret     string    `json:"secret,omitempty"`
+	EventTypes []string  `json:"event_types"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+func (wh *WebhookDto) Validate() error {
+	if wh.URL == "" || len(wh.EventTypes) == 0 {
+		return errors.New("The fields 'url' and 'event_types' are required")
+	}
+	u, err := url.Parse(wh.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return errors.New("The field 'url' must be an absolute http or https URL")
+	}
+	for _, t := range wh.EventTypes {
+		if !slices.Contains(WebhookEventTypes, t) {
+			return fmt.Errorf("Unknown event type '%s'. The 'event_types' can only contain the values '%s'",
+				t, strings.Join(WebhookEventTypes, "', '"))
+		}
+	}
+	return nil
+}
+
+func (wh *WebhookDto) ToDatabase() *Webhook {
+	return &Webhook{
+		ID:         wh.ID,
+		URL:        wh.URL,
+		Secret:     wh.Secret,
+		EventTypes: strings.Join(wh.EventTypes, ","),
+	}
+}
+
+const (
+	DeliveryPending   = "pending"
+	DeliverySucceeded = "succeeded"
+	DeliveryDead      = "dead"
+)
+
+type WebhookDelivery struct {
+	ID             uint   `gorm:"primaryKey"`
+	WebhookID      uint   `gorm:"uniqueIndex:idx_webhook_deliveries_event"`
+	EventID        string `gorm:"uniqueIndex:idx_webhook_deliveries_event"`
+	EventType      string
+	Payload        string
+	Status         string `gorm:"index"`
+	Attempts       int
+	NextAttemptAt  time.Time
+	LastStatusCode int
+	LastError      string
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+}
+
+func (d *WebhookDelivery) ToDto() *WebhookDeliveryDto {
+	return &WebhookDeliveryDto{
+		ID:             d.ID,
+		WebhookID:      d.WebhookID,
+		EventID:        d.EventID,
+		EventType:      d.EventType,
+		Status:         d.Status,
+		Attempts:       d.Attempts,
+		NextAttemptAt:  d.NextAttemptAt,
+		LastStatusCode: d.LastStatusCode,
+		LastError:      d.LastError,
+		CreatedAt:      d.CreatedAt,
+		UpdatedAt:      d.UpdatedAt,
+	}
+}
+
+type WebhookDeliveryDto struct {
+	ID             uint      `json:"id"`
+	WebhookID      uint      `json:"webhook_id"`
+	EventID        string    `json:"event_id"`
+	EventType      string    `json:"event_type"`
+	Status         string    `json:"status"`
+	Attempts       int       `json:"attempts"`
+	NextAttemptAt  time.Time `json:"next_attempt_at"`
+	LastStatusCode int       `json:"last_status_code,omitempty"`
+	LastError      string    `json:"last_error,omitempty"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
@@ -0,0 +1,228 @@
+package outbox
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pabloeclair/rest-subscription/internal/sbscrb/events"
+)
+
+// NATSPublisher publishes each event to the subject SubjectPrefix + event type
+// using the plain NATS text protocol. The event id is sent in the Nats-Msg-Id
+// header, so JetStream streams deduplicate the redelivered events. A failed
+// connection is dialed again by the next event.
+type NATSPublisher struct {
+	Addr          string
+	SubjectPrefix string
+	Timeout       time.Duration
+	// TLS upgrades the connection after the greeting of the server
+	TLS *tls.Config
+	// User and Password, or Token authenticate the connection
+	User     string
+	Password string
+	Token    string
+
+	mu   sync.Mutex
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+// NewNATSPublisher accepts "nats://[user:password@|token@]host[:port]",
+// "tls://..." or "host:port".
+func NewNATSPublisher(rawURL, subjectPrefix string) (*NATSPublisher, error) {
+	p := &NATSPublisher{
+		Addr:          rawURL,
+		SubjectPrefix: subjectPrefix,
+		Timeout:       5 * time.Second,
+	}
+	if strings.Contains(rawURL, "://") {
+		u, err := url.Parse(rawURL)
+		if err != nil {
+			return nil, err
+		}
+		switch u.Scheme {
+		case "nats":
+		case "tls":
+			p.TLS = &tls.Config{ServerName: u.Hostname(), MinVersion: tls.VersionTLS12}
+		default:
+			return nil, fmt.Errorf("unsupported NATS URL scheme '%s'", u.Scheme)
+		}
+		p.Addr = u.Host
+		if u.User != nil {
+			if password, ok := u.User.Password(); ok {
+				p.User, p.Password = u.User.Username(), password
+			} else {
+				p.Token = u.User.Username()
+			}
+		}
+	}
+	if _, _, err := net.SplitHostPort(p.Addr); err != nil {
+		p.Addr = net.JoinHostPort(p.Addr, "4222")
+	}
+	return p, nil
+}
+
+func (p *NATSPublisher) Publish(ctx context.Context, e events.Event) error {
+	payload, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.conn == nil {
+		if err := p.connect(ctx); err != nil {
+			return fmt.Errorf("nats publisher: %w", err)
+		}
+	}
+	if err := p.publish(ctx, p.SubjectPrefix+string(e.Type), e.ID, payload); err != nil {
+		p.conn.Close()
+		p.conn = nil
+		return fmt.Errorf("nats publisher: %w", err)
+	}
+	return nil
+}
+
+func (p *NATSPublisher) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.conn == nil {
+		return nil
+	}
+	err := p.conn.Close()
+	p.conn = nil
+	return err
+}
+
+// connect reads the greeting, upgrades the connection to TLS if it is
+// configured, and authenticates. The PING after CONNECT is answered after
+// the server has checked the credentials, so an -ERR comes before PONG.
+func (p *NATSPublisher) connect(ctx context.Context) error {
+	d := net.Dialer{Timeout: p.Timeout}
+	conn, err := d.DialContext(ctx, "tcp", p.Addr)
+	if err != nil {
+		return err
+	}
+	conn.SetDeadline(time.Now().Add(p.Timeout))
+	r := bufio.NewReader(conn)
+
+	line, err := r.ReadString('\n')
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	greeting, ok := strings.CutPrefix(strings.TrimSpace(line), "INFO ")
+	if !ok {
+		conn.Close()
+		return fmt.Errorf("unexpected greeting %q", strings.TrimSpace(line))
+	}
+	var info struct {
+		Headers     bool `json:"headers"`
+		TLSRequired bool `json:"tls_required"`
+	}
+	if err := json.Unmarshal([]byte(greeting), &info); err != nil {
+		conn.Close()
+		return fmt.Errorf("incorrect greeting: %w", err)
+	}
+	switch {
+	case !info.Headers:
+		conn.Close()
+		return errors.New("the server does not support the headers, NATS 2.2 or later is required")
+	case info.TLSRequired && p.TLS == nil:
+		conn.Close()
+		return errors.New("the server requires TLS, use a 'tls://' URL")
+	}
+	if p.TLS != nil {
+		tlsConn := tls.Client(conn, p.TLS)
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			conn.Close()
+			return err
+		}
+		conn, r = tlsConn, bufio.NewReader(tlsConn)
+	}
+
+	options, err := json.Marshal(struct {
+		Verbose     bool   `json:"verbose"`
+		Pedantic    bool   `json:"pedantic"`
+		TLSRequired bool   `json:"tls_required"`
+		Headers     bool   `json:"headers"`
+		Name        string `json:"name"`
+		User        string `json:"user,omitempty"`
+		Password    string `json:"pass,omitempty"`
+		Token       string `json:"auth_token,omitempty"`
+	}{
+		TLSRequired: p.TLS != nil,
+		Headers:     true,
+		Name:        "rest-subscription",
+		User:        p.User,
+		Password:    p.Password,
+		Token:       p.Token,
+	})
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	p.conn, p.r = conn, r
+	if _, err := io.WriteString(conn, "CONNECT "+string(options)+"\r\nPING\r\n"); err != nil {
+		conn.Close()
+		p.conn = nil
+		return err
+	}
+	if err := p.waitPong(); err != nil {
+		conn.Close()
+		p.conn = nil
+		return err
+	}
+	return nil
+}
+
+// publish sends the message followed by PING and waits for PONG, which the
+// server sends only after it has processed the message.
+func (p *NATSPublisher) publish(ctx context.Context, subject, msgId string, payload []byte) error {
+	deadline := time.Now().Add(p.Timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	p.conn.SetDeadline(deadline)
+
+	headers := "NATS/1.0\r\nNats-Msg-Id: " + msgId + "\r\n\r\n"
+	msg := fmt.Sprintf("HPUB %s %d %d\r\n%s%s\r\nPING\r\n",
+		subject, len(headers), len(headers)+len(payload), headers, payload)
+	if _, err := p.conn.Write([]byte(msg)); err != nil {
+		return err
+	}
+	return p.waitPong()
+}
+
+// waitPong reads the replies until PONG, the server's PINGs are answered
+// and its -ERR is returned.
+func (p *NATSPublisher) waitPong() error {
+	for {
+		line, err := p.r.ReadString('\n')
+		if err != nil {
+			return err
+		}
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "PONG":
+			return nil
+		case line == "PING":
+			if _, err := p.conn.Write([]byte("PONG\r\n")); err != nil {
+				return err
+			}
+		case strings.HasPrefix(line, "-ERR"):
+			return fmt.Errorf("server error: %s", strings.Trim(strings.TrimSpace(strings.TrimPrefix(line, "-ERR")), "'"))
+		}
+	}
+}
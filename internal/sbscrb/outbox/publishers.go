@@ -0,0 +1,66 @@
+package outbox
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/pabloeclair/rest-subscription/internal/sbscrb/events"
+)
+
+// EmitPublisher passes the events to the in-process handlers registered
+// with events.Listen, e.g. the webhook dispatcher.
+type EmitPublisher struct{}
+
+func (EmitPublisher) Publish(ctx context.Context, e events.Event) error {
+	return events.Emit(e)
+}
+
+type LogPublisher struct{}
+
+func (LogPublisher) Publish(ctx context.Context, e events.Event) error {
+	return events.LogHandler(e)
+}
+
+// HTTPPublisher posts each event as JSON to URL. The event id is sent in
+// the Idempotency-Key header for deduplication on the receiving side.
+type HTTPPublisher struct {
+	URL    string
+	Client *http.Client
+}
+
+func NewHTTPPublisher(url string) *HTTPPublisher {
+	return &HTTPPublisher{
+		URL:    url,
+		Client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (p *HTTPPublisher) Publish(ctx context.Context, e events.Event) error {
+	b, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.URL, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	req.Header.Set("Idempotency-Key", e.ID)
+
+	resp, err := p.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("http publisher: unexpected response status %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,124 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/pabloeclair/rest-subscription/internal/sbscrb/events"
+	"github.com/pabloeclair/rest-subscription/internal/sbscrb/models"
+	"github.com/pabloeclair/rest-subscription/internal/sbscrb/repositories"
+)
+
+type Publisher interface {
+	// Publish must be idempotent by e.ID, the relay publishes at least once.
+	Publish(ctx context.Context, e events.Event) error
+}
+
+// Relay publishes the outbox rows to one publisher in the order their
+// transactions were started, every publisher has its own relay and cursor.
+// A row waits for the older transactions to finish, so a long transaction
+// which writes to the database delays the events. Run it as a
+// single-runner scheduler job to keep that order across replicas.
+type Relay struct {
+	Repo      repositories.OutboxRepository
+	Name      string
+	Publisher Publisher
+
+	// a row is dead-lettered after MaxAttempts failed attempts
+	MaxAttempts int
+	// the delay before the n-th retry is BaseDelay * 2^(n-1), at most MaxDelay
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	BatchSize int
+	Now       func() time.Time
+}
+
+func NewRelay(repo repositories.OutboxRepository, name string, publisher Publisher) *Relay {
+	return &Relay{
+		Repo:        repo,
+		Name:        name,
+		Publisher:   publisher,
+		MaxAttempts: 20,
+		BaseDelay:   time.Second,
+		MaxDelay:    5 * time.Minute,
+		BatchSize:   100,
+		Now:         time.Now,
+	}
+}
+
+// Job matches scheduler.JobFunc. It stops at a failed row until the retry
+// is due, so a later event is never published before an earlier one. A row
+// that cannot be parsed or is out of attempts is dead-lettered and skipped.
+func (r *Relay) Job(ctx context.Context) error {
+	cursor, err := r.Repo.FindCursor(r.Name)
+	if err != nil {
+		return err
+	}
+	if r.Now().Before(cursor.NextAttemptAt) {
+		return nil
+	}
+	rows, err := r.Repo.FindAfter(cursor, r.BatchSize)
+	if err != nil {
+		return err
+	}
+
+	for _, row := range rows {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		var e events.Event
+		if err := json.Unmarshal([]byte(row.Payload), &e); err != nil {
+			// retrying does not fix the payload
+			cursor.Attempts++
+			cursor.LastError = err.Error()
+			if err := r.deadLetter(cursor, row); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if err := r.Publisher.Publish(ctx, e); err != nil {
+			// the shutdown is not an attempt
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			cursor.Attempts++
+			cursor.LastError = err.Error()
+			if cursor.Attempts < r.MaxAttempts {
+				cursor.NextAttemptAt = r.Now().Add(r.backoff(cursor.Attempts))
+				return errors.Join(err, r.Repo.SaveCursor(cursor))
+			}
+			if err := r.deadLetter(cursor, row); err != nil {
+				return err
+			}
+			continue
+		}
+
+		cursor.Pass(row)
+		if err := r.Repo.SaveCursor(cursor); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *Relay) deadLetter(cursor *models.OutboxCursor, row *models.OutboxEvent) error {
+	log.Println(models.RedString(fmt.Sprintf(
+		"ERROR: outbox: event %s is dead for the publisher '%s' after %d attempts: %s",
+		row.EventID, r.Name, cursor.Attempts, cursor.LastError,
+	)))
+	return r.Repo.DeadLetter(cursor, row)
+}
+
+func (r *Relay) backoff(attempts int) time.Duration {
+	delay := r.BaseDelay
+	for i := 1; i < attempts && delay < r.MaxDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, r.MaxDelay)
+}
@@ -0,0 +1,103 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Limit allows Requests per Period, with bursts of up to Requests.
+type Limit struct {
+	Requests int
+	Period   time.Duration
+}
+
+// ParseLimit reads a limit like "60/1m".
+func ParseLimit(s string) (Limit, error) {
+	n, period, ok := strings.Cut(strings.TrimSpace(s), "/")
+	if !ok {
+		return Limit{}, fmt.Errorf("incorrect limit '%s', expected e.g. '60/1m'", s)
+	}
+	requests, err := strconv.Atoi(n)
+	if err != nil || requests <= 0 {
+		return Limit{}, fmt.Errorf("incorrect number of requests in the limit '%s'", s)
+	}
+	d, err := time.ParseDuration(period)
+	if err != nil || d <= 0 {
+		return Limit{}, fmt.Errorf("incorrect period in the limit '%s'", s)
+	}
+	return Limit{Requests: requests, Period: d}, nil
+}
+
+func (l Limit) String() string {
+	return fmt.Sprintf("%d/%s", l.Requests, l.Period)
+}
+
+// rate is the number of tokens added per second
+func (l Limit) rate() float64 {
+	return float64(l.Requests) / l.Period.Seconds()
+}
+
+// Result is the state of the bucket after taking a token.
+type Result struct {
+	Allowed   bool
+	Remaining int
+	// Reset is the time until the bucket is full again
+	Reset time.Duration
+	// RetryAfter is the time until the next token, if not allowed
+	RetryAfter time.Duration
+}
+
+func result(l Limit, allowed bool, tokens float64) Result {
+	r := Result{
+		Allowed:   allowed,
+		Remaining: int(math.Max(0, math.Floor(tokens))),
+		Reset:     seconds((float64(l.Requests) - tokens) / l.rate()),
+	}
+	if !allowed {
+		r.RetryAfter = seconds((1 - tokens) / l.rate())
+	}
+	return r
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(math.Ceil(math.Max(0, s))) * time.Second
+}
+
+// Store keeps the token buckets by key.
+type Store interface {
+	Take(ctx context.Context, key string, limit Limit, now time.Time) (Result, error)
+}
+
+// Limiter takes the tokens of a client from the bucket of the route, if the
+// route has its own limit, or from the default bucket of the client.
+type Limiter struct {
+	Store   Store
+	Default Limit
+	// Routes are the limits by the route patterns of the mux
+	Routes map[string]Limit
+	Now    func() time.Time
+}
+
+// Take returns false as the last value if the request is not limited.
+func (l *Limiter) Take(ctx context.Context, client, pattern string) (Result, Limit, bool, error) {
+	limit, ok := l.Routes[pattern]
+	key := client + "|" + pattern
+	if !ok {
+		limit = l.Default
+		key = client
+	}
+	if limit.Requests == 0 {
+		return Result{}, limit, false, nil
+	}
+
+	now := time.Now()
+	if l.Now != nil {
+		now = l.Now()
+	}
+	res, err := l.Store.Take(ctx, key, limit, now)
+	return res, limit, true, err
+}
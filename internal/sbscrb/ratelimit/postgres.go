@@ -0,0 +1,48 @@
+package ratelimit
+
+import (
+	"context"
+	"time"
+
+	"github.com/pabloeclair/rest-subscription/internal/sbscrb/models"
+	"gorm.io/gorm"
+)
+
+// PgStore keeps the buckets in Postgres, so the limits are shared by the
+// replicas. A bucket is refilled and taken in a single upsert.
+type PgStore struct {
+	Db *gorm.DB
+}
+
+// the refilled tokens of the existing bucket
+const refilled = `LEAST(CAST(@capacity AS double precision),
+        b.tokens + GREATEST(EXTRACT(EPOCH FROM CAST(@now AS timestamptz) - b.updated_at), 0) * CAST(@rate AS double precision))`
+
+const takeQuery = `
+INSERT INTO rate_limit_buckets AS b (key, tokens, allowed, updated_at)
+VALUES (@key, CAST(@capacity AS double precision) - 1, true, CAST(@now AS timestamptz))
+ON CONFLICT (key) DO UPDATE SET
+    tokens = CASE WHEN ` + refilled + ` >= 1 THEN ` + refilled + ` - 1 ELSE ` + refilled + ` END,
+    allowed = ` + refilled + ` >= 1,
+    updated_at = GREATEST(CAST(@now AS timestamptz), b.updated_at)
+RETURNING tokens, allowed`
+
+func (s *PgStore) Take(ctx context.Context, key string, limit Limit, now time.Time) (Result, error) {
+	var bucket models.RateLimitBucket
+	err := s.Db.WithContext(ctx).Raw(takeQuery, map[string]any{
+		"key":      key,
+		"capacity": float64(limit.Requests),
+		"rate":     limit.rate(),
+		"now":      now,
+	}).Scan(&bucket).Error
+	if err != nil {
+		return Result{}, err
+	}
+	return result(limit, bucket.Allowed, bucket.Tokens), nil
+}
+
+// Cleanup deletes the buckets not used since before.
+func (s *PgStore) Cleanup(before time.Time) (int64, error) {
+	res := s.Db.Where("updated_at < ?", before).Delete(&models.RateLimitBucket{})
+	return res.RowsAffected, res.Error
+}
@@ -0,0 +1,103 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func TestParseLimit(t *testing.T) {
+	limit, err := ParseLimit("60/1m")
+	assert.NoError(t, err)
+	assert.Equal(t, Limit{Requests: 60, Period: time.Minute}, limit)
+
+	for _, s := range []string{"60", "0/1m", "60/0s", "x/1m", "60/m"} {
+		_, err := ParseLimit(s)
+		assert.Error(t, err, s)
+	}
+}
+
+func TestMemoryStoreTake(t *testing.T) {
+	store := NewMemoryStore()
+	limit := Limit{Requests: 3, Period: 3 * time.Second}
+	now := time.Date(2025, time.September, 1, 0, 0, 0, 0, time.UTC)
+	ctx := context.Background()
+
+	for i := 2; i >= 0; i-- {
+		res, err := store.Take(ctx, "client", limit, now)
+		assert.NoError(t, err)
+		assert.True(t, res.Allowed)
+		assert.Equal(t, i, res.Remaining)
+	}
+
+	res, err := store.Take(ctx, "client", limit, now)
+	assert.NoError(t, err)
+	assert.False(t, res.Allowed)
+	assert.Equal(t, time.Second, res.RetryAfter)
+	assert.Equal(t, 3*time.Second, res.Reset)
+
+	// other clients have their own buckets
+	res, _ = store.Take(ctx, "other", limit, now)
+	assert.True(t, res.Allowed)
+
+	// a token per second is refilled
+	res, _ = store.Take(ctx, "client", limit, now.Add(time.Second))
+	assert.True(t, res.Allowed)
+	assert.Equal(t, 0, res.Remaining)
+}
+
+func TestLimiterTake(t *testing.T) {
+	store := NewMemoryStore()
+	limiter := &Limiter{
+		Store:   store,
+		Default: Limit{Requests: 100, Period: time.Minute},
+		Routes:  map[string]Limit{"GET /api/v1/subscribe": {Requests: 1, Period: time.Minute}},
+	}
+	ctx := context.Background()
+
+	res, limit, limited, err := limiter.Take(ctx, "sub:user-1", "GET /api/v1/subscribe")
+	assert.NoError(t, err)
+	assert.True(t, limited && res.Allowed)
+	assert.Equal(t, 1, limit.Requests)
+
+	res, _, _, _ = limiter.Take(ctx, "sub:user-1", "GET /api/v1/subscribe")
+	assert.False(t, res.Allowed)
+
+	// the other routes share the default bucket
+	res, limit, _, _ = limiter.Take(ctx, "sub:user-1", "GET /api/v1/subscribes/{id}")
+	assert.True(t, res.Allowed)
+	assert.Equal(t, 99, res.Remaining)
+	assert.Equal(t, 100, limit.Requests)
+
+	limiter.Default = Limit{}
+	_, _, limited, _ = limiter.Take(ctx, "sub:user-1", "GET /api/v1/subscribes/{id}")
+	assert.False(t, limited)
+}
+
+func TestPgStoreTake(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	db, err := gorm.Open(postgres.New(postgres.Config{Conn: mockDB}), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	assert.NoError(t, err)
+	store := &PgStore{Db: db}
+	limit := Limit{Requests: 60, Period: time.Minute}
+	now := time.Date(2025, time.September, 1, 0, 0, 0, 0, time.UTC)
+
+	mock.ExpectQuery(`(?s)INSERT INTO rate_limit_buckets AS b .* ON CONFLICT \(key\) DO UPDATE SET .* RETURNING tokens, allowed`).
+		WillReturnRows(sqlmock.NewRows([]string{"tokens", "allowed"}).AddRow(0.5, false))
+
+	res, err := store.Take(context.Background(), "sub:user-1", limit, now)
+	assert.NoError(t, err)
+	assert.False(t, res.Allowed)
+	assert.Equal(t, 0, res.Remaining)
+	assert.Equal(t, time.Second, res.RetryAfter)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
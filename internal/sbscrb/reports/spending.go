@@ -0,0 +1,136 @@
+package reports
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/pabloeclair/rest-subscription/internal/sbscrb/models"
+)
+
+// Month is the first day of a month in UTC.
+type Month time.Time
+
+func ParseMonth(s string) (Month, error) {
+	t, err := time.Parse("2006-01", s)
+	if err != nil {
+		return Month{}, fmt.Errorf("incorrect month '%s'. Please use the format 'YYYY-MM'", s)
+	}
+	return Month(t), nil
+}
+
+func MonthOf(t time.Time) Month {
+	return Month(time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC))
+}
+
+func (m Month) AddMonths(n int) Month {
+	return Month(time.Time(m).AddDate(0, n, 0))
+}
+
+func (m Month) Before(other Month) bool {
+	return time.Time(m).Before(time.Time(other))
+}
+
+// MonthsUntil returns the number of months from m to other, both inclusive.
+func (m Month) MonthsUntil(other Month) int {
+	a, b := time.Time(m), time.Time(other)
+	return (b.Year()-a.Year())*12 + int(b.Month()-a.Month()) + 1
+}
+
+func (m Month) String() string {
+	return time.Time(m).Format("2006-01")
+}
+
+func (m Month) MarshalJSON() ([]byte, error) {
+	return []byte(`"` + m.String() + `"`), nil
+}
+
+type ServiceSpending struct {
+	ServiceName string `json:"service_name"`
+	Amount      int    `json:"amount"`
+	Subscribes  int    `json:"subscribes"`
+}
+
+type MonthSpending struct {
+	Month    Month             `json:"month"`
+	Total    int               `json:"total"`
+	Services []ServiceSpending `json:"services"`
+}
+
+type SpendingReport struct {
+	UserId   string            `json:"user_id"`
+	From     Month             `json:"from"`
+	To       Month             `json:"to"`
+	Total    int               `json:"total"`
+	Services []ServiceSpending `json:"services"`
+	Months   []MonthSpending   `json:"months"`
+}
+
+// Spending charges the price of a subscribe for every month from the month
+// of its start date to the month of its end date, both inclusive. Subscribes
+// without an end date are charged up to the end of the report.
+func Spending(userId string, subscribes []*models.Subscribe, from, to Month) *SpendingReport {
+	report := &SpendingReport{
+		UserId:   userId,
+		From:     from,
+		To:       to,
+		Services: []ServiceSpending{},
+		Months:   make([]MonthSpending, 0, from.MonthsUntil(to)),
+	}
+	totals := map[string]*ServiceSpending{}
+
+	for m := from; !to.Before(m); m = m.AddMonths(1) {
+		month := MonthSpending{Month: m, Services: []ServiceSpending{}}
+		byService := map[string]int{}
+
+		for _, s := range subscribes {
+			if !activeIn(s, m) {
+				continue
+			}
+			i, ok := byService[s.ServiceName]
+			if !ok {
+				i = len(month.Services)
+				byService[s.ServiceName] = i
+				month.Services = append(month.Services, ServiceSpending{ServiceName: s.ServiceName})
+			}
+			month.Services[i].Amount += s.Price
+			month.Services[i].Subscribes++
+			month.Total += s.Price
+
+			if _, ok := totals[s.ServiceName]; !ok {
+				totals[s.ServiceName] = &ServiceSpending{ServiceName: s.ServiceName}
+			}
+			totals[s.ServiceName].Amount += s.Price
+		}
+
+		sort.Slice(month.Services, func(i, k int) bool {
+			return month.Services[i].ServiceName < month.Services[k].ServiceName
+		})
+		report.Total += month.Total
+		report.Months = append(report.Months, month)
+	}
+
+	for _, total := range totals {
+		for _, s := range subscribes {
+			if s.ServiceName == total.ServiceName && overlaps(s, from, to) {
+				total.Subscribes++
+			}
+		}
+		report.Services = append(report.Services, *total)
+	}
+	sort.Slice(report.Services, func(i, k int) bool {
+		return report.Services[i].ServiceName < report.Services[k].ServiceName
+	})
+	return report
+}
+
+func activeIn(s *models.Subscribe, m Month) bool {
+	return overlaps(s, m, m)
+}
+
+func overlaps(s *models.Subscribe, from, to Month) bool {
+	if to.Before(MonthOf(s.StartDate)) {
+		return false
+	}
+	return s.EndDate == nil || !MonthOf(*s.EndDate).Before(from)
+}
@@ -0,0 +1,58 @@
+package reports
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pabloeclair/rest-subscription/internal/sbscrb/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseMonth(t *testing.T) {
+	m, err := ParseMonth("2025-07")
+	assert.NoError(t, err)
+	assert.Equal(t, "2025-07", m.String())
+	assert.Equal(t, 12, m.MonthsUntil(m.AddMonths(11)))
+
+	_, err = ParseMonth("07-2025")
+	assert.Error(t, err)
+}
+
+func TestSpending(t *testing.T) {
+	endDate := time.Date(2025, time.March, 10, 0, 0, 0, 0, time.UTC)
+	subscribes := []*models.Subscribe{
+		{
+			ServiceName: "Kinopoisk",
+			Price:       399,
+			StartDate:   time.Date(2025, time.January, 15, 0, 0, 0, 0, time.UTC),
+			EndDate:     &endDate,
+		},
+		{
+			ServiceName: "Yandex Plus",
+			Price:       199,
+			StartDate:   time.Date(2025, time.February, 1, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			ServiceName: "Okko",
+			Price:       299,
+			StartDate:   time.Date(2025, time.June, 1, 0, 0, 0, 0, time.UTC),
+		},
+	}
+	from, _ := ParseMonth("2025-01")
+	to, _ := ParseMonth("2025-04")
+
+	report := Spending("6061fee-2bf1-aef6f-763675gre", subscribes, from, to)
+	assert.Len(t, report.Months, 4)
+
+	assert.Equal(t, 399, report.Months[0].Total)
+	assert.Equal(t, []ServiceSpending{{"Kinopoisk", 399, 1}}, report.Months[0].Services)
+	assert.Equal(t, 598, report.Months[1].Total)
+	assert.Equal(t, 598, report.Months[2].Total)
+	assert.Equal(t, []ServiceSpending{{"Yandex Plus", 199, 1}}, report.Months[3].Services)
+
+	assert.Equal(t, 399*3+199*3, report.Total)
+	assert.Equal(t, []ServiceSpending{
+		{"Kinopoisk", 399 * 3, 1},
+		{"Yandex Plus", 199 * 3, 1},
+	}, report.Services)
+}
@@ -0,0 +1,163 @@
+package repositories
+
+import (
+	"fmt"
+
+	"github.com/pabloeclair/rest-subscription/internal/sbscrb/models"
+	"gorm.io/gorm"
+)
+
+type AnalyticsRepository interface {
+	MRR(q models.AnalyticsQuery) ([]*models.MRRPoint, error)
+	Movements(q models.AnalyticsQuery) ([]*models.MovementPoint, error)
+	Retention(q models.AnalyticsQuery) ([]*models.RetentionPoint, error)
+}
+
+type GormAnalyticsRepository struct {
+	Db *gorm.DB
+}
+
+// A subscribe is active at the moment t when it has started before t and
+// has not ended by t. The revenue of a bucket is counted at the bucket end.
+const (
+	analyticsBuckets = `WITH buckets AS (
+		SELECT bucket, bucket + CAST(@step AS interval) AS bucket_end
+		FROM generate_series(
+			date_trunc(@unit, CAST(@from AS timestamptz)),
+			date_trunc(@unit, CAST(@to AS timestamptz)),
+			CAST(@step AS interval)
+		) AS bucket
+	)`
+	analyticsServiceFilter = `(@service_name = '' OR s.service_name = @service_name)`
+)
+
+func analyticsArgs(q models.AnalyticsQuery) (map[string]any, error) {
+	step, ok := models.AnalyticsBuckets[q.Bucket]
+	if !ok {
+		return nil, fmt.Errorf("unknown bucket '%s'", q.Bucket)
+	}
+	return map[string]any{
+		"unit":         q.Bucket,
+		"step":         step.Interval(),
+		"from":         q.From,
+		"to":           q.To,
+		"service_name": q.ServiceName,
+	}, nil
+}
+
+// analyticsGroup returns the service_name column and the GROUP BY clause.
+func analyticsGroup(q models.AnalyticsQuery) (string, string) {
+	if q.ByService {
+		return "s.service_name", "GROUP BY b.bucket, s.service_name HAVING s.service_name IS NOT NULL"
+	}
+	return "''", "GROUP BY b.bucket"
+}
+
+func (r *GormAnalyticsRepository) MRR(q models.AnalyticsQuery) ([]*models.MRRPoint, error) {
+	args, err := analyticsArgs(q)
+	if err != nil {
+		return nil, err
+	}
+	column, group := analyticsGroup(q)
+
+	points := []*models.MRRPoint{}
+	err = r.Db.Raw(analyticsBuckets+`
+		SELECT b.bucket, `+column+` AS service_name,
+			COALESCE(SUM(s.price), 0) AS mrr, COUNT(s.id) AS subscribes
+		FROM buckets b
+		LEFT JOIN subscribes s ON s.start_date < b.bucket_end
+			AND (s.end_date IS NULL OR s.end_date >= b.bucket_end)
+			AND `+analyticsServiceFilter+`
+		`+group+`
+		ORDER BY 1, 2`, args).Scan(&points).Error
+	if err != nil {
+		return nil, err
+	}
+	return points, nil
+}
+
+func (r *GormAnalyticsRepository) Movements(q models.AnalyticsQuery) ([]*models.MovementPoint, error) {
+	args, err := analyticsArgs(q)
+	if err != nil {
+		return nil, err
+	}
+	column, group := analyticsGroup(q)
+
+	points := []*models.MovementPoint{}
+	err = r.Db.Raw(analyticsBuckets+`
+		SELECT b.bucket, `+column+` AS service_name,
+			COUNT(s.id) FILTER (WHERE s.start_date >= b.bucket) AS new,
+			COUNT(s.id) FILTER (WHERE s.end_date < b.bucket_end) AS churned,
+			COALESCE(SUM(s.price) FILTER (WHERE s.start_date >= b.bucket), 0) AS new_mrr,
+			COALESCE(SUM(s.price) FILTER (WHERE s.end_date < b.bucket_end), 0) AS churned_mrr
+		FROM buckets b
+		LEFT JOIN subscribes s ON (
+				(s.start_date >= b.bucket AND s.start_date < b.bucket_end)
+				OR (s.end_date >= b.bucket AND s.end_date < b.bucket_end)
+			)
+			AND `+analyticsServiceFilter+`
+		`+group+`
+		ORDER BY 1, 2`, args).Scan(&points).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range points {
+		p.NetMRR = p.NewMRR - p.ChurnedMRR
+	}
+	return points, nil
+}
+
+// Retention compares the revenue of the users active at the start of a
+// bucket with the revenue of the same users at its end, so expansion counts
+// and revenue of new users does not. The cohort of a bucket is collected
+// once from the subscribes overlapping it and joined by the user.
+func (r *GormAnalyticsRepository) Retention(q models.AnalyticsQuery) ([]*models.RetentionPoint, error) {
+	args, err := analyticsArgs(q)
+	if err != nil {
+		return nil, err
+	}
+	service, keys := "''", `SELECT bucket, '' AS service_name FROM buckets`
+	if q.ByService {
+		service = "service_name"
+		keys = `SELECT b.bucket, n.service_name FROM buckets b
+			CROSS JOIN (SELECT DISTINCT s.service_name FROM subscribes s
+				WHERE s.service_name IS NOT NULL AND ` + analyticsServiceFilter + `) n`
+	}
+
+	points := []*models.RetentionPoint{}
+	err = r.Db.Raw(analyticsBuckets+`,
+	active AS (
+		SELECT b.bucket, s.user_id, `+service+` AS service_name, s.price,
+			s.start_date < b.bucket AND (s.end_date IS NULL OR s.end_date >= b.bucket) AS at_start,
+			s.start_date < b.bucket_end AND (s.end_date IS NULL OR s.end_date >= b.bucket_end) AS at_end
+		FROM buckets b
+		JOIN subscribes s ON s.start_date < b.bucket_end
+			AND (s.end_date IS NULL OR s.end_date >= b.bucket)
+			AND `+analyticsServiceFilter+`
+	),
+	cohort AS (
+		SELECT DISTINCT bucket, user_id, service_name FROM active WHERE at_start
+	),
+	keys AS (
+		`+keys+`
+	)
+		SELECT k.bucket, k.service_name,
+			COALESCE(SUM(a.price) FILTER (WHERE a.at_start), 0) AS start_mrr,
+			COALESCE(SUM(a.price) FILTER (WHERE a.at_end AND c.user_id IS NOT NULL), 0) AS retained_mrr
+		FROM keys k
+		LEFT JOIN active a ON a.bucket = k.bucket AND a.service_name = k.service_name
+		LEFT JOIN cohort c ON c.bucket = a.bucket AND c.user_id = a.user_id
+			AND c.service_name = a.service_name
+		GROUP BY k.bucket, k.service_name
+		ORDER BY 1, 2`, args).Scan(&points).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range points {
+		if p.StartMRR > 0 {
+			nrr := float64(p.RetainedMRR) / float64(p.StartMRR)
+			p.NRR = &nrr
+		}
+	}
+	return points, nil
+}
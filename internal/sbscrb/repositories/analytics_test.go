@@ -0,0 +1,87 @@
+package repositories
+
+import (
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/pabloeclair/rest-subscription/internal/sbscrb/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAnalyticsMRR(t *testing.T) {
+	db, mock, err := NewMock()
+	assert.NoError(t, err)
+	repo := GormAnalyticsRepository{Db: db}
+	q := models.AnalyticsQuery{
+		From:   time.Date(2025, time.January, 1, 0, 0, 0, 0, time.UTC),
+		To:     time.Date(2025, time.February, 1, 0, 0, 0, 0, time.UTC),
+		Bucket: "month",
+	}
+
+	mock.ExpectQuery(`(?s)WITH buckets AS .* generate_series.* SELECT b.bucket, '' AS service_name.* GROUP BY b.bucket\s+ORDER BY 1, 2`).
+		WithArgs("1 month", "month", q.From, "month", q.To, "1 month", "", "").
+		WillReturnRows(sqlmock.NewRows([]string{"bucket", "service_name", "mrr", "subscribes"}).
+			AddRow(q.From, "", 598, 2).
+			AddRow(q.To, "", 399, 1))
+
+	points, err := repo.MRR(q)
+	assert.NoError(t, err)
+	assert.Equal(t, []*models.MRRPoint{
+		{Bucket: q.From, MRR: 598, Subscribes: 2},
+		{Bucket: q.To, MRR: 399, Subscribes: 1},
+	}, points)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestAnalyticsMovements(t *testing.T) {
+	db, mock, err := NewMock()
+	assert.NoError(t, err)
+	repo := GormAnalyticsRepository{Db: db}
+	bucket := time.Date(2025, time.January, 1, 0, 0, 0, 0, time.UTC)
+	q := models.AnalyticsQuery{From: bucket, To: bucket, Bucket: "week", ByService: true}
+
+	mock.ExpectQuery(`(?s)SELECT b.bucket, s.service_name AS service_name.* GROUP BY b.bucket, s.service_name HAVING s.service_name IS NOT NULL`).
+		WillReturnRows(sqlmock.NewRows([]string{"bucket", "service_name", "new", "churned", "new_mrr", "churned_mrr"}).
+			AddRow(bucket, "Kinopoisk", 3, 1, 1197, 399))
+
+	points, err := repo.Movements(q)
+	assert.NoError(t, err)
+	assert.Len(t, points, 1)
+	assert.Equal(t, "Kinopoisk", points[0].ServiceName)
+	assert.Equal(t, int64(798), points[0].NetMRR)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestAnalyticsRetention(t *testing.T) {
+	db, mock, err := NewMock()
+	assert.NoError(t, err)
+	repo := GormAnalyticsRepository{Db: db}
+	q := models.AnalyticsQuery{
+		From:   time.Date(2025, time.January, 1, 0, 0, 0, 0, time.UTC),
+		To:     time.Date(2025, time.February, 1, 0, 0, 0, 0, time.UTC),
+		Bucket: "month",
+	}
+
+	mock.ExpectQuery(`(?s)cohort AS \(\s*SELECT DISTINCT bucket, user_id, service_name FROM active WHERE at_start.*` +
+		`AS start_mrr.* AS retained_mrr.*LEFT JOIN cohort c ON c.bucket = a.bucket AND c.user_id = a.user_id`).
+		WillReturnRows(sqlmock.NewRows([]string{"bucket", "service_name", "start_mrr", "retained_mrr"}).
+			AddRow(q.From, "", 0, 0).
+			AddRow(q.To, "", 1000, 900))
+
+	points, err := repo.Retention(q)
+	assert.NoError(t, err)
+	assert.Len(t, points, 2)
+	assert.Nil(t, points[0].NRR)
+	assert.InDelta(t, 0.9, *points[1].NRR, 1e-9)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestAnalyticsUnknownBucket(t *testing.T) {
+	db, _, err := NewMock()
+	assert.NoError(t, err)
+	repo := GormAnalyticsRepository{Db: db}
+
+	_, err = repo.MRR(models.AnalyticsQuery{Bucket: "year"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,61 @@
+package repositories
+
+import (
+	"time"
+
+	"github.com/pabloeclair/rest-subscription/internal/sbscrb/models"
+	"gorm.io/gorm"
+)
+
+type APIKeyRepository interface {
+	Create(key *models.APIKey) error
+	FindAll() ([]*models.APIKey, error)
+	FindByHash(hash string) (*models.APIKey, error)
+	Revoke(id uint, at time.Time) error
+	// Touch records the usage of the key, the timestamp is written at
+	// most once per interval to spare the database on busy keys
+	Touch(id uint, at time.Time, interval time.Duration) error
+}
+
+type GormAPIKeyRepository struct {
+	Db *gorm.DB
+}
+
+func (r *GormAPIKeyRepository) Create(key *models.APIKey) error {
+	return r.Db.Create(key).Error
+}
+
+func (r *GormAPIKeyRepository) FindAll() ([]*models.APIKey, error) {
+	keys := []*models.APIKey{}
+	if err := r.Db.Order("id").Find(&keys).Error; err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+func (r *GormAPIKeyRepository) FindByHash(hash string) (*models.APIKey, error) {
+	key := &models.APIKey{}
+	if err := r.Db.Where("hash = ?", hash).First(key).Error; err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+func (r *GormAPIKeyRepository) Revoke(id uint, at time.Time) error {
+	res := r.Db.Model(&models.APIKey{}).
+		Where("id = ? AND revoked_at IS NULL", id).
+		Update("revoked_at", at)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (r *GormAPIKeyRepository) Touch(id uint, at time.Time, interval time.Duration) error {
+	return r.Db.Model(&models.APIKey{}).
+		Where("id = ? AND (last_used_at IS NULL OR last_used_at < ?)", id, at.Add(-interval)).
+		Update("last_used_at", at).Error
+}
@@ -0,0 +1,60 @@
+package repositories
+
+import (
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func TestAPIKeyRevoke(t *testing.T) {
+	at := time.Date(2025, time.September, 1, 0, 0, 0, 0, time.UTC)
+
+	t.Run("Success", func(t *testing.T) {
+		db, mock, err := NewMock()
+		assert.NoError(t, err)
+		repo := GormAPIKeyRepository{Db: db}
+
+		mock.ExpectBegin()
+		mock.ExpectExec(`UPDATE "api_keys" SET "revoked_at"=\$1 WHERE id = \$2 AND revoked_at IS NULL`).
+			WithArgs(at, 1).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		assert.NoError(t, repo.Revoke(1, at))
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("ErrRecordNotFound", func(t *testing.T) {
+		db, mock, err := NewMock()
+		assert.NoError(t, err)
+		repo := GormAPIKeyRepository{Db: db}
+
+		mock.ExpectBegin()
+		mock.ExpectExec(`UPDATE "api_keys" SET "revoked_at"=\$1 WHERE id = \$2 AND revoked_at IS NULL`).
+			WithArgs(at, 1).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectCommit()
+
+		assert.Equal(t, gorm.ErrRecordNotFound, repo.Revoke(1, at))
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestAPIKeyTouch(t *testing.T) {
+	db, mock, err := NewMock()
+	assert.NoError(t, err)
+	repo := GormAPIKeyRepository{Db: db}
+	at := time.Date(2025, time.September, 1, 0, 0, 0, 0, time.UTC)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE "api_keys" SET "last_used_at"=\$1 WHERE id = \$2 AND \(last_used_at IS NULL OR last_used_at < \$3\)`).
+		WithArgs(at, 1, at.Add(-time.Minute)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	assert.NoError(t, repo.Touch(1, at, time.Minute))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,148 @@
+package repositories
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/pabloeclair/rest-subscription/internal/sbscrb/cache"
+	"github.com/pabloeclair/rest-subscription/internal/sbscrb/models"
+)
+
+// SubscribeTombstoneTTL is how long a changed subscribe is not cached.
+const SubscribeTombstoneTTL = 5 * time.Second
+
+// subscribeTombstone replaces the cached subscribe before and after a change.
+var subscribeTombstone = []byte("tombstone")
+
+// CachedSubscribeRepository reads the subscribes by id through the cache.
+// Before and after the changes the cached subscribes are replaced by the
+// tombstones, and a read adds the subscribe only if its key is not set, so
+// a read which has loaded the old row during the change does not cache it.
+// The other methods go straight to the wrapped repository. If the store
+// fails, the subscribe is read from the wrapped repository.
+//
+// A read which loaded the old row and adds it later than
+// SubscribeTombstoneTTL after the change caches it until the TTL. The
+// changes made around the repository, like renaming a service of the
+// catalogue, are seen after the TTL too.
+type CachedSubscribeRepository struct {
+	SubscribeRepository
+	Store   cache.Store
+	TTL     time.Duration
+	Metrics *cache.Metrics
+	// Ctx is the context of the request, it bounds the calls of the store
+	Ctx context.Context
+}
+
+func subscribeCacheKey(id uint) string {
+	return "subscribe:" + strconv.FormatUint(uint64(id), 10)
+}
+
+func (r *CachedSubscribeRepository) ctx() context.Context {
+	if r.Ctx == nil {
+		return context.Background()
+	}
+	return r.Ctx
+}
+
+func (r *CachedSubscribeRepository) FindByID(id uint) (*models.Subscribe, error) {
+	ctx := r.ctx()
+	key := subscribeCacheKey(id)
+
+	b, ok, err := r.Store.Get(ctx, key)
+	if err != nil {
+		r.storeFailed(err)
+	}
+	if ok && !bytes.Equal(b, subscribeTombstone) {
+		var subscribe models.Subscribe
+		if err := json.Unmarshal(b, &subscribe); err == nil {
+			r.Metrics.Hits.Add(1)
+			return &subscribe, nil
+		}
+	}
+
+	r.Metrics.Misses.Add(1)
+	subscribe, err := r.SubscribeRepository.FindByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if b, err := json.Marshal(subscribe); err == nil {
+		if _, err := r.Store.Add(ctx, key, b, r.TTL); err != nil {
+			r.storeFailed(err)
+		}
+	}
+	return subscribe, nil
+}
+
+func (r *CachedSubscribeRepository) Update(id uint, subscribe *models.Subscribe) error {
+	r.invalidate(id)
+	err := r.SubscribeRepository.Update(id, subscribe)
+	if err == nil {
+		r.invalidate(id)
+	}
+	return err
+}
+
+func (r *CachedSubscribeRepository) Delete(id uint) error {
+	r.invalidate(id)
+	err := r.SubscribeRepository.Delete(id)
+	if err == nil {
+		r.invalidate(id)
+	}
+	return err
+}
+
+// DeleteByUserId reads the ids of the user before the deletion to drop
+// them from the cache.
+func (r *CachedSubscribeRepository) DeleteByUserId(userId string) (int64, error) {
+	subscribes, err := r.SubscribeRepository.FindByUserId(userId)
+	if err != nil {
+		return 0, err
+	}
+	ids := make([]uint, 0, len(subscribes))
+	for _, subscribe := range subscribes {
+		ids = append(ids, subscribe.ID)
+	}
+	r.invalidate(ids...)
+	deleted, err := r.SubscribeRepository.DeleteByUserId(userId)
+	if err == nil {
+		r.invalidate(ids...)
+	}
+	return deleted, err
+}
+
+// MarkExpired learns the ids only from the update, so they are dropped from
+// the cache after it.
+func (r *CachedSubscribeRepository) MarkExpired(now time.Time) ([]*models.Subscribe, error) {
+	subscribes, err := r.SubscribeRepository.MarkExpired(now)
+	if err == nil {
+		ids := make([]uint, 0, len(subscribes))
+		for _, subscribe := range subscribes {
+			ids = append(ids, subscribe.ID)
+		}
+		r.invalidate(ids...)
+	}
+	return subscribes, err
+}
+
+func (r *CachedSubscribeRepository) invalidate(ids ...uint) {
+	if len(ids) == 0 {
+		return
+	}
+	ctx := r.ctx()
+	for _, id := range ids {
+		if err := r.Store.Set(ctx, subscribeCacheKey(id), subscribeTombstone, SubscribeTombstoneTTL); err != nil {
+			r.storeFailed(err)
+			return
+		}
+	}
+}
+
+func (r *CachedSubscribeRepository) storeFailed(err error) {
+	r.Metrics.Errors.Add(1)
+	log.Println(models.RedString("ERROR: subscribe cache: ", err.Error()))
+}
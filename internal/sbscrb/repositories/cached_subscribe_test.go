@@ -0,0 +1,246 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/pabloeclair/rest-subscription/internal/sbscrb/cache"
+	"github.com/pabloeclair/rest-subscription/internal/sbscrb/models"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+// memoryRepository counts the reads of the subscribes by id.
+type memoryRepository struct {
+	SubscribeRepository
+	subscribes map[uint]*models.Subscribe
+	reads      int
+	// duringUpdate runs before the change is made
+	duringUpdate func()
+	// afterRead runs after the subscribe is read
+	afterRead func()
+}
+
+func (r *memoryRepository) FindByID(id uint) (*models.Subscribe, error) {
+	r.reads++
+	subscribe, ok := r.subscribes[id]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	copied := *subscribe
+	if afterRead := r.afterRead; afterRead != nil {
+		r.afterRead = nil
+		afterRead()
+	}
+	return &copied, nil
+}
+
+func (r *memoryRepository) FindByUserId(userId string) ([]*models.Subscribe, error) {
+	var subscribes []*models.Subscribe
+	for _, subscribe := range r.subscribes {
+		if subscribe.UserId == userId {
+			subscribes = append(subscribes, subscribe)
+		}
+	}
+	return subscribes, nil
+}
+
+func (r *memoryRepository) Update(id uint, subscribe *models.Subscribe) error {
+	if r.duringUpdate != nil {
+		r.duringUpdate()
+	}
+	r.subscribes[id].Price = subscribe.Price
+	return nil
+}
+
+func (r *memoryRepository) Delete(id uint) error {
+	delete(r.subscribes, id)
+	return nil
+}
+
+func (r *memoryRepository) DeleteByUserId(userId string) (int64, error) {
+	var deleted int64
+	for id, subscribe := range r.subscribes {
+		if subscribe.UserId == userId {
+			delete(r.subscribes, id)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+// failingStore fails every operation.
+type failingStore struct{}
+
+func (failingStore) Get(context.Context, string) ([]byte, bool, error) {
+	return nil, false, errors.New("connection refused")
+}
+
+func (failingStore) Set(context.Context, string, []byte, time.Duration) error {
+	return errors.New("connection refused")
+}
+
+func (failingStore) Add(context.Context, string, []byte, time.Duration) (bool, error) {
+	return false, errors.New("connection refused")
+}
+
+func (failingStore) Delete(context.Context, ...string) error {
+	return errors.New("connection refused")
+}
+
+type ctxKey struct{}
+
+// contextStore checks that the calls get the context of the request.
+type contextStore struct {
+	cache.Store
+	t *testing.T
+}
+
+func (s contextStore) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	assert.Equal(s.t, "request", ctx.Value(ctxKey{}))
+	return s.Store.Get(ctx, key)
+}
+
+func (s contextStore) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	assert.Equal(s.t, "request", ctx.Value(ctxKey{}))
+	return s.Store.Set(ctx, key, value, ttl)
+}
+
+func (s contextStore) Add(ctx context.Context, key string, value []byte, ttl time.Duration) (bool, error) {
+	assert.Equal(s.t, "request", ctx.Value(ctxKey{}))
+	return s.Store.Add(ctx, key, value, ttl)
+}
+
+func newCachedRepository(store cache.Store) (*CachedSubscribeRepository, *memoryRepository) {
+	inner := &memoryRepository{subscribes: map[uint]*models.Subscribe{
+		1: {ID: 1, ServiceName: "Kinopoisk", Price: 399, UserId: "user-1", StartDate: time.Date(2025, time.July, 1, 0, 0, 0, 0, time.UTC)},
+		2: {ID: 2, ServiceName: "Yandex Plus", Price: 400, UserId: "user-1"},
+		3: {ID: 3, ServiceName: "Yandex Plus", Price: 400, UserId: "user-2"},
+	}}
+	return &CachedSubscribeRepository{
+		SubscribeRepository: inner,
+		Store:               store,
+		TTL:                 time.Minute,
+		Metrics:             &cache.Metrics{},
+	}, inner
+}
+
+func TestCachedSubscribeRepository(t *testing.T) {
+	t.Run("ReadThrough", func(t *testing.T) {
+		repo, inner := newCachedRepository(cache.NewMemoryStore(10))
+
+		first, err := repo.FindByID(1)
+		assert.NoError(t, err)
+		second, err := repo.FindByID(1)
+		assert.NoError(t, err)
+		assert.Equal(t, first, second)
+		assert.Equal(t, 1, inner.reads)
+		assert.Equal(t, int64(1), repo.Metrics.Hits.Load())
+		assert.Equal(t, int64(1), repo.Metrics.Misses.Load())
+
+		// the callers change the returned subscribes
+		second.Price = 0
+		third, _ := repo.FindByID(1)
+		assert.Equal(t, 399, third.Price)
+	})
+
+	t.Run("NotFoundIsNotCached", func(t *testing.T) {
+		repo, inner := newCachedRepository(cache.NewMemoryStore(10))
+
+		_, err := repo.FindByID(42)
+		assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+		_, err = repo.FindByID(42)
+		assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+		assert.Equal(t, 2, inner.reads)
+	})
+
+	t.Run("InvalidatedOnUpdate", func(t *testing.T) {
+		repo, inner := newCachedRepository(cache.NewMemoryStore(10))
+
+		repo.FindByID(1)
+		assert.NoError(t, repo.Update(1, &models.Subscribe{Price: 199}))
+		subscribe, err := repo.FindByID(1)
+		assert.NoError(t, err)
+		assert.Equal(t, 199, subscribe.Price)
+		assert.Equal(t, 2, inner.reads)
+	})
+
+	t.Run("InvalidatedAroundUpdate", func(t *testing.T) {
+		store := cache.NewMemoryStore(10)
+		repo, inner := newCachedRepository(store)
+
+		repo.FindByID(1)
+		inner.duringUpdate = func() {
+			value, _, _ := store.Get(context.Background(), subscribeCacheKey(1))
+			assert.Equal(t, subscribeTombstone, value)
+			// a concurrent read gets the old row, but does not cache it
+			subscribe, _ := repo.FindByID(1)
+			assert.Equal(t, 399, subscribe.Price)
+			value, _, _ = store.Get(context.Background(), subscribeCacheKey(1))
+			assert.Equal(t, subscribeTombstone, value)
+		}
+		assert.NoError(t, repo.Update(1, &models.Subscribe{Price: 199}))
+		subscribe, err := repo.FindByID(1)
+		assert.NoError(t, err)
+		assert.Equal(t, 199, subscribe.Price)
+	})
+
+	t.Run("StaleReadNotCached", func(t *testing.T) {
+		repo, inner := newCachedRepository(cache.NewMemoryStore(10))
+
+		// the read loads the old row, the update completes before the read
+		// caches it
+		inner.afterRead = func() {
+			assert.NoError(t, repo.Update(1, &models.Subscribe{Price: 199}))
+		}
+		subscribe, err := repo.FindByID(1)
+		assert.NoError(t, err)
+		assert.Equal(t, 399, subscribe.Price)
+
+		subscribe, err = repo.FindByID(1)
+		assert.NoError(t, err)
+		assert.Equal(t, 199, subscribe.Price)
+	})
+
+	t.Run("RequestContext", func(t *testing.T) {
+		repo, _ := newCachedRepository(contextStore{Store: cache.NewMemoryStore(10), t: t})
+		repo.Ctx = context.WithValue(context.Background(), ctxKey{}, "request")
+
+		repo.FindByID(1)
+		repo.FindByID(1)
+		assert.NoError(t, repo.Update(1, &models.Subscribe{Price: 199}))
+	})
+
+	t.Run("InvalidatedOnDelete", func(t *testing.T) {
+		repo, _ := newCachedRepository(cache.NewMemoryStore(10))
+
+		repo.FindByID(1)
+		repo.FindByID(2)
+		repo.FindByID(3)
+		assert.NoError(t, repo.Delete(1))
+		_, err := repo.FindByID(1)
+		assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+
+		deleted, err := repo.DeleteByUserId("user-1")
+		assert.NoError(t, err)
+		assert.Equal(t, int64(1), deleted)
+		_, err = repo.FindByID(2)
+		assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+		_, err = repo.FindByID(3)
+		assert.NoError(t, err)
+	})
+
+	t.Run("StoreFailure", func(t *testing.T) {
+		repo, inner := newCachedRepository(failingStore{})
+
+		subscribe, err := repo.FindByID(1)
+		assert.NoError(t, err)
+		assert.Equal(t, uint(1), subscribe.ID)
+		assert.NoError(t, repo.Update(1, &models.Subscribe{Price: 199}))
+		assert.Equal(t, 1, inner.reads)
+		// the get and the add of the read, the tombstones around the update
+		assert.Equal(t, int64(4), repo.Metrics.Errors.Load())
+	})
+}
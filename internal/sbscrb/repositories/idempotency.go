@@ -0,0 +1,73 @@
+package repositories
+
+import (
+	"time"
+
+	"github.com/pabloeclair/rest-subscription/internal/sbscrb/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type IdempotencyRepository interface {
+	// Claim stores the key in progress and returns nil, or returns the
+	// stored key if it has been claimed before. The keys older than ttl
+	// and the claims in progress past their lock are claimed again.
+	Claim(key *models.IdempotencyKey, ttl time.Duration) (*models.IdempotencyKey, error)
+	// Complete stores the response of the claim, it returns
+	// models.ErrIdempotencyClaimLost if the claim has been taken over
+	Complete(key *models.IdempotencyKey, statusCode int, contentType string, body []byte) error
+	// Release deletes the claim, so the request can be retried
+	Release(key *models.IdempotencyKey) error
+	Cleanup(before time.Time) (int64, error)
+}
+
+type GormIdempotencyRepository struct {
+	Db *gorm.DB
+}
+
+func (r *GormIdempotencyRepository) Claim(key *models.IdempotencyKey, ttl time.Duration) (*models.IdempotencyKey, error) {
+	var existing *models.IdempotencyKey
+	err := r.Db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Where("key = ? AND (created_at < ? OR (status_code = 0 AND locked_until < ?))",
+			key.Key, key.CreatedAt.Add(-ttl), key.CreatedAt).
+			Delete(&models.IdempotencyKey{}).Error
+		if err != nil {
+			return err
+		}
+		res := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(key)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 1 {
+			return nil
+		}
+		existing = &models.IdempotencyKey{}
+		return tx.Where("key = ?", key.Key).First(existing).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return existing, nil
+}
+
+func (r *GormIdempotencyRepository) Complete(key *models.IdempotencyKey, statusCode int, contentType string, body []byte) error {
+	res := r.Db.Model(&models.IdempotencyKey{}).
+		Where("key = ? AND owner = ?", key.Key, key.Owner).
+		Updates(map[string]any{"status_code": statusCode, "content_type": contentType, "body": body})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return models.ErrIdempotencyClaimLost
+	}
+	return nil
+}
+
+func (r *GormIdempotencyRepository) Release(key *models.IdempotencyKey) error {
+	return r.Db.Where("key = ? AND owner = ?", key.Key, key.Owner).Delete(&models.IdempotencyKey{}).Error
+}
+
+func (r *GormIdempotencyRepository) Cleanup(before time.Time) (int64, error) {
+	res := r.Db.Where("created_at < ?", before).Delete(&models.IdempotencyKey{})
+	return res.RowsAffected, res.Error
+}
@@ -0,0 +1,106 @@
+package repositories
+
+import (
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/pabloeclair/rest-subscription/internal/sbscrb/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIdempotencyClaim(t *testing.T) {
+	now := time.Date(2025, time.September, 1, 0, 0, 0, 0, time.UTC)
+	newKey := func() *models.IdempotencyKey {
+		return &models.IdempotencyKey{Key: "sub:user-1|key-1", Fingerprint: "abc", CreatedAt: now}
+	}
+
+	t.Run("New", func(t *testing.T) {
+		db, mock, err := NewMock()
+		assert.NoError(t, err)
+		repo := GormIdempotencyRepository{Db: db}
+
+		mock.ExpectBegin()
+		mock.ExpectExec(`DELETE FROM "idempotency_keys" WHERE key = \$1 AND \(created_at < \$2 OR \(status_code = 0 AND locked_until < \$3\)\)`).
+			WithArgs("sub:user-1|key-1", now.Add(-time.Hour), now).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec(`INSERT INTO "idempotency_keys" .* ON CONFLICT DO NOTHING`).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		stored, err := repo.Claim(newKey(), time.Hour)
+		assert.NoError(t, err)
+		assert.Nil(t, stored)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Claimed", func(t *testing.T) {
+		db, mock, err := NewMock()
+		assert.NoError(t, err)
+		repo := GormIdempotencyRepository{Db: db}
+
+		mock.ExpectBegin()
+		mock.ExpectExec(`DELETE FROM "idempotency_keys"`).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec(`INSERT INTO "idempotency_keys" .* ON CONFLICT DO NOTHING`).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectQuery(`SELECT \* FROM "idempotency_keys" WHERE key = \$1`).
+			WithArgs("sub:user-1|key-1", 1).
+			WillReturnRows(sqlmock.NewRows([]string{"key", "fingerprint", "status_code", "content_type", "body", "created_at"}).
+				AddRow("sub:user-1|key-1", "abc", 201, "", []byte{}, now.Add(-time.Minute)))
+		mock.ExpectCommit()
+
+		stored, err := repo.Claim(newKey(), time.Hour)
+		assert.NoError(t, err)
+		assert.Equal(t, 201, stored.StatusCode)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestIdempotencyCompleteOwner(t *testing.T) {
+	key := &models.IdempotencyKey{Key: "sub:user-1|key-1", Owner: "owner-a"}
+
+	t.Run("Complete", func(t *testing.T) {
+		db, mock, err := NewMock()
+		assert.NoError(t, err)
+		repo := GormIdempotencyRepository{Db: db}
+
+		mock.ExpectBegin()
+		mock.ExpectExec(`UPDATE "idempotency_keys" SET .* WHERE key = \$\d AND owner = \$\d`).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		assert.NoError(t, repo.Complete(key, 201, "application/json", []byte(`{"id":1}`)))
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("TakenOver", func(t *testing.T) {
+		db, mock, err := NewMock()
+		assert.NoError(t, err)
+		repo := GormIdempotencyRepository{Db: db}
+
+		// the claim of another request is not overwritten
+		mock.ExpectBegin()
+		mock.ExpectExec(`UPDATE "idempotency_keys" SET .* WHERE key = \$\d AND owner = \$\d`).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectCommit()
+
+		assert.ErrorIs(t, repo.Complete(key, 201, "application/json", nil), models.ErrIdempotencyClaimLost)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Release", func(t *testing.T) {
+		db, mock, err := NewMock()
+		assert.NoError(t, err)
+		repo := GormIdempotencyRepository{Db: db}
+
+		mock.ExpectBegin()
+		mock.ExpectExec(`DELETE FROM "idempotency_keys" WHERE key = \$1 AND owner = \$2`).
+			WithArgs("sub:user-1|key-1", "owner-a").
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectCommit()
+
+		assert.NoError(t, repo.Release(key))
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
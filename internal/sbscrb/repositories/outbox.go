@@ -0,0 +1,146 @@
+package repositories
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/pabloeclair/rest-subscription/internal/sbscrb/events"
+	"github.com/pabloeclair/rest-subscription/internal/sbscrb/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type OutboxRepository interface {
+	FindCursor(publisher string) (*models.OutboxCursor, error)
+	SaveCursor(cursor *models.OutboxCursor) error
+	// FindAfter returns the rows after the cursor which can no longer be
+	// preceded by a row of an unfinished transaction.
+	FindAfter(cursor *models.OutboxCursor, limit int) ([]*models.OutboxEvent, error)
+	// DeadLetter records the row and moves the cursor past it.
+	DeadLetter(cursor *models.OutboxCursor, row *models.OutboxEvent) error
+}
+
+// the oldest transaction still running, the rows of the older ones are final
+const outboxHorizon = "pg_snapshot_xmin(pg_current_snapshot())::text::bigint"
+
+type GormOutboxRepository struct {
+	Db *gorm.DB
+}
+
+// writeOutbox stores the events of the subscribes, tx must be the
+// transaction of the mutation that caused them.
+func writeOutbox(tx *gorm.DB, t events.Type, subscribes ...*models.Subscribe) error {
+	if len(subscribes) == 0 {
+		return nil
+	}
+	rows := make([]*models.OutboxEvent, 0, len(subscribes))
+	for _, s := range subscribes {
+		e := events.New(t, s.ToDto())
+		payload, err := json.Marshal(e)
+		if err != nil {
+			return err
+		}
+		rows = append(rows, &models.OutboxEvent{
+			EventID:   e.ID,
+			EventType: string(e.Type),
+			Payload:   string(payload),
+			CreatedAt: e.OccurredAt,
+		})
+	}
+	return tx.Create(&rows).Error
+}
+
+// CreateCursors adds the cursors of the new publishers, they start after
+// the finished transactions. The relay before the cursors marked the
+// published rows in 'published_at', the first cursors continue after them
+// and the column is dropped.
+func (r *GormOutboxRepository) CreateCursors(publishers []string) error {
+	return r.Db.Transaction(func(tx *gorm.DB) error {
+		start := models.OutboxCursor{}
+		if err := tx.Raw("SELECT " + outboxHorizon).Scan(&start.LastTxID).Error; err != nil {
+			return err
+		}
+		legacy := tx.Migrator().HasColumn("outbox_events", "published_at")
+		if legacy {
+			first := &models.OutboxEvent{}
+			res := tx.Where("published_at IS NULL").Order("id").Limit(1).Find(first)
+			if res.Error != nil {
+				return res.Error
+			}
+			if res.RowsAffected > 0 {
+				start.LastTxID, start.LastID = first.TxID, first.ID-1
+			}
+		}
+
+		cursors := make([]*models.OutboxCursor, 0, len(publishers))
+		for _, publisher := range publishers {
+			cursor := start
+			cursor.Publisher = publisher
+			cursors = append(cursors, &cursor)
+		}
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&cursors).Error; err != nil {
+			return err
+		}
+		if legacy {
+			return tx.Migrator().DropColumn("outbox_events", "published_at")
+		}
+		return nil
+	})
+}
+
+func (r *GormOutboxRepository) FindCursor(publisher string) (*models.OutboxCursor, error) {
+	cursor := &models.OutboxCursor{}
+	if err := r.Db.Where("publisher = ?", publisher).First(cursor).Error; err != nil {
+		return nil, err
+	}
+	return cursor, nil
+}
+
+func (r *GormOutboxRepository) SaveCursor(cursor *models.OutboxCursor) error {
+	return r.Db.Save(cursor).Error
+}
+
+func (r *GormOutboxRepository) FindAfter(cursor *models.OutboxCursor, limit int) ([]*models.OutboxEvent, error) {
+	rows := []*models.OutboxEvent{}
+	err := r.Db.
+		Where("tx_id > ? OR (tx_id = ? AND id > ?)", cursor.LastTxID, cursor.LastTxID, cursor.LastID).
+		Where("tx_id < " + outboxHorizon).
+		Order("tx_id, id").
+		Limit(limit).
+		Find(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
+
+// Cleanup deletes the rows created before the time which the cursors of
+// all the publishers have passed.
+func (r *GormOutboxRepository) Cleanup(publishers []string, before time.Time) (int64, error) {
+	pending := r.Db.Model(&models.OutboxCursor{}).
+		Select("1").
+		Where("publisher IN ?", publishers).
+		Where("outbox_events.tx_id > outbox_cursors.last_tx_id OR " +
+			"(outbox_events.tx_id = outbox_cursors.last_tx_id AND outbox_events.id > outbox_cursors.last_id)")
+	res := r.Db.
+		Where("created_at < ?", before).
+		Where("NOT EXISTS (?)", pending).
+		Delete(&models.OutboxEvent{})
+	return res.RowsAffected, res.Error
+}
+
+func (r *GormOutboxRepository) DeadLetter(cursor *models.OutboxCursor, row *models.OutboxEvent) error {
+	return r.Db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&models.OutboxDeadLetter{
+			OutboxID:  row.ID,
+			EventID:   row.EventID,
+			Publisher: cursor.Publisher,
+			Attempts:  cursor.Attempts,
+			LastError: cursor.LastError,
+		}).Error; err != nil {
+			return err
+		}
+		cursor.Pass(row)
+		return tx.Save(cursor).Error
+	})
+}
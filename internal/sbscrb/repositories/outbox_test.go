@@ -0,0 +1,54 @@
+package repositories
+
+import (
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/pabloeclair/rest-subscription/internal/sbscrb/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOutboxFindAfter(t *testing.T) {
+	db, mock, err := NewMock()
+	assert.NoError(t, err)
+	repo := GormOutboxRepository{Db: db}
+
+	// the rows of the running transactions are not read, an older one may
+	// still commit a row before them
+	mock.ExpectQuery(`SELECT \* FROM "outbox_events" `+
+		`WHERE \(tx_id > \$1 OR \(tx_id = \$2 AND id > \$3\)\) `+
+		`AND tx_id < pg_snapshot_xmin\(pg_current_snapshot\(\)\)::text::bigint `+
+		`ORDER BY tx_id, id LIMIT \$4`).
+		WithArgs(100, 100, 7, 50).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "tx_id", "event_id"}).
+			AddRow(6, 101, "event-6").
+			AddRow(8, 101, "event-8"))
+
+	rows, err := repo.FindAfter(&models.OutboxCursor{Publisher: "nats", LastTxID: 100, LastID: 7}, 50)
+	assert.NoError(t, err)
+	assert.Len(t, rows, 2)
+	assert.Equal(t, uint64(101), rows[0].TxID)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestOutboxCleanup(t *testing.T) {
+	db, mock, err := NewMock()
+	assert.NoError(t, err)
+	repo := GormOutboxRepository{Db: db}
+	before := time.Date(2025, time.September, 1, 0, 0, 0, 0, time.UTC)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`DELETE FROM "outbox_events" WHERE created_at < \$1 `+
+		`AND NOT EXISTS \(SELECT 1 FROM "outbox_cursors" WHERE publisher IN \(\$2,\$3\) `+
+		`AND \(outbox_events.tx_id > outbox_cursors.last_tx_id OR `+
+		`\(outbox_events.tx_id = outbox_cursors.last_tx_id AND outbox_events.id > outbox_cursors.last_id\)\)\)`).
+		WithArgs(before, "nats", "webhooks").
+		WillReturnResult(sqlmock.NewResult(0, 3))
+	mock.ExpectCommit()
+
+	deleted, err := repo.Cleanup([]string{"nats", "webhooks"}, before)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), deleted)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
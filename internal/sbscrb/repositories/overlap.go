@@ -0,0 +1,88 @@
+package repositories
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/pabloeclair/rest-subscription/internal/sbscrb/models"
+	"gorm.io/gorm"
+)
+
+// OverlapConstraint is the exclusion constraint which forbids overlapping
+// date ranges of the subscribes of a user to the same service.
+const OverlapConstraint = "subscribes_no_overlap"
+
+// the date range of a subscribe, the end date is inclusive and a subscribe
+// without the end date never ends
+const subscribeDateRange = `daterange((start_date AT TIME ZONE 'UTC')::date, (end_date AT TIME ZONE 'UTC')::date, '[]')`
+
+// OverlapError is returned if the subscribe overlaps another subscribe of
+// the same user and service.
+type OverlapError struct {
+	ConflictingID uint
+}
+
+func (e *OverlapError) Error() string {
+	return fmt.Sprintf("the subscribe overlaps the subscribe with id = %d", e.ConflictingID)
+}
+
+// EnsureOverlapConstraint adds the exclusion constraint if enabled and drops
+// it otherwise. The constraint cannot be added while the table has
+// overlapping subscribes.
+func EnsureOverlapConstraint(db *gorm.DB, enabled bool) error {
+	if !enabled {
+		return db.Exec(`ALTER TABLE subscribes DROP CONSTRAINT IF EXISTS ` + OverlapConstraint).Error
+	}
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec(`CREATE EXTENSION IF NOT EXISTS btree_gist`).Error; err != nil {
+			return err
+		}
+		var exists int64
+		err := tx.Raw(`SELECT count(*) FROM pg_constraint WHERE conname = ?`, OverlapConstraint).Scan(&exists).Error
+		if err != nil || exists > 0 {
+			return err
+		}
+		return tx.Exec(`ALTER TABLE subscribes ADD CONSTRAINT ` + OverlapConstraint +
+			` EXCLUDE USING gist (user_id WITH =, service_name WITH =, ` + subscribeDateRange + ` WITH &&)`).Error
+	})
+}
+
+// FindOverlapping returns a subscribe of the same user and service whose
+// dates overlap the subscribe, or nil if there is none.
+func (r *GormSubscribeRepository) FindOverlapping(subscribe *models.Subscribe) (*models.Subscribe, error) {
+	var end *string
+	if subscribe.EndDate != nil {
+		s := subscribe.EndDate.UTC().Format("2006-01-02")
+		end = &s
+	}
+	overlapping := []*models.Subscribe{}
+	err := r.Db.
+		Where("user_id = ? AND service_name = ? AND id <> ?", subscribe.UserId, subscribe.ServiceName, subscribe.ID).
+		Where(subscribeDateRange+" && daterange(CAST(? AS date), CAST(? AS date), '[]')",
+			subscribe.StartDate.UTC().Format("2006-01-02"), end).
+		Order("id").
+		Limit(1).
+		Find(&overlapping).Error
+	if err != nil {
+		return nil, err
+	}
+	if len(overlapping) == 0 {
+		return nil, nil
+	}
+	return overlapping[0], nil
+}
+
+// overlapError replaces the violation of the exclusion constraint by
+// OverlapError with the id of the conflicting subscribe.
+func (r *GormSubscribeRepository) overlapError(err error, subscribe *models.Subscribe) error {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) || pgErr.Code != "23P01" || pgErr.ConstraintName != OverlapConstraint {
+		return err
+	}
+	conflicting, findErr := r.FindOverlapping(subscribe)
+	if findErr != nil || conflicting == nil {
+		return &OverlapError{}
+	}
+	return &OverlapError{ConflictingID: conflicting.ID}
+}
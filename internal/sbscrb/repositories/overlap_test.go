@@ -0,0 +1,85 @@
+package repositories
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/pabloeclair/rest-subscription/internal/sbscrb/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSubscribeFindOverlapping(t *testing.T) {
+	subscribeTest := &models.Subscribe{
+		ID:          3,
+		ServiceName: "Kinopoisk",
+		Price:       399,
+		UserId:      "6061fee-2bf1-aef6f-763675gre",
+		StartDate:   time.Date(2025, time.July, 26, 0, 0, 0, 0, time.UTC),
+	}
+	query := `SELECT \* FROM "subscribes" WHERE \(user_id = \$1 AND service_name = \$2 AND id <> \$3\) AND ` +
+		`daterange\(.*\) && daterange\(CAST\(\$4 AS date\), CAST\(\$5 AS date\), '\[\]'\) ORDER BY id LIMIT \$6`
+
+	t.Run("Found", func(t *testing.T) {
+		db, mock, err := NewMock()
+		assert.NoError(t, err)
+		repo := GormSubscribeRepository{Db: db}
+
+		mock.ExpectQuery(query).
+			WithArgs(subscribeTest.UserId, "Kinopoisk", 3, "2025-07-26", nil, 1).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "service_name", "user_id"}).
+				AddRow(1, "Kinopoisk", subscribeTest.UserId))
+
+		conflicting, err := repo.FindOverlapping(subscribeTest)
+		assert.NoError(t, err)
+		assert.Equal(t, uint(1), conflicting.ID)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("NotFound", func(t *testing.T) {
+		db, mock, err := NewMock()
+		assert.NoError(t, err)
+		repo := GormSubscribeRepository{Db: db}
+
+		endDate := time.Date(2025, time.August, 26, 0, 0, 0, 0, time.UTC)
+		subscribe := *subscribeTest
+		subscribe.EndDate = &endDate
+		mock.ExpectQuery(query).
+			WithArgs(subscribeTest.UserId, "Kinopoisk", 3, "2025-07-26", "2025-08-26", 1).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+		conflicting, err := repo.FindOverlapping(&subscribe)
+		assert.NoError(t, err)
+		assert.Nil(t, conflicting)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestSubscribeCreateOverlap(t *testing.T) {
+	db, mock, err := NewMock()
+	assert.NoError(t, err)
+	repo := GormSubscribeRepository{Db: db}
+
+	subscribeTest := &models.Subscribe{
+		ServiceName: "Kinopoisk",
+		Price:       399,
+		UserId:      "6061fee-2bf1-aef6f-763675gre",
+		StartDate:   time.Date(2025, time.July, 26, 0, 0, 0, 0, time.UTC),
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`INSERT INTO "subscribes"`).
+		WillReturnError(&pgconn.PgError{Code: "23P01", ConstraintName: OverlapConstraint})
+	mock.ExpectRollback()
+	mock.ExpectQuery(`SELECT \* FROM "subscribes" WHERE \(user_id = \$1`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(7))
+
+	err = repo.Create(subscribeTest)
+	var overlapErr *OverlapError
+	assert.True(t, errors.As(err, &overlapErr))
+	assert.Equal(t, uint(7), overlapErr.ConflictingID)
+	assert.Equal(t, uint(0), subscribeTest.ID)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,177 @@
+package repositories
+
+import (
+	"errors"
+
+	"github.com/pabloeclair/rest-subscription/internal/sbscrb/models"
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrServiceInUse is returned on deleting a service referenced by subscribes.
+	ErrServiceInUse = errors.New("the service is referenced by subscribes")
+	// ErrServiceAliasTaken is returned if an alias belongs to another service.
+	ErrServiceAliasTaken = errors.New("the alias belongs to another service")
+)
+
+type ServiceRepository interface {
+	Create(service *models.Service) error
+	FindAll() ([]*models.Service, error)
+	FindByID(id uint) (*models.Service, error)
+	FindByAlias(name string) (*models.Service, error)
+	Update(id uint, service *models.Service) error
+	Delete(id uint) error
+	// MapSubscribes links the subscribes without a service to the service
+	// whose alias matches their name and returns the number of linked rows.
+	MapSubscribes() (int64, error)
+}
+
+type GormServiceRepository struct {
+	Db *gorm.DB
+}
+
+func (r *GormServiceRepository) Create(service *models.Service) error {
+	return r.Db.Transaction(func(tx *gorm.DB) error {
+		if err := checkAliases(tx, 0, service.Aliases); err != nil {
+			return err
+		}
+		if err := tx.Create(service).Error; err != nil {
+			return err
+		}
+		return mapSubscribes(tx, service.ID)
+	})
+}
+
+func (r *GormServiceRepository) FindAll() ([]*models.Service, error) {
+	services := []*models.Service{}
+	if err := r.Db.Preload("Aliases").Order("id").Find(&services).Error; err != nil {
+		return nil, err
+	}
+	return services, nil
+}
+
+func (r *GormServiceRepository) FindByID(id uint) (*models.Service, error) {
+	service := &models.Service{}
+	err := r.Db.Preload("Aliases").First(service, id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, models.ErrServiceNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	return service, nil
+}
+
+func (r *GormServiceRepository) FindByAlias(name string) (*models.Service, error) {
+	alias := &models.ServiceAlias{}
+	err := r.Db.Where("alias = ?", models.NormalizeServiceName(name)).First(alias).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, models.ErrServiceNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	return r.FindByID(alias.ServiceID)
+}
+
+// Update replaces the fields and the aliases of the service and renames
+// the subscribes of the service.
+func (r *GormServiceRepository) Update(id uint, service *models.Service) error {
+	return r.Db.Transaction(func(tx *gorm.DB) error {
+		if err := checkAliases(tx, id, service.Aliases); err != nil {
+			return err
+		}
+		res := tx.Model(&models.Service{}).
+			Where("id = ?", id).
+			Select("name", "category", "default_price", "website").
+			Updates(service)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return models.ErrServiceNotFound
+		}
+
+		if err := tx.Where("service_id = ?", id).Delete(&models.ServiceAlias{}).Error; err != nil {
+			return err
+		}
+		for i := range service.Aliases {
+			service.Aliases[i].ServiceID = id
+		}
+		if len(service.Aliases) > 0 {
+			if err := tx.Create(&service.Aliases).Error; err != nil {
+				return err
+			}
+		}
+
+		err := tx.Model(&models.Subscribe{}).
+			Where("service_id = ?", id).
+			Update("service_name", service.Name).Error
+		if err != nil {
+			return err
+		}
+		service.ID = id
+		return mapSubscribes(tx, id)
+	})
+}
+
+func (r *GormServiceRepository) Delete(id uint) error {
+	return r.Db.Transaction(func(tx *gorm.DB) error {
+		var used int64
+		if err := tx.Model(&models.Subscribe{}).Where("service_id = ?", id).Count(&used).Error; err != nil {
+			return err
+		}
+		if used > 0 {
+			return ErrServiceInUse
+		}
+		if err := tx.Where("service_id = ?", id).Delete(&models.ServiceAlias{}).Error; err != nil {
+			return err
+		}
+		res := tx.Delete(&models.Service{}, id)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return models.ErrServiceNotFound
+		}
+		return nil
+	})
+}
+
+func (r *GormServiceRepository) MapSubscribes() (int64, error) {
+	res := r.Db.Exec(mapSubscribesQuery)
+	return res.RowsAffected, res.Error
+}
+
+// mapSubscribesQuery sets updated_at itself, unlike gorm, so the ETag of a
+// mapped subscribe changes.
+const mapSubscribesQuery = `
+UPDATE subscribes s
+SET service_id = a.service_id, service_name = sv.name, updated_at = now()
+FROM service_aliases a
+JOIN services sv ON sv.id = a.service_id
+WHERE s.service_id IS NULL
+  AND a.alias = lower(regexp_replace(btrim(s.service_name), '\s+', ' ', 'g'))`
+
+func mapSubscribes(tx *gorm.DB, serviceId uint) error {
+	return tx.Exec(mapSubscribesQuery+" AND a.service_id = ?", serviceId).Error
+}
+
+func checkAliases(tx *gorm.DB, serviceId uint, aliases []models.ServiceAlias) error {
+	if len(aliases) == 0 {
+		return nil
+	}
+	names := make([]string, len(aliases))
+	for i, a := range aliases {
+		names[i] = a.Alias
+	}
+	var taken int64
+	err := tx.Model(&models.ServiceAlias{}).
+		Where("alias IN ? AND service_id <> ?", names, serviceId).
+		Count(&taken).Error
+	if err != nil {
+		return err
+	}
+	if taken > 0 {
+		return ErrServiceAliasTaken
+	}
+	return nil
+}
@@ -0,0 +1,151 @@
+package repositories
+
+import (
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/pabloeclair/rest-subscription/internal/sbscrb/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServiceFindByAlias(t *testing.T) {
+	t.Run("Success", func(t *testing.T) {
+		db, mock, err := NewMock()
+		assert.NoError(t, err)
+		repo := GormServiceRepository{Db: db}
+
+		mock.ExpectQuery(`SELECT \* FROM "service_aliases" WHERE alias = \$1`).
+			WithArgs("kinopoisk hd", 1).
+			WillReturnRows(sqlmock.NewRows([]string{"alias", "service_id"}).AddRow("kinopoisk hd", 2))
+		mock.ExpectQuery(`SELECT \* FROM "services" WHERE "services"."id" = \$1`).
+			WithArgs(2, 1).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(2, "Kinopoisk"))
+		mock.ExpectQuery(`SELECT \* FROM "service_aliases" WHERE "service_aliases"."service_id" = \$1`).
+			WithArgs(2).
+			WillReturnRows(sqlmock.NewRows([]string{"alias", "service_id"}).
+				AddRow("kinopoisk", 2).
+				AddRow("kinopoisk hd", 2))
+
+		service, err := repo.FindByAlias("  Kinopoisk   HD ")
+		assert.NoError(t, err)
+		assert.Equal(t, "Kinopoisk", service.Name)
+		assert.Equal(t, []string{"kinopoisk hd"}, service.ToDto().Aliases)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("ErrServiceNotFound", func(t *testing.T) {
+		db, mock, err := NewMock()
+		assert.NoError(t, err)
+		repo := GormServiceRepository{Db: db}
+
+		mock.ExpectQuery(`SELECT \* FROM "service_aliases" WHERE alias = \$1`).
+			WithArgs("netflix", 1).
+			WillReturnRows(sqlmock.NewRows([]string{"alias", "service_id"}))
+
+		_, err = repo.FindByAlias("Netflix")
+		assert.ErrorIs(t, err, models.ErrServiceNotFound)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestServiceCreate(t *testing.T) {
+	t.Run("ErrServiceAliasTaken", func(t *testing.T) {
+		db, mock, err := NewMock()
+		assert.NoError(t, err)
+		repo := GormServiceRepository{Db: db}
+
+		mock.ExpectBegin()
+		mock.ExpectQuery(`SELECT count\(\*\) FROM "service_aliases" WHERE alias IN \(\$1,\$2\) AND service_id <> \$3`).
+			WithArgs("kinopoisk", "кинопоиск", 0).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+		mock.ExpectRollback()
+
+		service := (&models.ServiceDto{Name: "Kinopoisk", Aliases: []string{"Кинопоиск", "kinopoisk"}}).ToDatabase()
+		err = repo.Create(service)
+		assert.ErrorIs(t, err, ErrServiceAliasTaken)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestServiceDelete(t *testing.T) {
+	t.Run("Success", func(t *testing.T) {
+		db, mock, err := NewMock()
+		assert.NoError(t, err)
+		repo := GormServiceRepository{Db: db}
+
+		mock.ExpectBegin()
+		mock.ExpectQuery(`SELECT count\(\*\) FROM "subscribes" WHERE service_id = \$1`).
+			WithArgs(1).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+		mock.ExpectExec(`DELETE FROM "service_aliases" WHERE service_id = \$1`).
+			WithArgs(1).
+			WillReturnResult(sqlmock.NewResult(0, 2))
+		mock.ExpectExec(`DELETE FROM "services" WHERE "services"."id" = \$1`).
+			WithArgs(1).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		err = repo.Delete(1)
+		assert.NoError(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("ErrServiceInUse", func(t *testing.T) {
+		db, mock, err := NewMock()
+		assert.NoError(t, err)
+		repo := GormServiceRepository{Db: db}
+
+		mock.ExpectBegin()
+		mock.ExpectQuery(`SELECT count\(\*\) FROM "subscribes" WHERE service_id = \$1`).
+			WithArgs(1).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(3))
+		mock.ExpectRollback()
+
+		err = repo.Delete(1)
+		assert.ErrorIs(t, err, ErrServiceInUse)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+// The ETag of a subscribe is built from updated_at, so every write to the
+// subscribes of a service must change it.
+func TestServiceMapSubscribes(t *testing.T) {
+	t.Run("MapSubscribes", func(t *testing.T) {
+		db, mock, err := NewMock()
+		assert.NoError(t, err)
+		repo := GormServiceRepository{Db: db}
+
+		mock.ExpectExec(`UPDATE subscribes s\s+SET service_id = a.service_id, service_name = sv.name, updated_at = now\(\)`).
+			WillReturnResult(sqlmock.NewResult(0, 2))
+
+		mapped, err := repo.MapSubscribes()
+		assert.NoError(t, err)
+		assert.Equal(t, int64(2), mapped)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Update", func(t *testing.T) {
+		db, mock, err := NewMock()
+		assert.NoError(t, err)
+		repo := GormServiceRepository{Db: db}
+
+		mock.ExpectBegin()
+		mock.ExpectExec(`UPDATE "services" SET "name"=\$1,"category"=\$2,"default_price"=\$3,"website"=\$4 WHERE id = \$5`).
+			WithArgs("Kinopoisk", "", nil, "", 1).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(`DELETE FROM "service_aliases" WHERE service_id = \$1`).
+			WithArgs(1).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec(`UPDATE "subscribes" SET "service_name"=\$1,"updated_at"=\$2 WHERE service_id = \$3`).
+			WithArgs("Kinopoisk", sqlmock.AnyArg(), 1).
+			WillReturnResult(sqlmock.NewResult(0, 3))
+		mock.ExpectExec(`UPDATE subscribes s\s+SET service_id = a.service_id, service_name = sv.name, updated_at = now\(\).* AND a.service_id = \$1`).
+			WithArgs(1).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		err = repo.Update(1, &models.Service{Name: "Kinopoisk"})
+		assert.NoError(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
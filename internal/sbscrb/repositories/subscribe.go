@@ -1,20 +1,36 @@
 package repositories
 
 import (
+	"time"
+
+	"github.com/pabloeclair/rest-subscription/internal/sbscrb/events"
 	"github.com/pabloeclair/rest-subscription/internal/sbscrb/models"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 var ErrBadRequest error
 
 type SubscribeRepository interface {
 	Create(subscribe *models.Subscribe) error
+	CreateAll(subscribes []*models.Subscribe) (int, error)
 	FindAll() ([]*models.Subscribe, error)
 	FindByID(id uint) (*models.Subscribe, error)
 	FindByUserId(userId string) ([]*models.Subscribe, error)
+	SummaryByUserId(userId string, now time.Time) (*models.UserSummary, error)
 	FindByServiceName(serviceName string) ([]*models.Subscribe, error)
+	FindOverlapping(subscribe *models.Subscribe) (*models.Subscribe, error)
 	Update(id uint, subscribe *models.Subscribe) error
 	Delete(id uint) error
+	DeleteByUserId(userId string) (int64, error)
+	MarkExpired(now time.Time) ([]*models.Subscribe, error)
+	Stream(filter SubscribeFilter, fn func(subscribe *models.Subscribe) error) error
+}
+
+// SubscribeFilter selects subscribes by the non-empty fields.
+type SubscribeFilter struct {
+	UserId      string
+	ServiceName string
 }
 
 type GormSubscribeRepository struct {
@@ -22,14 +38,49 @@ type GormSubscribeRepository struct {
 }
 
 func (r *GormSubscribeRepository) Create(subscribe *models.Subscribe) error {
-	return r.Db.Create(subscribe).Error
+	err := r.Db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(subscribe).Error; err != nil {
+			return err
+		}
+		return writeOutbox(tx, events.SubscribeCreated, subscribe)
+	})
+	if err != nil {
+		subscribe.ID = 0
+		return r.overlapError(err, subscribe)
+	}
+	return nil
+}
+
+// CreateAll creates the subscribes in a single transaction. On failure
+// nothing is created and the index of the failed subscribe is returned.
+func (r *GormSubscribeRepository) CreateAll(subscribes []*models.Subscribe) (int, error) {
+	failed := -1
+	err := r.Db.Transaction(func(tx *gorm.DB) error {
+		for i, subscribe := range subscribes {
+			if err := tx.Create(subscribe).Error; err != nil {
+				failed = i
+				return err
+			}
+		}
+		return writeOutbox(tx, events.SubscribeCreated, subscribes...)
+	})
+	if err != nil {
+		for _, subscribe := range subscribes {
+			subscribe.ID = 0
+		}
+		if failed >= 0 {
+			err = r.overlapError(err, subscribes[failed])
+		}
+	}
+	return failed, err
 }
 
 func (r *GormSubscribeRepository) FindAll() ([]*models.Subscribe, error) {
-	if err := r.Db.Find(&[]*models.Subscribe{}).Error; err != nil {
+	subscribes := []*models.Subscribe{}
+	if err := r.Db.Find(&subscribes).Error; err != nil {
 		return nil, err
 	}
-	return []*models.Subscribe{}, nil
+	return subscribes, nil
 }
 
 func (r *GormSubscribeRepository) FindByID(id uint) (*models.Subscribe, error) {
@@ -55,12 +106,46 @@ func (r *GormSubscribeRepository) FindByUserId(userId string) ([]*models.Subscri
 	return subscribes, nil
 }
 
+// SummaryByUserId counts the subscribes of the user, a subscribe is active
+// at now if it has started, has not ended and is not marked as expired.
+func (r *GormSubscribeRepository) SummaryByUserId(userId string, now time.Time) (*models.UserSummary, error) {
+	if userId == "" {
+		return nil, ErrBadRequest
+	}
+	summary := &models.UserSummary{}
+	err := r.Db.Raw(`
+SELECT count(*) AS subscribes,
+       count(*) FILTER (WHERE active) AS active,
+       coalesce(sum(price) FILTER (WHERE active), 0) AS monthly_total,
+       min(start_date) AS earliest_start
+FROM (
+    SELECT price, start_date,
+           start_date <= @now AND (end_date IS NULL OR end_date >= @now) AND expired_at IS NULL AS active
+    FROM subscribes
+    WHERE user_id = @user_id
+) s`,
+		map[string]any{"user_id": userId, "now": now},
+	).Scan(summary).Error
+	if err != nil {
+		return nil, err
+	}
+	summary.UserId = userId
+	return summary, nil
+}
+
 func (r *GormSubscribeRepository) FindByServiceName(serviceName string) ([]*models.Subscribe, error) {
 	subscribes := []*models.Subscribe{}
 	if serviceName == "" {
 		return nil, ErrBadRequest
 	}
-	if err := r.Db.Where(&models.Subscribe{ServiceName: serviceName}).Find(&subscribes).Error; err != nil {
+	// the name may be any alias of a catalogue service
+	aliases := r.Db.Model(&models.ServiceAlias{}).
+		Select("service_id").
+		Where("alias = ?", models.NormalizeServiceName(serviceName))
+	err := r.Db.Where(&models.Subscribe{ServiceName: serviceName}).
+		Or("service_id IN (?)", aliases).
+		Find(&subscribes).Error
+	if err != nil {
 		if err == gorm.ErrRecordNotFound {
 			return nil, nil
 		} else {
@@ -70,24 +155,100 @@ func (r *GormSubscribeRepository) FindByServiceName(serviceName string) ([]*mode
 	return subscribes, nil
 }
 
+// Update writes all the fields of the subscribe, the cleared end_date and
+// expired_at too.
 func (r *GormSubscribeRepository) Update(id uint, subscribe *models.Subscribe) error {
-	res := r.Db.Model(&models.Subscribe{}).Omit("id").Where("id = ?", id).Updates(subscribe)
-	if res.Error != nil {
-		return res.Error
-	}
-	if res.RowsAffected == 0 {
-		return gorm.ErrRecordNotFound
+	err := r.Db.Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(&models.Subscribe{}).Select("*").Omit("id").Where("id = ?", id).Updates(subscribe)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		subscribe.ID = id
+		return writeOutbox(tx, events.SubscribeUpdated, subscribe)
+	})
+	if err != nil {
+		subscribe.ID = id
+		return r.overlapError(err, subscribe)
 	}
 	return nil
 }
 
 func (r *GormSubscribeRepository) Delete(id uint) error {
-	res := r.Db.Delete(&models.Subscribe{}, id)
-	if res.Error != nil {
-		return res.Error
+	return r.Db.Transaction(func(tx *gorm.DB) error {
+		deleted := []*models.Subscribe{}
+		res := tx.Clauses(clause.Returning{}).Delete(&deleted, id)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return writeOutbox(tx, events.SubscribeDeleted, deleted...)
+	})
+}
+
+// DeleteByUserId deletes all subscribes of the user and returns their number.
+func (r *GormSubscribeRepository) DeleteByUserId(userId string) (int64, error) {
+	if userId == "" {
+		return 0, ErrBadRequest
 	}
-	if res.RowsAffected == 0 {
-		return gorm.ErrRecordNotFound
+	deleted := []*models.Subscribe{}
+	err := r.Db.Transaction(func(tx *gorm.DB) error {
+		res := tx.Clauses(clause.Returning{}).Where("user_id = ?", userId).Delete(&deleted)
+		if res.Error != nil {
+			return res.Error
+		}
+		return writeOutbox(tx, events.SubscribeDeleted, deleted...)
+	})
+	if err != nil {
+		return 0, err
 	}
-	return nil
+	return int64(len(deleted)), nil
+}
+
+// MarkExpired sets expired_at for every subscribe whose end_date is before now
+// and returns the subscribes that have been marked by this call.
+func (r *GormSubscribeRepository) MarkExpired(now time.Time) ([]*models.Subscribe, error) {
+	subscribes := []*models.Subscribe{}
+	err := r.Db.Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(&subscribes).
+			Clauses(clause.Returning{}).
+			Where("end_date < ? AND expired_at IS NULL", now).
+			Update("expired_at", now)
+		if res.Error != nil {
+			return res.Error
+		}
+		return writeOutbox(tx, events.SubscribeExpired, subscribes...)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return subscribes, nil
+}
+
+// Stream calls fn for every subscribe matching the filter, reading them
+// from a database cursor one by one. It stops at the first error of fn.
+func (r *GormSubscribeRepository) Stream(filter SubscribeFilter, fn func(subscribe *models.Subscribe) error) error {
+	rows, err := r.Db.Model(&models.Subscribe{}).
+		Where(&models.Subscribe{UserId: filter.UserId, ServiceName: filter.ServiceName}).
+		Order("id").
+		Rows()
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		subscribe := &models.Subscribe{}
+		if err := r.Db.ScanRows(rows, subscribe); err != nil {
+			return err
+		}
+		if err := fn(subscribe); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
 }
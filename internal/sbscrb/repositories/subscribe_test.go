@@ -54,8 +54,13 @@ func TestSubscribeCreate(t *testing.T) {
 			subscribeTest.UserId,
 			subscribeTest.StartDate,
 			subscribeTest.EndDate,
+			subscribeTest.ExpiredAt,
+			subscribeTest.ServiceID,
 		).
 		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectQuery(`INSERT INTO "outbox_events"`).
+		WithArgs(sqlmock.AnyArg(), "subscribe.created", sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
 	mock.ExpectCommit()
 
 	err = repo.Create(subscribeTest)
@@ -109,6 +114,7 @@ func TestSubscribeFindAll(t *testing.T) {
 
 		subscribes, err := repo.FindAll()
 		assert.NoError(t, err)
+		assert.Len(t, subscribes, len(subscribesExpected))
 		for i, subscribe := range subscribes {
 			assert.Equal(t, subscribesExpected[i], subscribe)
 		}
@@ -193,8 +199,8 @@ func TestSubscribeFindByServiceName(t *testing.T) {
 			},
 		}
 
-		mock.ExpectQuery(`SELECT \* FROM "subscribes" WHERE "subscribes"."service_name" = \$1`).
-			WithArgs("Kinopoisk").
+		mock.ExpectQuery(`SELECT \* FROM "subscribes" WHERE "subscribes"."service_name" = \$1 OR service_id IN \(SELECT "service_id" FROM "service_aliases" WHERE alias = \$2\)`).
+			WithArgs("Kinopoisk", "kinopoisk").
 			WillReturnRows(sqlmock.NewRows([]string{"id", "service_name", "price", "user_id", "start_date", "end_date"}).
 				AddRow(
 					subscribesExpected[0].ID,
@@ -226,8 +232,8 @@ func TestSubscribeFindByServiceName(t *testing.T) {
 		assert.NoError(t, err)
 		repo := GormSubscribeRepository{Db: db}
 
-		mock.ExpectQuery(`SELECT \* FROM "subscribes" WHERE "subscribes"."service_name" = \$1`).
-			WithArgs("Kinopoisk").
+		mock.ExpectQuery(`SELECT \* FROM "subscribes" WHERE "subscribes"."service_name" = \$1 OR service_id IN \(SELECT "service_id" FROM "service_aliases" WHERE alias = \$2\)`).
+			WithArgs("Kinopoisk", "kinopoisk").
 			WillReturnError(gorm.ErrRecordNotFound)
 
 		subescribes, err := repo.FindByServiceName("Kinopoisk")
@@ -324,17 +330,23 @@ func TestSubscribeUpdate(t *testing.T) {
 
 		mock.ExpectBegin()
 		mock.ExpectExec(`UPDATE "subscribes" 
-			SET "service_name"=\$1,"price"=\$2,"user_id"=\$3,"start_date"=\$4,"end_date"=\$5 
-			WHERE id = \$6`).
+			SET "service_name"=\$1,"price"=\$2,"user_id"=\$3,"start_date"=\$4,"end_date"=\$5,"expired_at"=\$6,"service_id"=\$7,"updated_at"=\$8 
+			WHERE id = \$9`).
 			WithArgs(
 				subscribeTest.ServiceName,
 				subscribeTest.Price,
 				subscribeTest.UserId,
 				subscribeTest.StartDate,
 				subscribeTest.EndDate,
+				nil,
+				nil,
+				sqlmock.AnyArg(),
 				subscribeTest.ID,
 			).
 			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectQuery(`INSERT INTO "outbox_events"`).
+			WithArgs(sqlmock.AnyArg(), "subscribe.updated", sqlmock.AnyArg(), sqlmock.AnyArg()).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
 		mock.ExpectCommit()
 
 		err = repo.Update(1, subscribeTest)
@@ -357,16 +369,23 @@ func TestSubscribeUpdate(t *testing.T) {
 
 		mock.ExpectBegin()
 		mock.ExpectExec(`UPDATE "subscribes" 
-			SET "service_name"=\$1,"price"=\$2,"user_id"=\$3,"start_date"=\$4 
-			WHERE id = \$5`).
+			SET "service_name"=\$1,"price"=\$2,"user_id"=\$3,"start_date"=\$4,"end_date"=\$5,"expired_at"=\$6,"service_id"=\$7,"updated_at"=\$8 
+			WHERE id = \$9`).
 			WithArgs(
 				subscribeTest.ServiceName,
 				subscribeTest.Price,
 				subscribeTest.UserId,
 				subscribeTest.StartDate,
+				nil,
+				nil,
+				nil,
+				sqlmock.AnyArg(),
 				subscribeTest.ID,
 			).
 			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectQuery(`INSERT INTO "outbox_events"`).
+			WithArgs(sqlmock.AnyArg(), "subscribe.updated", sqlmock.AnyArg(), sqlmock.AnyArg()).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
 		mock.ExpectCommit()
 
 		err = repo.Update(1, subscribeTest)
@@ -389,17 +408,21 @@ func TestSubscribeUpdate(t *testing.T) {
 
 		mock.ExpectBegin()
 		mock.ExpectExec(`UPDATE "subscribes" 
-			SET "service_name"=\$1,"price"=\$2,"user_id"=\$3,"start_date"=\$4 
-			WHERE id = \$5`).
+			SET "service_name"=\$1,"price"=\$2,"user_id"=\$3,"start_date"=\$4,"end_date"=\$5,"expired_at"=\$6,"service_id"=\$7,"updated_at"=\$8 
+			WHERE id = \$9`).
 			WithArgs(
 				subscribeTest.ServiceName,
 				subscribeTest.Price,
 				subscribeTest.UserId,
 				subscribeTest.StartDate,
+				nil,
+				nil,
+				nil,
+				sqlmock.AnyArg(),
 				subscribeTest.ID,
 			).
 			WillReturnResult(sqlmock.NewResult(0, 0))
-		mock.ExpectCommit()
+		mock.ExpectRollback()
 
 		err = repo.Update(1, subscribeTest)
 		assert.Equal(t, gorm.ErrRecordNotFound, err)
@@ -414,9 +437,14 @@ func TestSubscribeDelete(t *testing.T) {
 		repo := GormSubscribeRepository{Db: db}
 
 		mock.ExpectBegin()
-		mock.ExpectExec(`DELETE FROM "subscribes" WHERE "subscribes"."id" = \$1`).
+		mock.ExpectQuery(`DELETE FROM "subscribes" WHERE "subscribes"."id" = \$1 RETURNING \*`).
 			WithArgs(1).
-			WillReturnResult(sqlmock.NewResult(1, 1))
+			WillReturnRows(sqlmock.NewRows([]string{"id", "service_name", "price", "user_id", "start_date", "end_date"}).
+				AddRow(1, "Kinopoisk", 399, "6061fee-2bf1-aef6f-763675gre",
+					time.Date(2025, time.July, 26, 0, 0, 0, 0, time.Local), nil))
+		mock.ExpectQuery(`INSERT INTO "outbox_events"`).
+			WithArgs(sqlmock.AnyArg(), "subscribe.deleted", sqlmock.AnyArg(), sqlmock.AnyArg()).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
 		mock.ExpectCommit()
 
 		err = repo.Delete(1)
@@ -430,13 +458,189 @@ func TestSubscribeDelete(t *testing.T) {
 		repo := GormSubscribeRepository{Db: db}
 
 		mock.ExpectBegin()
-		mock.ExpectExec(`DELETE FROM "subscribes" WHERE "subscribes"."id" = \$1`).
+		mock.ExpectQuery(`DELETE FROM "subscribes" WHERE "subscribes"."id" = \$1 RETURNING \*`).
 			WithArgs(1).
-			WillReturnResult(sqlmock.NewResult(0, 0))
-		mock.ExpectCommit()
+			WillReturnRows(sqlmock.NewRows([]string{"id", "service_name", "price", "user_id", "start_date", "end_date"}))
+		mock.ExpectRollback()
 
 		err = repo.Delete(1)
 		assert.Equal(t, gorm.ErrRecordNotFound, err)
 		assert.NoError(t, mock.ExpectationsWereMet())
 	})
 }
+
+func TestSubscribeMarkExpired(t *testing.T) {
+	t.Run("Success", func(t *testing.T) {
+		db, mock, err := NewMock()
+		assert.NoError(t, err)
+		repo := GormSubscribeRepository{Db: db}
+		now := time.Date(2025, time.September, 1, 0, 0, 0, 0, time.Local)
+		endDate := time.Date(2025, time.August, 26, 0, 0, 0, 0, time.Local)
+
+		mock.ExpectBegin()
+		mock.ExpectQuery(`UPDATE "subscribes" SET "expired_at"=\$1,"updated_at"=\$2 WHERE end_date < \$3 AND expired_at IS NULL RETURNING \*`).
+			WithArgs(now, sqlmock.AnyArg(), now).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "service_name", "price", "user_id", "start_date", "end_date", "expired_at"}).
+				AddRow(1, "Kinopoisk", 399, "6061fee-2bf1-aef6f-763675gre",
+					time.Date(2025, time.July, 26, 0, 0, 0, 0, time.Local), endDate, now))
+		mock.ExpectQuery(`INSERT INTO "outbox_events"`).
+			WithArgs(sqlmock.AnyArg(), "subscribe.expired", sqlmock.AnyArg(), sqlmock.AnyArg()).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+		mock.ExpectCommit()
+
+		subscribes, err := repo.MarkExpired(now)
+		assert.NoError(t, err)
+		assert.Len(t, subscribes, 1)
+		assert.Equal(t, uint(1), subscribes[0].ID)
+		assert.Equal(t, now, *subscribes[0].ExpiredAt)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("SuccessEmpty", func(t *testing.T) {
+		db, mock, err := NewMock()
+		assert.NoError(t, err)
+		repo := GormSubscribeRepository{Db: db}
+		now := time.Date(2025, time.September, 1, 0, 0, 0, 0, time.Local)
+
+		mock.ExpectBegin()
+		mock.ExpectQuery(`UPDATE "subscribes" SET "expired_at"=\$1,"updated_at"=\$2 WHERE end_date < \$3 AND expired_at IS NULL RETURNING \*`).
+			WithArgs(now, sqlmock.AnyArg(), now).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "service_name", "price", "user_id", "start_date", "end_date", "expired_at"}))
+		mock.ExpectCommit()
+
+		subscribes, err := repo.MarkExpired(now)
+		assert.NoError(t, err)
+		assert.Len(t, subscribes, 0)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestSubscribeCreateAll(t *testing.T) {
+	newSubscribes := func() []*models.Subscribe {
+		return []*models.Subscribe{
+			{
+				ServiceName: "Kinopoisk",
+				Price:       399,
+				UserId:      "6061fee-2bf1-aef6f-763675gre",
+				StartDate:   time.Date(2025, time.July, 26, 0, 0, 0, 0, time.Local),
+			},
+			{
+				ServiceName: "Kinopoisk",
+				Price:       199,
+				UserId:      "708gr-26896-agrfrf-fr5655gre",
+				StartDate:   time.Date(2025, time.July, 15, 0, 0, 0, 0, time.Local),
+			},
+		}
+	}
+
+	t.Run("Success", func(t *testing.T) {
+		db, mock, err := NewMock()
+		assert.NoError(t, err)
+		repo := GormSubscribeRepository{Db: db}
+		subscribes := newSubscribes()
+
+		mock.ExpectBegin()
+		mock.ExpectQuery(`INSERT INTO "subscribes"`).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+		mock.ExpectQuery(`INSERT INTO "subscribes"`).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(2))
+		mock.ExpectQuery(`INSERT INTO "outbox_events"`).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1).AddRow(2))
+		mock.ExpectCommit()
+
+		failed, err := repo.CreateAll(subscribes)
+		assert.NoError(t, err)
+		assert.Equal(t, -1, failed)
+		assert.Equal(t, uint(1), subscribes[0].ID)
+		assert.Equal(t, uint(2), subscribes[1].ID)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Rollback", func(t *testing.T) {
+		db, mock, err := NewMock()
+		assert.NoError(t, err)
+		repo := GormSubscribeRepository{Db: db}
+		subscribes := newSubscribes()
+
+		mock.ExpectBegin()
+		mock.ExpectQuery(`INSERT INTO "subscribes"`).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+		mock.ExpectQuery(`INSERT INTO "subscribes"`).
+			WillReturnError(gorm.ErrInvalidData)
+		mock.ExpectRollback()
+
+		failed, err := repo.CreateAll(subscribes)
+		assert.Error(t, err)
+		assert.Equal(t, 1, failed)
+		assert.Equal(t, uint(0), subscribes[0].ID)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestSubscribeStream(t *testing.T) {
+	db, mock, err := NewMock()
+	assert.NoError(t, err)
+	repo := GormSubscribeRepository{Db: db}
+
+	mock.ExpectQuery(`SELECT \* FROM "subscribes" WHERE "subscribes"."user_id" = \$1 ORDER BY id`).
+		WithArgs("6061fee-2bf1-aef6f-763675gre").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "service_name", "price", "user_id", "start_date", "end_date"}).
+			AddRow(1, "Kinopoisk", 399, "6061fee-2bf1-aef6f-763675gre", time.Date(2025, time.July, 26, 0, 0, 0, 0, time.Local), nil).
+			AddRow(2, "Okko", 299, "6061fee-2bf1-aef6f-763675gre", time.Date(2025, time.July, 15, 0, 0, 0, 0, time.Local), nil))
+
+	ids := []uint{}
+	err = repo.Stream(SubscribeFilter{UserId: "6061fee-2bf1-aef6f-763675gre"}, func(subscribe *models.Subscribe) error {
+		ids = append(ids, subscribe.ID)
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []uint{1, 2}, ids)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSubscribeDeleteByUserId(t *testing.T) {
+	db, mock, err := NewMock()
+	assert.NoError(t, err)
+	repo := GormSubscribeRepository{Db: db}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`DELETE FROM "subscribes" WHERE user_id = \$1 RETURNING \*`).
+		WithArgs("6061fee-2bf1-aef6f-763675gre").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "service_name", "price", "user_id", "start_date", "end_date"}).
+			AddRow(1, "Kinopoisk", 399, "6061fee-2bf1-aef6f-763675gre",
+				time.Date(2025, time.July, 26, 0, 0, 0, 0, time.Local), nil).
+			AddRow(2, "Yandex Plus", 299, "6061fee-2bf1-aef6f-763675gre",
+				time.Date(2025, time.July, 1, 0, 0, 0, 0, time.Local), nil))
+	mock.ExpectQuery(`INSERT INTO "outbox_events"`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1).AddRow(2))
+	mock.ExpectCommit()
+
+	deleted, err := repo.DeleteByUserId("6061fee-2bf1-aef6f-763675gre")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), deleted)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSubscribeSummaryByUserId(t *testing.T) {
+	db, mock, err := NewMock()
+	assert.NoError(t, err)
+	repo := GormSubscribeRepository{Db: db}
+	now := time.Date(2025, time.September, 1, 0, 0, 0, 0, time.Local)
+	earliest := time.Date(2025, time.July, 1, 0, 0, 0, 0, time.Local)
+
+	mock.ExpectQuery(`(?s)SELECT count\(\*\) AS subscribes.*FROM subscribes\s+WHERE user_id = \$3`).
+		WithArgs(now, now, "6061fee-2bf1-aef6f-763675gre").
+		WillReturnRows(sqlmock.NewRows([]string{"subscribes", "active", "monthly_total", "earliest_start"}).
+			AddRow(3, 2, 698, earliest))
+
+	summary, err := repo.SummaryByUserId("6061fee-2bf1-aef6f-763675gre", now)
+	assert.NoError(t, err)
+	assert.Equal(t, &models.UserSummary{
+		UserId:        "6061fee-2bf1-aef6f-763675gre",
+		Subscribes:    3,
+		Active:        2,
+		MonthlyTotal:  698,
+		EarliestStart: &earliest,
+	}, summary)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
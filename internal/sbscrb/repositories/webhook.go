@@ -0,0 +1,117 @@
+package repositories
+
+import (
+	"time"
+
+	"github.com/pabloeclair/rest-subscription/internal/sbscrb/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type WebhookRepository interface {
+	Create(webhook *models.Webhook) error
+	FindAll() ([]*models.Webhook, error)
+	FindByID(id uint) (*models.Webhook, error)
+	Update(id uint, webhook *models.Webhook) error
+	Delete(id uint) error
+}
+
+type WebhookDeliveryRepository interface {
+	Enqueue(deliveries []*models.WebhookDelivery) error
+	// ClaimDue returns up to limit pending deliveries due at now and
+	// postpones them by lease, so other instances do not pick them up
+	ClaimDue(now time.Time, lease time.Duration, limit int) ([]*models.WebhookDelivery, error)
+	Save(delivery *models.WebhookDelivery) error
+	FindByWebhookID(webhookId uint) ([]*models.WebhookDelivery, error)
+}
+
+type GormWebhookRepository struct {
+	Db *gorm.DB
+}
+
+func (r *GormWebhookRepository) Create(webhook *models.Webhook) error {
+	return r.Db.Create(webhook).Error
+}
+
+func (r *GormWebhookRepository) FindAll() ([]*models.Webhook, error) {
+	webhooks := []*models.Webhook{}
+	if err := r.Db.Order("id").Find(&webhooks).Error; err != nil {
+		return nil, err
+	}
+	return webhooks, nil
+}
+
+func (r *GormWebhookRepository) FindByID(id uint) (*models.Webhook, error) {
+	webhook := &models.Webhook{}
+	if err := r.Db.First(webhook, id).Error; err != nil {
+		return nil, err
+	}
+	return webhook, nil
+}
+
+func (r *GormWebhookRepository) Update(id uint, webhook *models.Webhook) error {
+	res := r.Db.Model(&models.Webhook{}).Omit("id", "created_at").Where("id = ?", id).Updates(webhook)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (r *GormWebhookRepository) Delete(id uint) error {
+	return r.Db.Transaction(func(tx *gorm.DB) error {
+		res := tx.Delete(&models.Webhook{}, id)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return tx.Where("webhook_id = ?", id).Delete(&models.WebhookDelivery{}).Error
+	})
+}
+
+type GormWebhookDeliveryRepository struct {
+	Db *gorm.DB
+}
+
+func (r *GormWebhookDeliveryRepository) Enqueue(deliveries []*models.WebhookDelivery) error {
+	if len(deliveries) == 0 {
+		return nil
+	}
+	return r.Db.Clauses(clause.OnConflict{DoNothing: true}).Create(&deliveries).Error
+}
+
+func (r *GormWebhookDeliveryRepository) ClaimDue(now time.Time, lease time.Duration, limit int) ([]*models.WebhookDelivery, error) {
+	deliveries := []*models.WebhookDelivery{}
+	err := r.Db.Raw(
+		`UPDATE webhook_deliveries SET next_attempt_at = ?
+		WHERE id IN (
+			SELECT id FROM webhook_deliveries
+			WHERE status = ? AND next_attempt_at <= ?
+			ORDER BY next_attempt_at, id
+			LIMIT ?
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING *`,
+		now.Add(lease), models.DeliveryPending, now, limit,
+	).Scan(&deliveries).Error
+	if err != nil {
+		return nil, err
+	}
+	return deliveries, nil
+}
+
+func (r *GormWebhookDeliveryRepository) Save(delivery *models.WebhookDelivery) error {
+	return r.Db.Save(delivery).Error
+}
+
+func (r *GormWebhookDeliveryRepository) FindByWebhookID(webhookId uint) ([]*models.WebhookDelivery, error) {
+	deliveries := []*models.WebhookDelivery{}
+	if err := r.Db.Where("webhook_id = ?", webhookId).Order("id DESC").Find(&deliveries).Error; err != nil {
+		return nil, err
+	}
+	return deliveries, nil
+}
@@ -0,0 +1,67 @@
+package repositories
+
+import (
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/pabloeclair/rest-subscription/internal/sbscrb/models"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func TestWebhookDelete(t *testing.T) {
+	t.Run("Success", func(t *testing.T) {
+		db, mock, err := NewMock()
+		assert.NoError(t, err)
+		repo := GormWebhookRepository{Db: db}
+
+		mock.ExpectBegin()
+		mock.ExpectExec(`DELETE FROM "webhooks" WHERE "webhooks"."id" = \$1`).
+			WithArgs(1).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec(`DELETE FROM "webhook_deliveries" WHERE webhook_id = \$1`).
+			WithArgs(1).
+			WillReturnResult(sqlmock.NewResult(0, 3))
+		mock.ExpectCommit()
+
+		err = repo.Delete(1)
+		assert.NoError(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("ErrRecordNotFound", func(t *testing.T) {
+		db, mock, err := NewMock()
+		assert.NoError(t, err)
+		repo := GormWebhookRepository{Db: db}
+
+		mock.ExpectBegin()
+		mock.ExpectExec(`DELETE FROM "webhooks" WHERE "webhooks"."id" = \$1`).
+			WithArgs(1).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectRollback()
+
+		err = repo.Delete(1)
+		assert.Equal(t, gorm.ErrRecordNotFound, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestWebhookDeliveryClaimDue(t *testing.T) {
+	db, mock, err := NewMock()
+	assert.NoError(t, err)
+	repo := GormWebhookDeliveryRepository{Db: db}
+	now := time.Date(2025, time.July, 26, 0, 0, 0, 0, time.UTC)
+
+	mock.ExpectQuery(`UPDATE webhook_deliveries SET next_attempt_at = \$1 .* FOR UPDATE SKIP LOCKED .* RETURNING \*`).
+		WithArgs(now.Add(time.Minute), models.DeliveryPending, now, 10).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "webhook_id", "event_id", "event_type", "payload", "status", "attempts"}).
+			AddRow(7, 1, "e1", "subscribe.created", "{}", models.DeliveryPending, 0))
+
+	deliveries, err := repo.ClaimDue(now, time.Minute, 10)
+	assert.NoError(t, err)
+	assert.Len(t, deliveries, 1)
+	assert.Equal(t, uint(7), deliveries[0].ID)
+	assert.Equal(t, "e1", deliveries[0].EventID)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
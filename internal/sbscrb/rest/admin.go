@@ -0,0 +1,44 @@
+package rest
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/pabloeclair/rest-subscription/internal/sbscrb/models"
+	"github.com/pabloeclair/rest-subscription/internal/sbscrb/scheduler"
+	"github.com/pabloeclair/rest-subscription/internal/sbscrb/workers"
+)
+
+func ExpirationStatus(worker *workers.ExpirationWorker) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		b, err := json.Marshal(worker.Status())
+		if err != nil {
+			errDto := models.NewFullExceptionDto(
+				http.StatusInternalServerError,
+				"Failed to marshal a response",
+				err.Error(),
+			)
+			errDto.Write(w)
+			return
+		}
+
+		w.Write(b)
+	}
+}
+
+func JobsMetrics(s *scheduler.Scheduler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		b, err := json.Marshal(s.Metrics())
+		if err != nil {
+			errDto := models.NewFullExceptionDto(
+				http.StatusInternalServerError,
+				"Failed to marshal a response",
+				err.Error(),
+			)
+			errDto.Write(w)
+			return
+		}
+
+		w.Write(b)
+	}
+}
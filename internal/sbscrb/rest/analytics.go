@@ -0,0 +1,129 @@
+package rest
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/pabloeclair/rest-subscription/internal/sbscrb/models"
+	"github.com/pabloeclair/rest-subscription/internal/sbscrb/repositories"
+)
+
+// the largest number of buckets of an analytics response
+const maxAnalyticsBuckets = 1000
+
+func parseAnalyticsDate(s string) (time.Time, error) {
+	if t, err := time.Parse(time.DateOnly, s); err == nil {
+		return t, nil
+	}
+	return time.Parse("2006-01", s)
+}
+
+// analyticsQuery reads the 'from', 'to', 'bucket', 'service_name' and
+// 'by_service' query parameters. By default it covers the last 12 months.
+func analyticsQuery(r *http.Request) (models.AnalyticsQuery, error) {
+	queryParams := r.URL.Query()
+	now := time.Now().UTC()
+	q := models.AnalyticsQuery{
+		From:        time.Date(now.Year()-1, now.Month()+1, 1, 0, 0, 0, 0, time.UTC),
+		To:          now,
+		Bucket:      strings.ToLower(queryParams.Get("bucket")),
+		ServiceName: queryParams.Get("service_name"),
+	}
+	var err error
+
+	if q.Bucket == "" {
+		q.Bucket = "month"
+	}
+	bucket, ok := models.AnalyticsBuckets[q.Bucket]
+	if !ok {
+		return q, errors.New("Incorrect the 'bucket' parameter. The 'bucket' can only be empty or have the values 'day', 'week' and 'month'")
+	}
+	if v := queryParams.Get("from"); v != "" {
+		if q.From, err = parseAnalyticsDate(v); err != nil {
+			return q, errors.New("Incorrect the 'from' parameter. Please use the format 'YYYY-MM-DD' or 'YYYY-MM'")
+		}
+	}
+	if v := queryParams.Get("to"); v != "" {
+		if q.To, err = parseAnalyticsDate(v); err != nil {
+			return q, errors.New("Incorrect the 'to' parameter. Please use the format 'YYYY-MM-DD' or 'YYYY-MM'")
+		}
+	}
+	if q.To.Before(q.From) {
+		return q, errors.New("The 'from' parameter must not be after the 'to' parameter")
+	}
+	if bucket.Count(q.From, q.To) > maxAnalyticsBuckets {
+		return q, fmt.Errorf("The period is too long for the '%s' bucket. The response is limited to %d buckets", q.Bucket, maxAnalyticsBuckets)
+	}
+	switch v := strings.ToLower(queryParams.Get("by_service")); v {
+	case "", "false":
+	case "true":
+		q.ByService = true
+	default:
+		return q, errors.New("Incorrect the 'by_service' parameter. The 'by_service' can only be empty or have the values 'true' and 'false'")
+	}
+	return q, nil
+}
+
+// analyticsHandler wraps a query of the analytics repository into a handler.
+func analyticsHandler[T any](name string, query func(repo *repositories.GormAnalyticsRepository, q models.AnalyticsQuery) ([]T, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var (
+			//the error for response
+			errDto models.FullExceptionDto
+		)
+
+		// query validate
+		q, err := analyticsQuery(r)
+		if err != nil {
+			errDto = models.NewFullExceptionDto(
+				http.StatusBadRequest,
+				err.Error(),
+				"",
+			)
+			errDto.Write(w)
+			return
+		}
+
+		db, err := openDB(w, r)
+		if err != nil {
+			return
+		}
+		repo := &repositories.GormAnalyticsRepository{Db: db}
+
+		// aggregate operation
+		points, err := query(repo, q)
+		if err != nil {
+			errDto = models.NewFullExceptionDto(
+				http.StatusInternalServerError,
+				fmt.Sprintf("Failed to calculate the %s", name),
+				err.Error(),
+			)
+			errDto.Write(w)
+			return
+		}
+
+		// result
+		b, err := json.Marshal(points)
+		if err != nil {
+			errDto = models.NewFullExceptionDto(
+				http.StatusInternalServerError,
+				"Failed to marshal a response",
+				err.Error(),
+			)
+			errDto.Write(w)
+			return
+		}
+
+		w.Write(b)
+	}
+}
+
+var (
+	GetAnalyticsMRR       = analyticsHandler("monthly recurring revenue", (*repositories.GormAnalyticsRepository).MRR)
+	GetAnalyticsMovements = analyticsHandler("new and churned subscribes", (*repositories.GormAnalyticsRepository).Movements)
+	GetAnalyticsRetention = analyticsHandler("net revenue retention", (*repositories.GormAnalyticsRepository).Retention)
+)
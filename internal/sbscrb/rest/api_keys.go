@@ -0,0 +1,203 @@
+package rest
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pabloeclair/rest-subscription/internal/sbscrb/auth"
+	"github.com/pabloeclair/rest-subscription/internal/sbscrb/models"
+	"github.com/pabloeclair/rest-subscription/internal/sbscrb/repositories"
+	"gorm.io/gorm"
+)
+
+// CreateAPIKey generates a key with the requested scopes and stores its
+// hash in the repository. The key itself is returned only once.
+func CreateAPIKey(repo repositories.APIKeyRepository, name string, scopes []string) (*models.APIKeyDto, error) {
+	keyDto := &models.APIKeyDto{Name: name, Scopes: scopes}
+	if err := keyDto.Validate(); err != nil {
+		return nil, err
+	}
+
+	key, hash := auth.NewAPIKey()
+	keyDb := &models.APIKey{
+		Name:   name,
+		Prefix: key[:len(auth.APIKeyPrefix)+8],
+		Hash:   hash,
+		Scopes: strings.Join(scopes, ","),
+	}
+	if err := repo.Create(keyDb); err != nil {
+		return nil, err
+	}
+
+	keyDto = keyDb.ToDto()
+	keyDto.Key = key
+	return keyDto, nil
+}
+
+func PostAPIKey(w http.ResponseWriter, r *http.Request) {
+	var (
+		//the key from request
+		keyDto models.APIKeyDto
+		//the error for response
+		errDto models.FullExceptionDto
+	)
+
+	// headers validation
+	if r.Header.Get("Content-Type") != "application/json; charset=utf-8" {
+		errDto = models.NewFullExceptionDto(
+			http.StatusBadRequest,
+			"The request body must be in JSON format",
+			"",
+		)
+		errDto.Write(w)
+		return
+	}
+
+	d := json.NewDecoder(r.Body)
+	if err := d.Decode(&keyDto); err != nil {
+		writeBodyError(w, "Incorrect JSON body", err)
+		return
+	}
+
+	// body validation
+	if err := keyDto.Validate(); err != nil {
+		errDto = models.NewFullExceptionDto(
+			http.StatusBadRequest,
+			err.Error(),
+			"",
+		)
+		errDto.Write(w)
+		return
+	}
+
+	db, err := openDB(w, r)
+	if err != nil {
+		return
+	}
+
+	// create operation
+	created, err := CreateAPIKey(&repositories.GormAPIKeyRepository{Db: db}, keyDto.Name, keyDto.Scopes)
+	if err != nil {
+		errDto = models.NewFullExceptionDto(
+			http.StatusInternalServerError,
+			"Failed to create the API key",
+			err.Error(),
+		)
+		errDto.Write(w)
+		return
+	}
+
+	// result
+	b, err := json.Marshal(created)
+	if err != nil {
+		errDto = models.NewFullExceptionDto(
+			http.StatusInternalServerError,
+			"Failed to marshal a response",
+			err.Error(),
+		)
+		errDto.Write(w)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	w.Write(b)
+}
+
+func GetAPIKeys(w http.ResponseWriter, r *http.Request) {
+	var (
+		//the keys for response
+		keysDto = []*models.APIKeyDto{}
+		//the error for response
+		errDto models.FullExceptionDto
+	)
+
+	db, err := openDB(w, r)
+	if err != nil {
+		return
+	}
+	repo := repositories.GormAPIKeyRepository{Db: db}
+
+	// find operation
+	keys, err := repo.FindAll()
+	if err != nil {
+		errDto = models.NewFullExceptionDto(
+			http.StatusInternalServerError,
+			"Failed to get the API key list",
+			err.Error(),
+		)
+		errDto.Write(w)
+		return
+	}
+
+	// result
+	for _, v := range keys {
+		keysDto = append(keysDto, v.ToDto())
+	}
+
+	b, err := json.Marshal(&keysDto)
+	if err != nil {
+		errDto = models.NewFullExceptionDto(
+			http.StatusInternalServerError,
+			"Failed to marshal a response",
+			err.Error(),
+		)
+		errDto.Write(w)
+		return
+	}
+
+	w.Write(b)
+}
+
+func RevokeAPIKey(w http.ResponseWriter, r *http.Request) {
+	var (
+		//the error for response
+		errDto models.FullExceptionDto
+	)
+
+	// query validate
+	idInt, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		errDto = models.NewFullExceptionDto(
+			http.StatusBadRequest,
+			"Incorrect id in the URL path. Please specify a positive number",
+			err.Error(),
+		)
+		errDto.Write(w)
+		return
+	}
+
+	db, err := openDB(w, r)
+	if err != nil {
+		return
+	}
+	repo := repositories.GormAPIKeyRepository{Db: db}
+
+	// revoke operation
+	err = repo.Revoke(uint(idInt), time.Now())
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		errDto = models.NewFullExceptionDto(
+			http.StatusNotFound,
+			fmt.Sprintf("The active API key with id = %d is not found", idInt),
+			"",
+		)
+		errDto.Write(w)
+		return
+	} else if err != nil {
+		errDto = models.NewFullExceptionDto(
+			http.StatusInternalServerError,
+			fmt.Sprintf("Failed to revoke the API key with id = %d", idInt),
+			err.Error(),
+		)
+		errDto.Write(w)
+		return
+	}
+
+	// result
+	w.WriteHeader(http.StatusNoContent)
+	w.Header().Del("Content-Type")
+}
@@ -0,0 +1,212 @@
+package rest
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/pabloeclair/rest-subscription/internal/sbscrb/auth"
+	"github.com/pabloeclair/rest-subscription/internal/sbscrb/models"
+	"github.com/pabloeclair/rest-subscription/internal/sbscrb/repositories"
+	"gorm.io/gorm"
+)
+
+var (
+	// Verifier validates the bearer tokens, it is configured by Init
+	Verifier = &auth.Verifier{}
+	// Policy is the default policy or the one from 'RBAC_POLICY_FILE'
+	Policy = auth.DefaultPolicy()
+	// CertificateScopes are the scopes of the client certificates by their
+	// common name, it is configured by Init
+	CertificateScopes = map[string][]string{}
+)
+
+// the last-used timestamp of an API key is written at most once per interval
+const apiKeyTouchInterval = time.Minute
+
+// AuthMiddleware rejects the requests without a valid bearer token or API
+// key and puts the caller into the request context. The API key is read from the 'X-API-Key' header or the bearer
+// token with the 'sk_' prefix. A request without them may be authenticated
+// by a verified TLS client certificate.
+func AuthMiddleware(verifier *auth.Verifier, keys repositories.APIKeyRepository, handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if key := r.Header.Get("X-API-Key"); key != "" {
+			token, ok = key, true
+		}
+		if (!ok || token == "") && r.TLS != nil && len(r.TLS.VerifiedChains) > 0 {
+			if p := certificatePrincipal(r.TLS.VerifiedChains[0][0]); p != nil {
+				handler.ServeHTTP(w, r.WithContext(auth.WithPrincipal(r.Context(), p)))
+				return
+			}
+		}
+		if !ok || token == "" {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="api"`)
+			errDto := models.NewFullExceptionDto(
+				http.StatusUnauthorized,
+				"The bearer token or the API key is required",
+				"",
+			)
+			errDto.Write(w)
+			return
+		}
+
+		var (
+			p   *auth.Principal
+			err error
+		)
+		if auth.IsAPIKey(token) {
+			p, err = authenticateAPIKey(keys, token)
+		} else {
+			var claims *auth.Claims
+			if claims, err = verifier.Verify(token); err == nil {
+				p = &auth.Principal{Subject: claims.Subject, Roles: claims.Roles}
+				if len(p.Roles) == 0 {
+					p.Roles = []string{auth.RoleUser}
+				}
+			}
+		}
+		if errors.Is(err, errAPIKeysUnavailable) {
+			errDto := models.NewFullExceptionDto(
+				http.StatusInternalServerError,
+				"Failed to check the API key",
+				err.Error(),
+			)
+			errDto.Write(w)
+			return
+		} else if err != nil {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="api", error="invalid_token"`)
+			errDto := models.NewFullExceptionDto(
+				http.StatusUnauthorized,
+				"The bearer token or the API key is invalid",
+				err.Error(),
+			)
+			errDto.Write(w)
+			return
+		}
+
+		handler.ServeHTTP(w, r.WithContext(auth.WithPrincipal(r.Context(), p)))
+	})
+}
+
+var errAPIKeysUnavailable = errors.New("the API keys are unavailable")
+
+func authenticateAPIKey(keys repositories.APIKeyRepository, token string) (*auth.Principal, error) {
+	key, err := keys.FindByHash(auth.HashAPIKey(token))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, errors.New("unknown API key")
+	} else if err != nil {
+		return nil, fmt.Errorf("%w: %s", errAPIKeysUnavailable, err.Error())
+	}
+	if key.RevokedAt != nil {
+		return nil, errors.New("the API key is revoked")
+	}
+	if err := keys.Touch(key.ID, time.Now(), apiKeyTouchInterval); err != nil {
+		return nil, fmt.Errorf("%w: %s", errAPIKeysUnavailable, err.Error())
+	}
+	return &auth.Principal{
+		Subject: fmt.Sprintf("api-key:%d", key.ID),
+		Roles:   auth.ScopeRoles(key.ScopeList()),
+		APIKey:  true,
+	}, nil
+}
+
+// certificatePrincipal authenticates a service by its client certificate
+// with the scopes configured for its common name. The fields of the
+// certificate are not trusted for the scopes, any client of the CA could
+// set them. It returns nil for a certificate without scopes.
+func certificatePrincipal(cert *x509.Certificate) *auth.Principal {
+	scopes, ok := CertificateScopes[cert.Subject.CommonName]
+	if !ok {
+		return nil
+	}
+	return &auth.Principal{
+		Subject: "cert:" + cert.Subject.CommonName,
+		Roles:   auth.ScopeRoles(scopes),
+		APIKey:  true,
+	}
+}
+
+// RBACMiddleware grants the permissions to the caller by its roles and
+// rejects the request if the route pattern of the mux needs a permission
+// the caller does not have. The routes missing in the policy are denied,
+// except the catch-all pattern.
+func RBACMiddleware(policy *auth.Policy, mux *http.ServeMux, handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p := principal(r)
+		policy.Grant(p)
+
+		_, pattern := mux.Handler(r)
+		permission, ok := policy.Routes[pattern]
+		switch {
+		case pattern == "/" || pattern == "":
+			handler.ServeHTTP(w, r)
+			return
+		case !ok:
+			log.Printf("rbac: deny %s %v %q: the route has no permission in the policy", p.Subject, p.Roles, pattern)
+		case p.Has(permission) || p.Has(permission+auth.AnySuffix):
+			log.Printf("rbac: allow %s %v %q: %s", p.Subject, p.Roles, pattern, permission)
+			handler.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), permissionKey{}, permission)))
+			return
+		default:
+			log.Printf("rbac: deny %s %v %q: %s is required", p.Subject, p.Roles, pattern, permission)
+		}
+
+		errDto := models.NewFullExceptionDto(
+			http.StatusForbidden,
+			fmt.Sprintf("The permission '%s' is required", permission),
+			"",
+		)
+		if !ok {
+			errDto = models.NewFullExceptionDto(
+				http.StatusForbidden,
+				"The access to the route is not configured",
+				"",
+			)
+		}
+		errDto.Write(w)
+	})
+}
+
+type permissionKey struct{}
+
+// principal returns the caller of the request, a request without a caller
+// gets a principal without any access.
+func principal(r *http.Request) *auth.Principal {
+	if p, ok := auth.FromContext(r.Context()); ok {
+		return p
+	}
+	return &auth.Principal{}
+}
+
+// canAccessAny reports whether the caller may access the subscribes of any
+// user on the route, otherwise only the own subscribes are accessible.
+func canAccessAny(r *http.Request) bool {
+	permission, _ := r.Context().Value(permissionKey{}).(string)
+	return permission != "" && principal(r).Has(permission+auth.AnySuffix)
+}
+
+func canAccess(r *http.Request, userId string) bool {
+	p := principal(r)
+	return canAccessAny(r) || (p.Subject != "" && !p.APIKey && p.Subject == userId)
+}
+
+// authorize writes the 403 error and returns false if the caller may not
+// access the subscribes of the user.
+func authorize(w http.ResponseWriter, r *http.Request, userId string) bool {
+	if canAccess(r, userId) {
+		return true
+	}
+	errDto := models.NewFullExceptionDto(
+		http.StatusForbidden,
+		fmt.Sprintf("Access to the subscribes of the user '%s' is forbidden", userId),
+		"",
+	)
+	errDto.Write(w)
+	return false
+}
@@ -0,0 +1,173 @@
+package rest
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/pabloeclair/rest-subscription/internal/sbscrb/auth"
+	"github.com/pabloeclair/rest-subscription/internal/sbscrb/models"
+	"github.com/pabloeclair/rest-subscription/internal/sbscrb/repositories"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+type memoryAPIKeyRepository struct {
+	repositories.APIKeyRepository
+	keys []*models.APIKey
+}
+
+func (r *memoryAPIKeyRepository) Create(key *models.APIKey) error {
+	key.ID = uint(len(r.keys) + 1)
+	r.keys = append(r.keys, key)
+	return nil
+}
+
+func (r *memoryAPIKeyRepository) FindByHash(hash string) (*models.APIKey, error) {
+	for _, k := range r.keys {
+		if k.Hash == hash {
+			return k, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (r *memoryAPIKeyRepository) Touch(id uint, at time.Time, interval time.Duration) error {
+	r.keys[id-1].LastUsedAt = &at
+	return nil
+}
+
+func hs256(secret, payload string) string {
+	signed := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`)) + "." +
+		base64.RawURLEncoding.EncodeToString([]byte(payload))
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(signed))
+	return signed + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func TestAuthMiddleware(t *testing.T) {
+	verifier := &auth.Verifier{
+		Secret: []byte("secret"),
+		Now:    func() time.Time { return time.Unix(1700000000, 0) },
+	}
+	keys := &memoryAPIKeyRepository{}
+
+	ownerOnly := func(w http.ResponseWriter, r *http.Request) {
+		if authorize(w, r, r.PathValue("user_id")) {
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}
+	noContent := func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/v1/users/{user_id}/subscribes", ownerOnly)
+	mux.HandleFunc("POST /api/v1/users/{user_id}/subscribes", ownerOnly)
+	mux.HandleFunc("DELETE /api/v1/users/{user_id}/subscribes", ownerOnly)
+	mux.HandleFunc("GET /api/v1/users/{user_id}/spending", ownerOnly)
+	mux.HandleFunc("GET /api/v1/admin/jobs", noContent)
+	mux.HandleFunc("GET /api/v1/unlisted", noContent)
+	handler := AuthMiddleware(verifier, keys, RBACMiddleware(auth.DefaultPolicy(), mux, mux))
+
+	serve := func(method, path, token string) int {
+		r := httptest.NewRequest(method, path, nil)
+		if auth.IsAPIKey(token) {
+			r.Header.Set("X-API-Key", token)
+		} else if token != "" {
+			r.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w.Code
+	}
+	user := hs256("secret", `{"sub":"user-1","exp":1700003600}`)
+	support := hs256("secret", `{"sub":"user-2","exp":1700003600,"roles":["support"]}`)
+	admin := hs256("secret", `{"sub":"user-3","exp":1700003600,"roles":["admin"]}`)
+	finance := hs256("secret", `{"sub":"user-4","exp":1700003600,"roles":["finance"]}`)
+
+	t.Run("MissingToken", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodGet, "/api/v1/users/user-1/subscribes", nil)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.Contains(t, w.Header().Get("WWW-Authenticate"), "Bearer")
+	})
+
+	t.Run("InvalidToken", func(t *testing.T) {
+		token := hs256("other", `{"sub":"user-1","exp":1700003600}`)
+		assert.Equal(t, http.StatusUnauthorized, serve(http.MethodGet, "/api/v1/users/user-1/subscribes", token))
+	})
+
+	t.Run("User", func(t *testing.T) {
+		assert.Equal(t, http.StatusNoContent, serve(http.MethodGet, "/api/v1/users/user-1/subscribes", user))
+		assert.Equal(t, http.StatusForbidden, serve(http.MethodGet, "/api/v1/users/user-9/subscribes", user))
+		assert.Equal(t, http.StatusForbidden, serve(http.MethodGet, "/api/v1/admin/jobs", user))
+	})
+
+	t.Run("Support", func(t *testing.T) {
+		assert.Equal(t, http.StatusNoContent, serve(http.MethodGet, "/api/v1/users/user-9/subscribes", support))
+		assert.Equal(t, http.StatusForbidden, serve(http.MethodDelete, "/api/v1/users/user-9/subscribes", support))
+	})
+
+	t.Run("Finance", func(t *testing.T) {
+		assert.Equal(t, http.StatusNoContent, serve(http.MethodGet, "/api/v1/users/user-4/spending", finance))
+		assert.Equal(t, http.StatusForbidden, serve(http.MethodGet, "/api/v1/users/user-9/spending", finance))
+		assert.Equal(t, http.StatusForbidden, serve(http.MethodGet, "/api/v1/users/user-9/subscribes", finance))
+	})
+
+	t.Run("Admin", func(t *testing.T) {
+		assert.Equal(t, http.StatusNoContent, serve(http.MethodDelete, "/api/v1/users/user-9/subscribes", admin))
+		assert.Equal(t, http.StatusNoContent, serve(http.MethodGet, "/api/v1/admin/jobs", admin))
+	})
+
+	t.Run("RouteNotInPolicy", func(t *testing.T) {
+		assert.Equal(t, http.StatusForbidden, serve(http.MethodGet, "/api/v1/unlisted", admin))
+	})
+
+	t.Run("APIKeyScopes", func(t *testing.T) {
+		reader, err := CreateAPIKey(keys, "batch", []string{models.ScopeSubscribesRead})
+		assert.NoError(t, err)
+		assert.True(t, auth.IsAPIKey(reader.Key))
+
+		assert.Equal(t, http.StatusNoContent, serve(http.MethodGet, "/api/v1/users/user-9/subscribes", reader.Key))
+		assert.Equal(t, http.StatusForbidden, serve(http.MethodPost, "/api/v1/users/user-9/subscribes", reader.Key))
+		assert.Equal(t, http.StatusForbidden, serve(http.MethodGet, "/api/v1/admin/jobs", reader.Key))
+		assert.NotNil(t, keys.keys[reader.ID-1].LastUsedAt)
+	})
+
+	t.Run("RevokedAPIKey", func(t *testing.T) {
+		key, err := CreateAPIKey(keys, "old", []string{models.ScopeAdmin})
+		assert.NoError(t, err)
+		revokedAt := time.Now()
+		keys.keys[key.ID-1].RevokedAt = &revokedAt
+
+		assert.Equal(t, http.StatusUnauthorized, serve(http.MethodGet, "/api/v1/admin/jobs", key.Key))
+	})
+
+	t.Run("ClientCertificate", func(t *testing.T) {
+		scopes := CertificateScopes
+		CertificateScopes = map[string][]string{"billing": {models.ScopeSubscribesRead}}
+		defer func() { CertificateScopes = scopes }()
+
+		serveCert := func(method, path, commonName string, units ...string) int {
+			r := httptest.NewRequest(method, path, nil)
+			cert := &x509.Certificate{Subject: pkix.Name{CommonName: commonName, OrganizationalUnit: units}}
+			r.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert}}}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+			return w.Code
+		}
+		assert.Equal(t, http.StatusNoContent, serveCert(http.MethodGet, "/api/v1/users/user-9/subscribes", "billing"))
+		assert.Equal(t, http.StatusForbidden, serveCert(http.MethodPost, "/api/v1/users/user-9/subscribes", "billing"))
+		// the organizational units do not grant the scopes
+		assert.Equal(t, http.StatusForbidden, serveCert(http.MethodGet, "/api/v1/admin/jobs", "billing", models.ScopeAdmin))
+		assert.Equal(t, http.StatusUnauthorized, serveCert(http.MethodGet, "/api/v1/admin/jobs", "intruder", models.ScopeAdmin))
+	})
+}
@@ -0,0 +1,70 @@
+package rest
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/pabloeclair/rest-subscription/internal/sbscrb/models"
+)
+
+// BodyLimits are the largest request bodies in bytes.
+type BodyLimits struct {
+	Default int64
+	// Routes override the default by the route pattern
+	Routes map[string]int64
+}
+
+// BodyLimit is configured by Configure.
+var BodyLimit = &BodyLimits{
+	Default: 1 << 20,
+	Routes:  map[string]int64{"POST /api/v1/subscribes/import": 32 << 20},
+}
+
+func (l *BodyLimits) For(pattern string) int64 {
+	if limit, ok := l.Routes[pattern]; ok {
+		return limit
+	}
+	return l.Default
+}
+
+// BodyLimitMiddleware rejects the requests whose body is larger than the
+// limit of the route pattern of the mux. The declared length is checked
+// at once, the body of unknown length fails on reading.
+func BodyLimitMiddleware(limits *BodyLimits, mux *http.ServeMux, handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, pattern := mux.Handler(r)
+		limit := limits.For(pattern)
+		if r.ContentLength > limit {
+			writeBodyTooLarge(w, limit)
+			return
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, limit)
+		handler.ServeHTTP(w, r)
+	})
+}
+
+func writeBodyTooLarge(w http.ResponseWriter, limit int64) {
+	errDto := models.NewFullExceptionDto(
+		http.StatusRequestEntityTooLarge,
+		fmt.Sprintf("The request body must not be larger than %d bytes", limit),
+		"",
+	)
+	errDto.Write(w)
+}
+
+// writeBodyError writes 413 if reading the body has failed on the limit
+// and 400 with the message otherwise.
+func writeBodyError(w http.ResponseWriter, message string, err error) {
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		writeBodyTooLarge(w, maxBytesErr.Limit)
+		return
+	}
+	errDto := models.NewFullExceptionDto(
+		http.StatusBadRequest,
+		message,
+		err.Error(),
+	)
+	errDto.Write(w)
+}
@@ -0,0 +1,64 @@
+package rest
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/pabloeclair/rest-subscription/internal/sbscrb/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBodyLimitMiddleware(t *testing.T) {
+	mux := http.NewServeMux()
+	decode := func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			writeBodyError(w, "Incorrect JSON body", err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+	mux.HandleFunc("POST /api/v1/subscribes", decode)
+	mux.HandleFunc("POST /api/v1/subscribes/import", decode)
+	limits := &BodyLimits{Default: 16, Routes: map[string]int64{"POST /api/v1/subscribes/import": 64}}
+	handler := BodyLimitMiddleware(limits, mux, mux)
+
+	serve := func(path string, body io.Reader) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPost, path, body)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w
+	}
+	large := `{"service_name":"Kinopoisk"}`
+
+	t.Run("UnderLimit", func(t *testing.T) {
+		assert.Equal(t, http.StatusNoContent, serve("/api/v1/subscribes", strings.NewReader(`{"a":1}`)).Code)
+	})
+
+	t.Run("ContentLength", func(t *testing.T) {
+		w := serve("/api/v1/subscribes", strings.NewReader(large))
+		assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+
+		var errDto models.FullExceptionDto
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &errDto))
+		assert.Equal(t, "The request body must not be larger than 16 bytes", errDto.ErrorMessage)
+	})
+
+	t.Run("UnknownLength", func(t *testing.T) {
+		// the reader hides the length of the body
+		w := serve("/api/v1/subscribes", io.MultiReader(strings.NewReader(large)))
+		assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+	})
+
+	t.Run("RouteLimit", func(t *testing.T) {
+		assert.Equal(t, http.StatusNoContent, serve("/api/v1/subscribes/import", strings.NewReader(large)).Code)
+	})
+
+	t.Run("IncorrectJSON", func(t *testing.T) {
+		assert.Equal(t, http.StatusBadRequest, serve("/api/v1/subscribes", strings.NewReader(`{`)).Code)
+	})
+}
@@ -0,0 +1,137 @@
+package rest
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+	"github.com/pabloeclair/rest-subscription/internal/sbscrb/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNegotiateEncoding(t *testing.T) {
+	encodings := []string{"zstd", "br", "gzip"}
+	for header, expected := range map[string]string{
+		"":                        "",
+		"gzip":                    "gzip",
+		"gzip, deflate, br":       "br",
+		"gzip, deflate, br, zstd": "zstd",
+		"gzip;q=1.0, br;q=0.5":    "gzip",
+		"zstd;q=0, GZIP":          "gzip",
+		"*":                       "zstd",
+		"*;q=0.1, gzip":           "gzip",
+		"identity":                "",
+		"deflate, compress;q=0.5": "",
+	} {
+		assert.Equal(t, expected, negotiateEncoding(header, encodings), header)
+	}
+}
+
+func decode(t *testing.T, encoding string, body []byte) string {
+	var r io.Reader
+	switch encoding {
+	case "gzip":
+		gr, err := gzip.NewReader(bytes.NewReader(body))
+		require.NoError(t, err)
+		r = gr
+	case "br":
+		r = brotli.NewReader(bytes.NewReader(body))
+	case "zstd":
+		zr, err := zstd.NewReader(bytes.NewReader(body))
+		require.NoError(t, err)
+		defer zr.Close()
+		r = zr
+	default:
+		r = bytes.NewReader(body)
+	}
+	b, err := io.ReadAll(r)
+	require.NoError(t, err)
+	return string(b)
+}
+
+func TestCompressionMiddleware(t *testing.T) {
+	large := `[` + strings.Repeat(`{"service_name":"Yandex Plus","price":400},`, 100) + `{}]`
+	var body string
+	var code int
+	handler := CompressionMiddleware(
+		&Compression{Encodings: []string{"zstd", "br", "gzip"}, MinSize: 1024},
+		LoggingMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("ETag", `"1"`)
+			if code != 0 {
+				errDto := models.NewFullExceptionDto(code, strings.Repeat("failed ", 200), "")
+				errDto.Write(w)
+				return
+			}
+			w.Write([]byte(body))
+		})),
+	)
+
+	request := func(acceptEncoding string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, "/api/v1/subscribe", nil)
+		r.Header.Set("Accept-Encoding", acceptEncoding)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w
+	}
+
+	for _, encoding := range []string{"gzip", "br", "zstd"} {
+		t.Run(encoding, func(t *testing.T) {
+			body, code = large, 0
+			w := request(encoding)
+			assert.Equal(t, encoding, w.Header().Get("Content-Encoding"))
+			assert.Equal(t, "Accept-Encoding", w.Header().Get("Vary"))
+			assert.Equal(t, `W/"1"`, w.Header().Get("ETag"))
+			assert.Less(t, w.Body.Len(), len(large))
+			assert.Equal(t, large, decode(t, encoding, w.Body.Bytes()))
+		})
+	}
+
+	t.Run("Small", func(t *testing.T) {
+		body, code = `{"id":1}`, 0
+		w := request("gzip")
+		assert.Empty(t, w.Header().Get("Content-Encoding"))
+		assert.Equal(t, "Accept-Encoding", w.Header().Get("Vary"))
+		assert.Equal(t, `{"id":1}`, w.Body.String())
+	})
+
+	t.Run("NotAccepted", func(t *testing.T) {
+		body, code = large, 0
+		w := request("")
+		assert.Empty(t, w.Header().Get("Content-Encoding"))
+		assert.Equal(t, large, w.Body.String())
+	})
+
+	t.Run("ErrorRewrittenByLogging", func(t *testing.T) {
+		code = http.StatusBadRequest
+		w := request("gzip")
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, decode(t, w.Header().Get("Content-Encoding"), w.Body.Bytes()), `"status_code":400`)
+	})
+}
+
+func TestCompressionFlush(t *testing.T) {
+	handler := CompressionMiddleware(
+		&Compression{Encodings: []string{"gzip"}, MinSize: 1024},
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/csv")
+			w.Write([]byte("id,service_name\n"))
+			http.NewResponseController(w).Flush()
+			w.Write([]byte("1,Yandex Plus\n"))
+		}),
+	)
+
+	r := httptest.NewRequest(http.MethodGet, "/api/v1/subscribes/export", nil)
+	r.Header.Set("Accept-Encoding", "gzip")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	assert.True(t, w.Flushed)
+	assert.Equal(t, "gzip", w.Header().Get("Content-Encoding"))
+	assert.Equal(t, "id,service_name\n1,Yandex Plus\n", decode(t, "gzip", w.Body.Bytes()))
+}
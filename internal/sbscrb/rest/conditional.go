@@ -0,0 +1,103 @@
+package rest
+
+import (
+	"fmt"
+	"hash/fnv"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/pabloeclair/rest-subscription/internal/sbscrb/models"
+)
+
+// CacheControls are the 'Cache-Control' headers of the GET responses.
+type CacheControls struct {
+	Default string
+	// Routes override the default by the route pattern
+	Routes map[string]string
+}
+
+// CacheControl is configured by Configure. The responses depend on the
+// caller, so they are private and revalidated by default.
+var CacheControl = &CacheControls{
+	Default: "private, no-cache",
+	Routes:  map[string]string{},
+}
+
+func (c *CacheControls) For(pattern string) string {
+	if value, ok := c.Routes[pattern]; ok {
+		return value
+	}
+	return c.Default
+}
+
+// CacheControlMiddleware sets the 'Cache-Control' header of the route
+// pattern of the mux on the GET and HEAD responses.
+func CacheControlMiddleware(c *CacheControls, mux *http.ServeMux, handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet || r.Method == http.MethodHead {
+			_, pattern := mux.Handler(r)
+			if value := c.For(pattern); value != "" {
+				w.Header().Set("Cache-Control", value)
+			}
+		}
+		handler.ServeHTTP(w, r)
+	})
+}
+
+// subscribeETag is the version of the subscribe.
+func subscribeETag(subscribe *models.Subscribe) string {
+	return fmt.Sprintf(`"%d-%x"`, subscribe.ID, subscribe.UpdatedAt.UnixMicro())
+}
+
+// subscribesValidators are the version of the list and the time of its
+// latest change. A removed subscribe changes the version, but not the time.
+func subscribesValidators(subscribes []*models.Subscribe) (string, time.Time) {
+	var lastModified time.Time
+	h := fnv.New64a()
+	for _, subscribe := range subscribes {
+		fmt.Fprintf(h, "%d-%x;", subscribe.ID, subscribe.UpdatedAt.UnixMicro())
+		if subscribe.UpdatedAt.After(lastModified) {
+			lastModified = subscribe.UpdatedAt
+		}
+	}
+	return fmt.Sprintf(`"%d-%x"`, len(subscribes), h.Sum64()), lastModified
+}
+
+// notModified sets the validators of the response and writes 304 if the
+// client has the same version. 'If-Modified-Since' is used only without
+// 'If-None-Match', as it cannot tell the changes within a second.
+func notModified(w http.ResponseWriter, r *http.Request, etag string, lastModified time.Time) bool {
+	h := w.Header()
+	h.Set("ETag", etag)
+	if !lastModified.IsZero() {
+		h.Set("Last-Modified", lastModified.UTC().Format(http.TimeFormat))
+	}
+
+	if ifNoneMatch := r.Header.Get("If-None-Match"); ifNoneMatch != "" {
+		if !etagMatch(ifNoneMatch, etag) {
+			return false
+		}
+	} else {
+		since, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+		if err != nil || lastModified.IsZero() || lastModified.Truncate(time.Second).After(since) {
+			return false
+		}
+	}
+
+	w.WriteHeader(http.StatusNotModified)
+	return true
+}
+
+// etagMatch is the weak comparison of 'If-None-Match', the compression
+// makes the tags weak.
+func etagMatch(ifNoneMatch, etag string) bool {
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,146 @@
+package rest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/pabloeclair/rest-subscription/internal/sbscrb/auth"
+	"github.com/pabloeclair/rest-subscription/internal/sbscrb/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNotModified(t *testing.T) {
+	updatedAt := time.Date(2025, time.July, 26, 10, 30, 15, 500000000, time.UTC)
+	subscribe := &models.Subscribe{ID: 1, UpdatedAt: updatedAt}
+	etag := subscribeETag(subscribe)
+
+	check := func(headers map[string]string) (*httptest.ResponseRecorder, bool) {
+		r := httptest.NewRequest(http.MethodGet, "/api/v1/subscribes/1", nil)
+		for k, v := range headers {
+			r.Header.Set(k, v)
+		}
+		w := httptest.NewRecorder()
+		return w, notModified(w, r, etag, updatedAt)
+	}
+
+	w, ok := check(nil)
+	assert.False(t, ok)
+	assert.Equal(t, etag, w.Header().Get("ETag"))
+	assert.Equal(t, "Sat, 26 Jul 2025 10:30:15 GMT", w.Header().Get("Last-Modified"))
+
+	for name, headers := range map[string]map[string]string{
+		"IfNoneMatch":     {"If-None-Match": etag},
+		"IfNoneMatchWeak": {"If-None-Match": `"0-0", W/` + etag},
+		"IfNoneMatchAny":  {"If-None-Match": "*"},
+		"IfModifiedSince": {"If-Modified-Since": "Sat, 26 Jul 2025 10:30:15 GMT"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			w, ok := check(headers)
+			assert.True(t, ok)
+			assert.Equal(t, http.StatusNotModified, w.Code)
+		})
+	}
+
+	for name, headers := range map[string]map[string]string{
+		"ChangedETag": {"If-None-Match": `"1-0"`},
+		// the tag is checked first
+		"ChangedETagNotModifiedSince": {"If-None-Match": `"1-0"`, "If-Modified-Since": "Sat, 26 Jul 2025 11:00:00 GMT"},
+		"ModifiedSince":               {"If-Modified-Since": "Sat, 26 Jul 2025 10:30:14 GMT"},
+		"IncorrectDate":               {"If-Modified-Since": "yesterday"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			w, ok := check(headers)
+			assert.False(t, ok)
+			assert.Equal(t, http.StatusOK, w.Code)
+		})
+	}
+}
+
+func TestSubscribesValidators(t *testing.T) {
+	first := time.Date(2025, time.July, 26, 0, 0, 0, 0, time.UTC)
+	second := first.Add(time.Hour)
+	subscribes := []*models.Subscribe{{ID: 1, UpdatedAt: second}, {ID: 2, UpdatedAt: first}}
+
+	etag, lastModified := subscribesValidators(subscribes)
+	assert.Equal(t, second, lastModified)
+
+	removed, removedLastModified := subscribesValidators(subscribes[:1])
+	assert.NotEqual(t, etag, removed)
+	assert.Equal(t, lastModified, removedLastModified)
+
+	updated := []*models.Subscribe{{ID: 1, UpdatedAt: second}, {ID: 2, UpdatedAt: first.Add(time.Microsecond)}}
+	changed, _ := subscribesValidators(updated)
+	assert.NotEqual(t, etag, changed)
+
+	empty, emptyLastModified := subscribesValidators(nil)
+	assert.Equal(t, `"0-cbf29ce484222325"`, empty)
+	assert.True(t, emptyLastModified.IsZero())
+}
+
+func TestCacheControlMiddleware(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/v1/subscribes/{id}", func(w http.ResponseWriter, r *http.Request) {})
+	mux.HandleFunc("GET /api/v1/subscribe", func(w http.ResponseWriter, r *http.Request) {})
+	mux.HandleFunc("DELETE /api/v1/subscribes/{id}", func(w http.ResponseWriter, r *http.Request) {})
+	handler := CacheControlMiddleware(&CacheControls{
+		Default: "private, no-cache",
+		Routes:  map[string]string{"GET /api/v1/subscribes/{id}": "private, max-age=60"},
+	}, mux, mux)
+
+	for path, expected := range map[string]string{
+		"GET /api/v1/subscribes/1":    "private, max-age=60",
+		"GET /api/v1/subscribe":       "private, no-cache",
+		"DELETE /api/v1/subscribes/1": "",
+	} {
+		method, target, _ := strings.Cut(path, " ")
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(method, target, nil))
+		assert.Equal(t, expected, w.Header().Get("Cache-Control"), path)
+	}
+}
+
+func TestGetListETag(t *testing.T) {
+	mock := mockDB(t)
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/v1/subscribe", GetList)
+	handler := RBACMiddleware(auth.DefaultPolicy(), mux, mux)
+
+	updatedAt := time.Date(2025, time.July, 26, 0, 0, 0, 0, time.UTC)
+	expectList := func(updatedAt time.Time) {
+		mock.ExpectQuery(`SELECT \* FROM "subscribes"`).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "service_name", "price", "user_id", "start_date", "updated_at"}).
+				AddRow(1, "Kinopoisk", 399, "user-1", updatedAt, updatedAt).
+				AddRow(2, "Kinopoisk", 199, "user-2", updatedAt, updatedAt))
+	}
+	serve := func(etag string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, "/api/v1/subscribe", nil)
+		r = r.WithContext(auth.WithPrincipal(r.Context(), &auth.Principal{Subject: "user-9", Roles: []string{"support"}}))
+		if etag != "" {
+			r.Header.Set("If-None-Match", etag)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w
+	}
+
+	expectList(updatedAt)
+	w := serve("")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"user_id":"user-2"`)
+	etag := w.Header().Get("ETag")
+	assert.NotEqual(t, `"0-cbf29ce484222325"`, etag)
+
+	expectList(updatedAt)
+	assert.Equal(t, http.StatusNotModified, serve(etag).Code)
+
+	// a changed row changes the ETag of the list
+	expectList(updatedAt.Add(time.Second))
+	w = serve(etag)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NotEqual(t, etag, w.Header().Get("ETag"))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,163 @@
+package rest
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pabloeclair/rest-subscription/internal/sbscrb/models"
+)
+
+// CORSPolicy is the access of the browsers from other origins, it is
+// disabled while Origins is empty.
+type CORSPolicy struct {
+	// Origins are the origins like 'https://app.example.com', the
+	// subdomains like 'https://*.example.com' or '*' for any origin
+	Origins     []string
+	Methods     []string
+	Headers     []string
+	Credentials bool
+	MaxAge      time.Duration
+}
+
+// CORS is configured by Configure.
+var CORS = &CORSPolicy{
+	Methods: []string{"GET", "POST", "PUT", "PATCH", "DELETE"},
+	Headers: []string{"Authorization", "Content-Type", "Idempotency-Key", "X-API-Key", "X-Request-ID"},
+	MaxAge:  10 * time.Minute,
+}
+
+// corsExposedHeaders are the response headers of the API readable by the
+// browsers besides the safelisted ones.
+var corsExposedHeaders = []string{
+	"Content-Disposition",
+	"Idempotent-Replayed",
+	"RateLimit-Limit",
+	"RateLimit-Policy",
+	"RateLimit-Remaining",
+	"RateLimit-Reset",
+	"Retry-After",
+	"Warning",
+	"X-Request-ID",
+	"X-Total-Count",
+}
+
+// AllowOrigin reports if the origin matches one of the origins of the
+// policy. A wildcard origin matches the subdomains, but not the domain.
+func (c *CORSPolicy) AllowOrigin(origin string) bool {
+	origin = strings.ToLower(origin)
+	for _, allowed := range c.Origins {
+		allowed = strings.ToLower(allowed)
+		if allowed == "*" || allowed == origin {
+			return true
+		}
+		scheme, host, ok := strings.Cut(allowed, "://*.")
+		if !ok {
+			continue
+		}
+		subdomain, found := strings.CutSuffix(origin, "."+host)
+		if found && strings.HasPrefix(subdomain, scheme+"://") && len(subdomain) > len(scheme)+3 {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *CORSPolicy) allowMethod(method string) bool {
+	return slices.Contains(c.Methods, method) || slices.Contains(c.Methods, "*")
+}
+
+// allowHeaders checks the comma-separated headers of a preflight.
+func (c *CORSPolicy) allowHeaders(headers string) (string, bool) {
+	for _, header := range strings.Split(headers, ",") {
+		header = strings.TrimSpace(header)
+		if header == "" {
+			continue
+		}
+		if !slices.ContainsFunc(c.Headers, func(allowed string) bool {
+			return allowed == "*" || strings.EqualFold(allowed, header)
+		}) {
+			return header, false
+		}
+	}
+	return "", true
+}
+
+// CORSMiddleware answers the preflights before the routing, so they are
+// not authenticated and do not fall into the 404 handler. The other
+// requests from the allowed origins get the CORS headers, the requests
+// from the other origins are served without them and the browser hides
+// the response.
+func CORSMiddleware(cors *CORSPolicy, handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		if len(cors.Origins) == 0 || origin == "" {
+			handler.ServeHTTP(w, r)
+			return
+		}
+
+		h := w.Header()
+		h.Add("Vary", "Origin")
+		preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+		if preflight {
+			h.Add("Vary", "Access-Control-Request-Method")
+			h.Add("Vary", "Access-Control-Request-Headers")
+		}
+
+		if !cors.AllowOrigin(origin) {
+			if preflight {
+				writeCORSForbidden(w, fmt.Sprintf("The origin %s is not allowed", origin))
+				return
+			}
+			handler.ServeHTTP(w, r)
+			return
+		}
+
+		if cors.Credentials || !slices.Contains(cors.Origins, "*") {
+			h.Set("Access-Control-Allow-Origin", origin)
+		} else {
+			h.Set("Access-Control-Allow-Origin", "*")
+		}
+		if cors.Credentials {
+			h.Set("Access-Control-Allow-Credentials", "true")
+		}
+
+		if !preflight {
+			h.Set("Access-Control-Expose-Headers", strings.Join(corsExposedHeaders, ", "))
+			handler.ServeHTTP(w, r)
+			return
+		}
+
+		method := r.Header.Get("Access-Control-Request-Method")
+		if !cors.allowMethod(method) {
+			writeCORSForbidden(w, fmt.Sprintf("The method %s is not allowed", method))
+			return
+		}
+		requested := r.Header.Get("Access-Control-Request-Headers")
+		if header, ok := cors.allowHeaders(requested); !ok {
+			writeCORSForbidden(w, fmt.Sprintf("The header %s is not allowed", header))
+			return
+		}
+
+		h.Set("Access-Control-Allow-Methods", method)
+		if requested != "" {
+			h.Set("Access-Control-Allow-Headers", requested)
+		}
+		if cors.MaxAge > 0 {
+			h.Set("Access-Control-Max-Age", strconv.Itoa(int(cors.MaxAge.Seconds())))
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+func writeCORSForbidden(w http.ResponseWriter, message string) {
+	errDto := models.NewFullExceptionDto(
+		http.StatusForbidden,
+		message,
+		"",
+	)
+	errDto.Write(w)
+}
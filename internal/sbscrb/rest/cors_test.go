@@ -0,0 +1,108 @@
+package rest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCORSAllowOrigin(t *testing.T) {
+	cors := &CORSPolicy{Origins: []string{"https://app.example.com", "https://*.example.org"}}
+
+	for origin, expected := range map[string]bool{
+		"https://app.example.com":     true,
+		"https://APP.example.com":     true,
+		"http://app.example.com":      false,
+		"https://other.example.com":   false,
+		"https://app.example.org":     true,
+		"https://a.b.example.org":     true,
+		"https://example.org":         false,
+		"https://evilexample.org":     false,
+		"http://app.example.org":      false,
+		"https://app.example.org:444": false,
+	} {
+		assert.Equal(t, expected, cors.AllowOrigin(origin), origin)
+	}
+}
+
+func TestCORSMiddleware(t *testing.T) {
+	cors := &CORSPolicy{
+		Origins:     []string{"https://*.example.com"},
+		Methods:     []string{"GET", "POST"},
+		Headers:     []string{"Authorization", "Content-Type"},
+		Credentials: true,
+		MaxAge:      10 * time.Minute,
+	}
+	served := false
+	handler := CORSMiddleware(cors, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		served = true
+	}))
+
+	request := func(method, origin string, headers map[string]string) *httptest.ResponseRecorder {
+		served = false
+		r := httptest.NewRequest(method, "/api/v1/subscribes", nil)
+		if origin != "" {
+			r.Header.Set("Origin", origin)
+		}
+		for k, v := range headers {
+			r.Header.Set(k, v)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w
+	}
+
+	t.Run("Preflight", func(t *testing.T) {
+		w := request(http.MethodOptions, "https://app.example.com", map[string]string{
+			"Access-Control-Request-Method":  "POST",
+			"Access-Control-Request-Headers": "content-type, authorization",
+		})
+		assert.False(t, served)
+		assert.Equal(t, http.StatusNoContent, w.Code)
+		assert.Equal(t, "https://app.example.com", w.Header().Get("Access-Control-Allow-Origin"))
+		assert.Equal(t, "true", w.Header().Get("Access-Control-Allow-Credentials"))
+		assert.Equal(t, "POST", w.Header().Get("Access-Control-Allow-Methods"))
+		assert.Equal(t, "content-type, authorization", w.Header().Get("Access-Control-Allow-Headers"))
+		assert.Equal(t, "600", w.Header().Get("Access-Control-Max-Age"))
+		assert.Contains(t, w.Header().Values("Vary"), "Origin")
+	})
+
+	t.Run("PreflightForbidden", func(t *testing.T) {
+		w := request(http.MethodOptions, "https://app.example.com", map[string]string{
+			"Access-Control-Request-Method": "DELETE",
+		})
+		assert.Equal(t, http.StatusForbidden, w.Code)
+
+		w = request(http.MethodOptions, "https://app.example.com", map[string]string{
+			"Access-Control-Request-Method":  "GET",
+			"Access-Control-Request-Headers": "X-Custom",
+		})
+		assert.Equal(t, http.StatusForbidden, w.Code)
+
+		w = request(http.MethodOptions, "https://example.net", map[string]string{
+			"Access-Control-Request-Method": "GET",
+		})
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		assert.Empty(t, w.Header().Get("Access-Control-Allow-Origin"))
+	})
+
+	t.Run("Request", func(t *testing.T) {
+		w := request(http.MethodGet, "https://app.example.com", nil)
+		assert.True(t, served)
+		assert.Equal(t, "https://app.example.com", w.Header().Get("Access-Control-Allow-Origin"))
+		assert.Contains(t, w.Header().Get("Access-Control-Expose-Headers"), "X-Total-Count")
+
+		w = request(http.MethodGet, "https://example.net", nil)
+		assert.True(t, served)
+		assert.Empty(t, w.Header().Get("Access-Control-Allow-Origin"))
+	})
+
+	t.Run("SameOrigin", func(t *testing.T) {
+		w := request(http.MethodOptions, "", nil)
+		assert.True(t, served)
+		assert.Empty(t, w.Header().Get("Vary"))
+	})
+}
@@ -0,0 +1,116 @@
+package rest
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/pabloeclair/rest-subscription/internal/sbscrb/export"
+	"github.com/pabloeclair/rest-subscription/internal/sbscrb/models"
+)
+
+// rows written between flushes of the streamed export
+const exportFlushRows = 500
+
+func Export(w http.ResponseWriter, r *http.Request) {
+	var (
+		//the error for response
+		errDto models.FullExceptionDto
+	)
+
+	// query validate
+	formatName := strings.ToLower(r.URL.Query().Get("format"))
+	if formatName == "" {
+		formatName = "csv"
+	}
+	format, ok := export.Formats[formatName]
+	if !ok {
+		errDto = models.NewFullExceptionDto(
+			http.StatusBadRequest,
+			"Incorrect the 'format' parameter. The 'format' can only be empty or have the values 'csv', 'ndjson' and 'xlsx'",
+			"",
+		)
+		errDto.Write(w)
+		return
+	}
+
+	filter, err := listFilter(r)
+	if err != nil {
+		errDto = models.NewFullExceptionDto(
+			http.StatusBadRequest,
+			err.Error(),
+			"",
+		)
+		errDto.Write(w)
+		return
+	}
+	if !canAccessAny(r) {
+		if filter.UserId != "" && !authorize(w, r, filter.UserId) {
+			return
+		}
+		filter.UserId = principal(r).Subject
+	}
+
+	repo, err := connectToDB(w, r)
+	if err != nil {
+		return
+	}
+
+	// export operation, the response is started with the first row
+	var (
+		ew      export.Writer
+		rc      = http.NewResponseController(w)
+		written int
+	)
+	start := func() (err error) {
+		w.Header().Set("Content-Type", format.ContentType)
+		w.Header().Set("Content-Disposition", fmt.Sprintf(
+			`attachment; filename="subscribes-%s.%s"`, time.Now().Format("20060102-150405"), format.Extension,
+		))
+		ew, err = format.New(w)
+		return err
+	}
+
+	err = repo.Stream(filter, func(subscribe *models.Subscribe) error {
+		if ew == nil {
+			if err := start(); err != nil {
+				return err
+			}
+		}
+		if err := ew.Write(subscribe.ToDto()); err != nil {
+			return err
+		}
+		written++
+		if written%exportFlushRows == 0 && formatName != "xlsx" {
+			rc.Flush()
+		}
+		return nil
+	})
+	if err != nil && ew == nil {
+		errDto = models.NewFullExceptionDto(
+			http.StatusInternalServerError,
+			"Failed to export the subscribes",
+			err.Error(),
+		)
+		errDto.Write(w)
+		return
+	}
+	if err != nil {
+		// the status is already sent, the client gets a truncated file
+		log.Println(models.RedString("ERROR: export: ", err.Error()))
+		return
+	}
+
+	// result
+	if ew == nil {
+		if err := start(); err != nil {
+			log.Println(models.RedString("ERROR: export: ", err.Error()))
+			return
+		}
+	}
+	if err := ew.Close(); err != nil {
+		log.Println(models.RedString("ERROR: export: ", err.Error()))
+	}
+}
@@ -7,18 +7,20 @@ import (
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 
+	"github.com/pabloeclair/rest-subscription/internal/sbscrb/cache"
 	"github.com/pabloeclair/rest-subscription/internal/sbscrb/models"
 	"github.com/pabloeclair/rest-subscription/internal/sbscrb/repositories"
-	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 )
 
-var DSN string
+// DB is the database shared by the handlers, it is set on start.
+var DB *gorm.DB
 
-func connectToDB(w http.ResponseWriter) (*repositories.GormSubscribeRepository, error) {
-	db, err := gorm.Open(postgres.Open(DSN), &gorm.Config{})
-	if err != nil {
+func openDB(w http.ResponseWriter, r *http.Request) (*gorm.DB, error) {
+	if DB == nil {
+		err := errors.New("the database is not connected")
 		errDto := models.NewFullExceptionDto(
 			http.StatusInternalServerError,
 			"Failed to connect to the database",
@@ -27,11 +29,47 @@ func connectToDB(w http.ResponseWriter) (*repositories.GormSubscribeRepository,
 		errDto.Write(w)
 		return nil, err
 	}
-	repo := repositories.GormSubscribeRepository{Db: db}
-	return &repo, nil
+	// the queries are canceled with the request, e.g. on the handler timeout
+	return DB.WithContext(r.Context()), nil
+}
+
+// SubscribeCache is set in main if the cache of the subscribes is enabled.
+var (
+	SubscribeCache    cache.Store
+	SubscribeCacheTTL = time.Minute
+)
+
+// NewSubscribeRepository reads the subscribes through the cache if it is
+// enabled, the cache is called with the context of the db.
+func NewSubscribeRepository(db *gorm.DB) repositories.SubscribeRepository {
+	var repo repositories.SubscribeRepository = &repositories.GormSubscribeRepository{Db: db}
+	if SubscribeCache != nil {
+		repo = &repositories.CachedSubscribeRepository{
+			SubscribeRepository: repo,
+			Store:               SubscribeCache,
+			TTL:                 SubscribeCacheTTL,
+			Metrics:             &Metrics.SubscribeCache,
+			Ctx:                 db.Statement.Context,
+		}
+	}
+	return repo
+}
+
+func connectToDB(w http.ResponseWriter, r *http.Request) (repositories.SubscribeRepository, error) {
+	db, err := openDB(w, r)
+	if err != nil {
+		return nil, err
+	}
+	return NewSubscribeRepository(db), nil
 }
 
 func Create(w http.ResponseWriter, r *http.Request) {
+	create(w, r, "")
+}
+
+// create creates the subscribe from the request body. If userId is not
+// empty, the subscribe is created for this user.
+func create(w http.ResponseWriter, r *http.Request, userId string) {
 	var (
 		//the subscribe from request
 		subscribeDto models.SubscribeDto
@@ -52,16 +90,32 @@ func Create(w http.ResponseWriter, r *http.Request) {
 
 	d := json.NewDecoder(r.Body)
 	if err := d.Decode(&subscribeDto); err != nil {
-		errDto = models.NewFullExceptionDto(
-			http.StatusBadRequest,
-			"Incorrect JSON body",
-			err.Error(),
-		)
-		errDto.Write(w)
+		writeBodyError(w, "Incorrect JSON body", err)
+		return
+	}
+
+	if userId != "" {
+		if subscribeDto.UserId != "" && subscribeDto.UserId != userId {
+			errDto = models.NewFullExceptionDto(
+				http.StatusBadRequest,
+				"The field 'user_id' does not match the user in the URL path",
+				"",
+			)
+			errDto.Write(w)
+			return
+		}
+		subscribeDto.UserId = userId
+	}
+
+	repo, err := connectToDB(w, r)
+	if err != nil {
 		return
 	}
 
 	// body validation
+	if err := validateService(w, DB.WithContext(r.Context()), &subscribeDto); err != nil {
+		return
+	}
 	if err := subscribeDto.Validate(); err != nil {
 		errDto = models.NewFullExceptionDto(
 			http.StatusBadRequest,
@@ -71,15 +125,20 @@ func Create(w http.ResponseWriter, r *http.Request) {
 		errDto.Write(w)
 		return
 	}
+	if !authorize(w, r, subscribeDto.UserId) {
+		return
+	}
 
-	repo, err := connectToDB(w)
-	if err != nil {
+	subscribeDb := subscribeDto.ToDatabase()
+	if !checkOverlap(w, repo, subscribeDb) {
 		return
 	}
 
 	// create operation
-	err = repo.Create(subscribeDto.ToDatabase())
-	if err != nil {
+	err = repo.Create(subscribeDb)
+	if writeOverlapError(w, err) {
+		return
+	} else if err != nil {
 		errDto = models.NewFullExceptionDto(
 			http.StatusInternalServerError,
 			"Failed to create the subscribe",
@@ -115,7 +174,7 @@ func GetById(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	repo, err := connectToDB(w)
+	repo, err := connectToDB(w, r)
 	if err != nil {
 		return
 	}
@@ -142,6 +201,13 @@ func GetById(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	if !authorize(w, r, subscribeDb.UserId) {
+		return
+	}
+	if notModified(w, r, subscribeETag(subscribeDb), subscribeDb.UpdatedAt) {
+		return
+	}
+
 	// result
 	b, err := json.Marshal(subscribeDb.ToDto())
 	if err != nil {
@@ -157,6 +223,27 @@ func GetById(w http.ResponseWriter, r *http.Request) {
 	w.Write(b)
 }
 
+// listFilter reads the 'sort' and 'value' query parameters of the list endpoints.
+func listFilter(r *http.Request) (repositories.SubscribeFilter, error) {
+	queryParams := r.URL.Query()
+	sort := strings.ToUpper(queryParams.Get("sort"))
+	value := queryParams.Get("value")
+
+	if (sort == "" && value != "") || (sort != "" && value == "") {
+		return repositories.SubscribeFilter{}, errors.New("The 'sort' or 'value' parameters are missing. Please fill in both parameters")
+	}
+	switch sort {
+	case "":
+		return repositories.SubscribeFilter{}, nil
+	case "USER_ID":
+		return repositories.SubscribeFilter{UserId: value}, nil
+	case "SERVICE_NAME":
+		return repositories.SubscribeFilter{ServiceName: value}, nil
+	default:
+		return repositories.SubscribeFilter{}, errors.New("Incorrect the 'sort' parameter. The 'sort' can only be empty or have the values 'SERVICE_NAME' and 'USER_ID'")
+	}
+}
+
 func GetList(w http.ResponseWriter, r *http.Request) {
 	var (
 		//the subscribes from db
@@ -167,42 +254,42 @@ func GetList(w http.ResponseWriter, r *http.Request) {
 		errDto models.FullExceptionDto
 	)
 
-	queryParams := r.URL.Query()
-	sort := strings.ToUpper(queryParams.Get("sort"))
-	value := queryParams.Get("value")
-
 	// query validate
-	if (sort == "" && value != "") || (sort != "" && value == "") {
+	filter, err := listFilter(r)
+	if err != nil {
 		errDto = models.NewFullExceptionDto(
 			http.StatusBadRequest,
-			"The 'sort' or 'value' parameters are missing. Please fill in both parameters",
+			err.Error(),
 			"",
 		)
 		errDto.Write(w)
 		return
 	}
 
-	repo, err := connectToDB(w)
+	repo, err := connectToDB(w, r)
 	if err != nil {
 		return
 	}
 
+	// a caller without the permission for any user sees only the own subscribes
+	p := principal(r)
+	if !canAccessAny(r) {
+		if filter.UserId != "" && !authorize(w, r, filter.UserId) {
+			return
+		}
+		if filter.ServiceName == "" {
+			filter.UserId = p.Subject
+		}
+	}
+
 	// find operation
-	switch sort {
-	case "":
-		subscribes, err = repo.FindAll()
-	case "USER_ID":
-		subscribes, err = repo.FindByUserId(value)
-	case "SERVICE_NAME":
-		subscribes, err = repo.FindByServiceName(value)
+	switch {
+	case filter.UserId != "":
+		subscribes, err = repo.FindByUserId(filter.UserId)
+	case filter.ServiceName != "":
+		subscribes, err = repo.FindByServiceName(filter.ServiceName)
 	default:
-		errDto = models.NewFullExceptionDto(
-			http.StatusBadRequest,
-			"Incorrect the 'sort' parameter. The 'sort' can only be empty or have the values 'SERVICE_NAME' and 'USER_ID'",
-			"",
-		)
-		errDto.Write(w)
-		return
+		subscribes, err = repo.FindAll()
 	}
 
 	if err != nil {
@@ -216,9 +303,17 @@ func GetList(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// result
+	visible := subscribes[:0]
 	for _, v := range subscribes {
+		if !canAccessAny(r) && v.UserId != p.Subject {
+			continue
+		}
+		visible = append(visible, v)
 		subscribesDto = append(subscribesDto, v.ToDto())
 	}
+	if etag, lastModified := subscribesValidators(visible); notModified(w, r, etag, lastModified) {
+		return
+	}
 
 	b, err := json.Marshal(&subscribesDto)
 	if err != nil {
@@ -267,17 +362,20 @@ func UpdatePatch(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// body unmarshal
+	// body unmarshal, the fields are read too to tell a null 'end_date'
+	// from a missing one
+	var body json.RawMessage
+	var fields map[string]json.RawMessage
 	d := json.NewDecoder(r.Body)
-	if err := d.Decode(&subscribeDto); err != nil {
-		errDto = models.NewFullExceptionDto(
-			http.StatusBadRequest,
-			"Incorrect JSON body",
-			err.Error(),
-		)
-		errDto.Write(w)
+	if err := d.Decode(&body); err != nil {
+		writeBodyError(w, "Incorrect JSON body", err)
 		return
 	}
+	if err := json.Unmarshal(body, &subscribeDto); err != nil {
+		writeBodyError(w, "Incorrect JSON body", err)
+		return
+	}
+	json.Unmarshal(body, &fields)
 
 	// fields validate
 	if err = subscribeDto.ValidateTime(); err != nil {
@@ -291,7 +389,7 @@ func UpdatePatch(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// preparing fields
-	repo, err := connectToDB(w)
+	repo, err := connectToDB(w, r)
 	if err != nil {
 		return
 	}
@@ -315,8 +413,22 @@ func UpdatePatch(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if subscribeDto.ServiceName != "" {
+	if !authorize(w, r, subscribeDb.UserId) {
+		return
+	}
+	if subscribeDto.UserId != "" && !authorize(w, r, subscribeDto.UserId) {
+		return
+	}
+
+	if subscribeDto.ServiceName != "" || subscribeDto.ServiceID != nil {
+		// the default price of the service is not applied to an existing subscribe
+		price := subscribeDto.Price
+		if err = validateService(w, DB.WithContext(r.Context()), subscribeDto); err != nil {
+			return
+		}
+		subscribeDto.Price = price
 		subscribeDb.ServiceName = subscribeDto.ServiceName
+		subscribeDb.ServiceID = subscribeDto.ServiceID
 	}
 	if subscribeDto.Price != nil {
 		subscribeDb.Price = *subscribeDto.Price
@@ -327,9 +439,10 @@ func UpdatePatch(w http.ResponseWriter, r *http.Request) {
 	if !subscribeDto.StartDate.IsZero() {
 		subscribeDb.StartDate = subscribeDto.StartDate
 	}
-	if subscribeDto.EndDate != nil {
+	if subscribeDto.EndDate != nil || string(fields["end_date"]) == "null" {
 		subscribeDb.EndDate = subscribeDto.EndDate
 	}
+	subscribeDb.ResetExpiration(time.Now())
 	if subscribeDto.EndDate != nil && subscribeDto.StartDate.After(*subscribeDto.EndDate) {
 		errDto = models.NewFullExceptionDto(
 			http.StatusBadRequest,
@@ -340,9 +453,15 @@ func UpdatePatch(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if !checkOverlap(w, repo, subscribeDb) {
+		return
+	}
+
 	// update operation
 	err = repo.Update(uint(idInt), subscribeDb)
-	if errors.Is(err, gorm.ErrRecordNotFound) {
+	if writeOverlapError(w, err) {
+		return
+	} else if errors.Is(err, gorm.ErrRecordNotFound) {
 		errDto = models.NewFullExceptionDto(
 			http.StatusNotFound,
 			fmt.Sprintf("The subscribe with id = %d is not found", idInt),
@@ -401,34 +520,65 @@ func UpdatePut(w http.ResponseWriter, r *http.Request) {
 	// body unmarshal
 	d := json.NewDecoder(r.Body)
 	if err := d.Decode(&subscribeDto); err != nil {
+		writeBodyError(w, "Incorrect JSON body", err)
+		return
+	}
+
+	repo, err := connectToDB(w, r)
+	if err != nil {
+		return
+	}
+
+	// fields validate
+	if err = validateService(w, DB.WithContext(r.Context()), subscribeDto); err != nil {
+		return
+	}
+	if err = subscribeDto.Validate(); err != nil {
 		errDto = models.NewFullExceptionDto(
 			http.StatusBadRequest,
-			"Incorrect JSON body",
 			err.Error(),
+			"",
 		)
 		errDto.Write(w)
 		return
 	}
 
-	// fields validate
-	if err = subscribeDto.Validate(); err != nil {
+	// the caller must own both the current and the new subscribe
+	subscribeDb, err := repo.FindByID(uint(idInt))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
 		errDto = models.NewFullExceptionDto(
-			http.StatusBadRequest,
-			err.Error(),
+			http.StatusNotFound,
+			fmt.Sprintf("The subscribe with id = %d is not found", idInt),
 			"",
 		)
 		errDto.Write(w)
 		return
+	} else if err != nil {
+		errDto = models.NewFullExceptionDto(
+			http.StatusInternalServerError,
+			fmt.Sprintf("Failed to get the subscribe with id = %d", idInt),
+			err.Error(),
+		)
+		errDto.Write(w)
+		return
+	}
+	if !authorize(w, r, subscribeDb.UserId) || !authorize(w, r, subscribeDto.UserId) {
+		return
 	}
 
-	repo, err := connectToDB(w)
-	if err != nil {
+	subscribeUpdate := subscribeDto.ToDatabase()
+	subscribeUpdate.ID = uint(idInt)
+	subscribeUpdate.ExpiredAt = subscribeDb.ExpiredAt
+	subscribeUpdate.ResetExpiration(time.Now())
+	if !checkOverlap(w, repo, subscribeUpdate) {
 		return
 	}
 
 	// update operation
-	err = repo.Update(uint(idInt), subscribeDto.ToDatabase())
-	if errors.Is(err, gorm.ErrRecordNotFound) {
+	err = repo.Update(uint(idInt), subscribeUpdate)
+	if writeOverlapError(w, err) {
+		return
+	} else if errors.Is(err, gorm.ErrRecordNotFound) {
 		errDto = models.NewFullExceptionDto(
 			http.StatusNotFound,
 			fmt.Sprintf("The subscribe with id = %d is not found", idInt),
@@ -470,11 +620,33 @@ func Delete(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	repo, err := connectToDB(w)
+	repo, err := connectToDB(w, r)
 	if err != nil {
 		return
 	}
 
+	subscribeDb, err := repo.FindByID(uint(idInt))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		errDto = models.NewFullExceptionDto(
+			http.StatusNotFound,
+			fmt.Sprintf("The subscribe with id = %d is not found", idInt),
+			"",
+		)
+		errDto.Write(w)
+		return
+	} else if err != nil {
+		errDto = models.NewFullExceptionDto(
+			http.StatusInternalServerError,
+			fmt.Sprintf("Failed to get the subscribe with id = %d", idInt),
+			err.Error(),
+		)
+		errDto.Write(w)
+		return
+	}
+	if !authorize(w, r, subscribeDb.UserId) {
+		return
+	}
+
 	// delete operation
 	err = repo.Delete(uint(idInt))
 	if errors.Is(err, gorm.ErrRecordNotFound) {
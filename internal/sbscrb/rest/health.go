@@ -0,0 +1,67 @@
+package rest
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/pabloeclair/rest-subscription/internal/sbscrb/models"
+)
+
+// Readiness reports if the service accepts the requests, it is failed
+// first on shutdown.
+type Readiness interface {
+	Ready() bool
+}
+
+const readinessPingTimeout = 2 * time.Second
+
+// Healthz is the liveness probe, it answers while the process serves.
+func Healthz(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Write([]byte(`{"status":"ok"}`))
+}
+
+// Readyz is the readiness probe, it fails on shutdown and when the
+// database is unreachable.
+func Readyz(readiness Readiness) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !readiness.Ready() {
+			errDto := models.NewFullExceptionDto(
+				http.StatusServiceUnavailable,
+				"The server is not ready",
+				"",
+			)
+			errDto.Write(w)
+			return
+		}
+
+		if DB == nil {
+			errDto := models.NewFullExceptionDto(
+				http.StatusServiceUnavailable,
+				"The database is not connected",
+				"",
+			)
+			errDto.Write(w)
+			return
+		}
+		ctx, cancel := context.WithTimeout(r.Context(), readinessPingTimeout)
+		defer cancel()
+		sqlDB, err := DB.DB()
+		if err == nil {
+			err = sqlDB.PingContext(ctx)
+		}
+		if err != nil {
+			errDto := models.NewFullExceptionDto(
+				http.StatusServiceUnavailable,
+				"The database is unreachable",
+				err.Error(),
+			)
+			errDto.Write(w)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"status":"ready"}`))
+	}
+}
@@ -0,0 +1,35 @@
+package rest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type readiness bool
+
+func (r readiness) Ready() bool {
+	return bool(r)
+}
+
+func TestProbes(t *testing.T) {
+	w := httptest.NewRecorder()
+	Healthz(w, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	t.Run("NotReady", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		Readyz(readiness(false))(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+		assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+		assert.Contains(t, w.Body.String(), "The server is not ready")
+	})
+
+	t.Run("NoDatabase", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		Readyz(readiness(true))(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+		assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+		assert.Contains(t, w.Body.String(), "The database is not connected")
+	})
+}
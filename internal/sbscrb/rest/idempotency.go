@@ -0,0 +1,166 @@
+package rest
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/pabloeclair/rest-subscription/internal/sbscrb/models"
+	"github.com/pabloeclair/rest-subscription/internal/sbscrb/repositories"
+)
+
+var (
+	// IdempotencyTTL is the time a stored response is replayed, it is
+	// configured by Init
+	IdempotencyTTL = 24 * time.Hour
+	// IdempotencyLockTimeout is the time a request holds its key, it is
+	// configured by Init. It must be longer than the handler timeout.
+	IdempotencyLockTimeout = 10 * time.Minute
+)
+
+const maxIdempotencyKeyLength = 255
+
+// idempotencyRecorder passes the response through and keeps a copy of it.
+type idempotencyRecorder struct {
+	http.ResponseWriter
+	statusCode int
+	body       bytes.Buffer
+}
+
+func (rec *idempotencyRecorder) WriteHeader(statusCode int) {
+	if rec.statusCode == 0 {
+		rec.statusCode = statusCode
+	}
+	rec.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (rec *idempotencyRecorder) Write(b []byte) (int, error) {
+	if rec.statusCode == 0 {
+		rec.statusCode = http.StatusOK
+	}
+	rec.body.Write(b)
+	return rec.ResponseWriter.Write(b)
+}
+
+func (rec *idempotencyRecorder) Unwrap() http.ResponseWriter {
+	return rec.ResponseWriter
+}
+
+// IdempotencyMiddleware stores the response of a POST request with the
+// 'Idempotency-Key' header and replays it on the retries with the same key
+// and payload. A retry with another payload gets 422, a retry while the
+// first request is in progress gets 409, until the lock of the first one
+// expires, e.g. if the process was killed. The responses with 5xx statuses
+// are not stored, so such requests can be retried. The keys are scoped by
+// the caller.
+func newIdempotencyOwner() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func IdempotencyMiddleware(repo repositories.IdempotencyRepository, handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		idempotencyKey := r.Header.Get("Idempotency-Key")
+		if r.Method != http.MethodPost || idempotencyKey == "" {
+			handler.ServeHTTP(w, r)
+			return
+		}
+		if len(idempotencyKey) > maxIdempotencyKeyLength {
+			errDto := models.NewFullExceptionDto(
+				http.StatusBadRequest,
+				"The 'Idempotency-Key' header must not be longer than 255 characters",
+				"",
+			)
+			errDto.Write(w)
+			return
+		}
+
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			writeBodyError(w, "Failed to read the request body", err)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		fingerprint := sha256.New()
+		io.WriteString(fingerprint, r.Method+" "+r.URL.RequestURI()+"\n")
+		fingerprint.Write(body)
+		now := time.Now()
+		key := &models.IdempotencyKey{
+			Key:         rateLimitClient(r) + "|" + idempotencyKey,
+			Fingerprint: hex.EncodeToString(fingerprint.Sum(nil)),
+			CreatedAt:   now,
+			LockedUntil: now.Add(IdempotencyLockTimeout),
+			Owner:       newIdempotencyOwner(),
+		}
+
+		stored, err := repo.Claim(key, IdempotencyTTL)
+		if err != nil {
+			errDto := models.NewFullExceptionDto(
+				http.StatusInternalServerError,
+				"Failed to check the idempotency key",
+				err.Error(),
+			)
+			errDto.Write(w)
+			return
+		}
+		if stored != nil {
+			replay(w, stored, key.Fingerprint)
+			return
+		}
+
+		rec := &idempotencyRecorder{ResponseWriter: w}
+		defer func() {
+			// the key of a panicked request is released and the panic goes on
+			if p := recover(); p != nil {
+				repo.Release(key)
+				panic(p)
+			}
+		}()
+		handler.ServeHTTP(rec, r)
+
+		if rec.statusCode == 0 || rec.statusCode >= http.StatusInternalServerError {
+			err = repo.Release(key)
+		} else {
+			err = repo.Complete(key, rec.statusCode, w.Header().Get("Content-Type"), rec.body.Bytes())
+		}
+		if err != nil {
+			log.Println(models.RedString("ERROR: idempotency: ", err.Error()))
+		}
+	})
+}
+
+func replay(w http.ResponseWriter, stored *models.IdempotencyKey, fingerprint string) {
+	switch {
+	case stored.Fingerprint != fingerprint:
+		errDto := models.NewFullExceptionDto(
+			http.StatusUnprocessableEntity,
+			"The 'Idempotency-Key' has already been used with another request payload",
+			"",
+		)
+		errDto.Write(w)
+	case stored.InProgress():
+		w.Header().Set("Retry-After", "1")
+		errDto := models.NewFullExceptionDto(
+			http.StatusConflict,
+			"The request with the same 'Idempotency-Key' is in progress",
+			"",
+		)
+		errDto.Write(w)
+	default:
+		if stored.ContentType != "" {
+			w.Header().Set("Content-Type", stored.ContentType)
+		} else {
+			w.Header().Del("Content-Type")
+		}
+		w.Header().Set("Idempotent-Replayed", "true")
+		w.WriteHeader(stored.StatusCode)
+		w.Write(stored.Body)
+	}
+}
@@ -0,0 +1,199 @@
+package rest
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pabloeclair/rest-subscription/internal/sbscrb/models"
+	"github.com/stretchr/testify/assert"
+)
+
+type memoryIdempotencyRepository struct {
+	mu   sync.Mutex
+	keys map[string]*models.IdempotencyKey
+}
+
+func (r *memoryIdempotencyRepository) Claim(key *models.IdempotencyKey, ttl time.Duration) (*models.IdempotencyKey, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if stored, ok := r.keys[key.Key]; ok && stored.CreatedAt.After(key.CreatedAt.Add(-ttl)) &&
+		!(stored.InProgress() && stored.LockedUntil.Before(key.CreatedAt)) {
+		return stored, nil
+	}
+	r.keys[key.Key] = key
+	return nil, nil
+}
+
+func (r *memoryIdempotencyRepository) Complete(key *models.IdempotencyKey, statusCode int, contentType string, body []byte) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stored, ok := r.keys[key.Key]
+	if !ok || stored.Owner != key.Owner {
+		return models.ErrIdempotencyClaimLost
+	}
+	stored.StatusCode = statusCode
+	stored.ContentType = contentType
+	stored.Body = body
+	return nil
+}
+
+func (r *memoryIdempotencyRepository) Release(key *models.IdempotencyKey) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if stored, ok := r.keys[key.Key]; ok && stored.Owner == key.Owner {
+		delete(r.keys, key.Key)
+	}
+	return nil
+}
+
+func (r *memoryIdempotencyRepository) Cleanup(before time.Time) (int64, error) {
+	return 0, nil
+}
+
+func TestIdempotencyMiddleware(t *testing.T) {
+	repo := &memoryIdempotencyRepository{keys: map[string]*models.IdempotencyKey{}}
+	calls := 0
+	status := http.StatusCreated
+	handler := IdempotencyMiddleware(repo, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(status)
+		w.Write([]byte(`{"id":1}`))
+	}))
+	serve := func(key, body string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPost, "/api/v1/subscribes", strings.NewReader(body))
+		r.Header.Set("Idempotency-Key", key)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w
+	}
+
+	t.Run("Replay", func(t *testing.T) {
+		w := serve("key-1", `{"price":100}`)
+		assert.Equal(t, http.StatusCreated, w.Code)
+
+		w = serve("key-1", `{"price":100}`)
+		assert.Equal(t, http.StatusCreated, w.Code)
+		assert.Equal(t, `{"id":1}`, w.Body.String())
+		assert.Equal(t, "true", w.Header().Get("Idempotent-Replayed"))
+		assert.Equal(t, 1, calls)
+	})
+
+	t.Run("ConflictingPayload", func(t *testing.T) {
+		w := serve("key-1", `{"price":200}`)
+		assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+		assert.Equal(t, 1, calls)
+	})
+
+	t.Run("InProgress", func(t *testing.T) {
+		fingerprint := sha256.Sum256([]byte("POST /api/v1/subscribes\n" + `{"price":100}`))
+		repo.keys["ip:192.0.2.1|key-2"] = &models.IdempotencyKey{
+			Key:         "ip:192.0.2.1|key-2",
+			Fingerprint: hex.EncodeToString(fingerprint[:]),
+			CreatedAt:   time.Now(),
+			LockedUntil: time.Now().Add(time.Minute),
+		}
+		w := serve("key-2", `{"price":100}`)
+		assert.Equal(t, http.StatusConflict, w.Code)
+	})
+
+	t.Run("AbandonedClaim", func(t *testing.T) {
+		before := calls
+		fingerprint := sha256.Sum256([]byte("POST /api/v1/subscribes\n" + `{"price":100}`))
+		repo.keys["ip:192.0.2.1|key-4"] = &models.IdempotencyKey{
+			Key:         "ip:192.0.2.1|key-4",
+			Fingerprint: hex.EncodeToString(fingerprint[:]),
+			CreatedAt:   time.Now().Add(-time.Hour),
+			LockedUntil: time.Now().Add(-time.Minute),
+		}
+		w := serve("key-4", `{"price":100}`)
+		assert.Equal(t, http.StatusCreated, w.Code)
+		assert.Equal(t, before+1, calls)
+	})
+
+	t.Run("TakenOverClaim", func(t *testing.T) {
+		// the claim of a stale request is taken over by a retry while the
+		// stale request is still running
+		takeover := &models.IdempotencyKey{
+			Key:         "ip:192.0.2.1|key-5",
+			CreatedAt:   time.Now(),
+			LockedUntil: time.Now().Add(time.Minute),
+			Owner:       "retry",
+		}
+		stale := IdempotencyMiddleware(repo, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			repo.mu.Lock()
+			repo.keys[takeover.Key] = takeover
+			repo.mu.Unlock()
+			w.WriteHeader(status)
+		}))
+		for _, code := range []int{http.StatusCreated, http.StatusInternalServerError} {
+			status = code
+			r := httptest.NewRequest(http.MethodPost, "/api/v1/subscribes", strings.NewReader(`{"price":100}`))
+			r.Header.Set("Idempotency-Key", "key-5")
+			stale.ServeHTTP(httptest.NewRecorder(), r)
+
+			stored := repo.keys[takeover.Key]
+			assert.Same(t, takeover, stored)
+			assert.True(t, stored.InProgress())
+			delete(repo.keys, takeover.Key)
+		}
+		status = http.StatusCreated
+	})
+
+	t.Run("ServerErrorIsNotStored", func(t *testing.T) {
+		status = http.StatusInternalServerError
+		serve("key-3", `{"price":100}`)
+		status = http.StatusCreated
+		w := serve("key-3", `{"price":100}`)
+		assert.Equal(t, http.StatusCreated, w.Code)
+		assert.Empty(t, w.Header().Get("Idempotent-Replayed"))
+	})
+
+	t.Run("WithoutKey", func(t *testing.T) {
+		before := calls
+		r := httptest.NewRequest(http.MethodPost, "/api/v1/subscribes", strings.NewReader(`{}`))
+		handler.ServeHTTP(httptest.NewRecorder(), r)
+		handler.ServeHTTP(httptest.NewRecorder(), r)
+		assert.Equal(t, before+2, calls)
+	})
+}
+
+func TestIdempotencyMiddlewareTimedOut(t *testing.T) {
+	repo := &memoryIdempotencyRepository{keys: map[string]*models.IdempotencyKey{}}
+	finish := make(chan struct{})
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /api/v1/subscribes", func(w http.ResponseWriter, r *http.Request) {
+		// the subscribe is created after the timeout
+		<-finish
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"id":1}`))
+	})
+	handler := TimeoutMiddleware(&HandlerTimeouts{Default: 20 * time.Millisecond}, mux, IdempotencyMiddleware(repo, mux))
+	serve := func() *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPost, "/api/v1/subscribes", strings.NewReader(`{"price":100}`))
+		r.Header.Set("Idempotency-Key", "key-1")
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w
+	}
+
+	assert.Equal(t, http.StatusServiceUnavailable, serve().Code)
+	// the key is held while the timed out handler is still running
+	assert.Equal(t, http.StatusConflict, serve().Code)
+
+	close(finish)
+	assert.Eventually(t, func() bool {
+		repo.mu.Lock()
+		defer repo.mu.Unlock()
+		return repo.keys["ip:192.0.2.1|key-1"].StatusCode == http.StatusCreated
+	}, time.Second, 5*time.Millisecond)
+	w := serve()
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, `{"id":1}`, w.Body.String())
+	assert.Equal(t, "true", w.Header().Get("Idempotent-Replayed"))
+}
@@ -0,0 +1,318 @@
+package rest
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pabloeclair/rest-subscription/internal/sbscrb/models"
+	"github.com/pabloeclair/rest-subscription/internal/sbscrb/repositories"
+)
+
+const MaxImportRows = 10000
+
+type importRow struct {
+	line int
+	dto  *models.SubscribeDto
+	err  error
+}
+
+type ImportRowResult struct {
+	Row     int    `json:"row"`
+	ID      uint   `json:"id,omitempty"`
+	Error   string `json:"error,omitempty"`
+	Warning string `json:"warning,omitempty"`
+}
+
+type ImportReport struct {
+	Mode      string            `json:"mode"`
+	Committed bool              `json:"committed"`
+	Total     int               `json:"total"`
+	Created   int               `json:"created"`
+	Failed    int               `json:"failed"`
+	Rows      []ImportRowResult `json:"rows"`
+}
+
+// parseImportDate accepts RFC 3339 timestamps and plain dates.
+func parseImportDate(s string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.DateOnly, s)
+}
+
+func parseImportCSV(r io.Reader) ([]importRow, error) {
+	cr := csv.NewReader(r)
+	cr.TrimLeadingSpace = true
+	cr.FieldsPerRecord = -1
+
+	header, err := cr.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the CSV header: %w", err)
+	}
+	columns := map[string]int{}
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, name := range []string{"service_name", "price", "user_id", "start_date"} {
+		if _, ok := columns[name]; !ok {
+			return nil, fmt.Errorf("the CSV header must contain the columns 'service_name', 'price', 'user_id', 'start_date' and optionally 'end_date'")
+		}
+	}
+
+	rows := []importRow{}
+	for {
+		record, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		// the malformed rows count too, the report is limited as well
+		if len(rows) == MaxImportRows {
+			return nil, fmt.Errorf("the import is limited to %d rows", MaxImportRows)
+		}
+		if err != nil {
+			var parseErr *csv.ParseError
+			if !errors.As(err, &parseErr) {
+				return nil, err
+			}
+			rows = append(rows, importRow{line: parseErr.Line, err: err})
+			continue
+		}
+		line, _ := cr.FieldPos(0)
+
+		field := func(name string) string {
+			i, ok := columns[name]
+			if !ok || i >= len(record) {
+				return ""
+			}
+			return strings.TrimSpace(record[i])
+		}
+
+		row := importRow{line: line, dto: &models.SubscribeDto{
+			ServiceName: field("service_name"),
+			UserId:      field("user_id"),
+		}}
+		if v := field("price"); v != "" {
+			price, err := strconv.Atoi(v)
+			if err != nil {
+				row.err = fmt.Errorf("incorrect 'price' value '%s'", v)
+			}
+			row.dto.Price = &price
+		}
+		if v := field("start_date"); v != "" && row.err == nil {
+			if row.dto.StartDate, err = parseImportDate(v); err != nil {
+				row.err = fmt.Errorf("incorrect 'start_date' value '%s'", v)
+			}
+		}
+		if v := field("end_date"); v != "" && row.err == nil {
+			endDate, err := parseImportDate(v)
+			if err != nil {
+				row.err = fmt.Errorf("incorrect 'end_date' value '%s'", v)
+			}
+			row.dto.EndDate = &endDate
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+func parseImportNDJSON(r io.Reader) ([]importRow, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64<<10), 1<<20)
+
+	rows := []importRow{}
+	for line := 1; scanner.Scan(); line++ {
+		b := scanner.Bytes()
+		if len(strings.TrimSpace(string(b))) == 0 {
+			continue
+		}
+		if len(rows) == MaxImportRows {
+			return nil, fmt.Errorf("the import is limited to %d rows", MaxImportRows)
+		}
+
+		row := importRow{line: line, dto: &models.SubscribeDto{}}
+		if err := json.Unmarshal(b, row.dto); err != nil {
+			row.err = fmt.Errorf("incorrect JSON: %w", err)
+		}
+		rows = append(rows, row)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
+
+func Import(w http.ResponseWriter, r *http.Request) {
+	var (
+		//the rows from request
+		rows []importRow
+		//the error for response
+		errDto models.FullExceptionDto
+	)
+
+	// query validate
+	mode := strings.ToLower(r.URL.Query().Get("mode"))
+	if mode == "" {
+		mode = "atomic"
+	}
+	if mode != "atomic" && mode != "best_effort" {
+		errDto = models.NewFullExceptionDto(
+			http.StatusBadRequest,
+			"Incorrect the 'mode' parameter. The 'mode' can only be empty or have the values 'atomic' and 'best_effort'",
+			"",
+		)
+		errDto.Write(w)
+		return
+	}
+
+	// headers validation and body parsing
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	switch {
+	case err == nil && mediaType == "text/csv":
+		rows, err = parseImportCSV(r.Body)
+	case err == nil && mediaType == "application/x-ndjson":
+		rows, err = parseImportNDJSON(r.Body)
+	default:
+		errDto = models.NewFullExceptionDto(
+			http.StatusUnsupportedMediaType,
+			"The request body must be in 'text/csv' or 'application/x-ndjson' format",
+			"",
+		)
+		errDto.Write(w)
+		return
+	}
+	if err != nil {
+		writeBodyError(w, "Incorrect import body", err)
+		return
+	}
+	if len(rows) == 0 {
+		errDto = models.NewFullExceptionDto(
+			http.StatusBadRequest,
+			"The import body has no rows",
+			"",
+		)
+		errDto.Write(w)
+		return
+	}
+
+	// rows validation
+	report := ImportReport{
+		Mode:  mode,
+		Total: len(rows),
+		Rows:  make([]ImportRowResult, len(rows)),
+	}
+	repo, err := connectToDB(w, r)
+	if err != nil {
+		return
+	}
+	services := &repositories.GormServiceRepository{Db: DB.WithContext(r.Context())}
+
+	valid := []*models.Subscribe{}
+	validIdx := []int{}
+	for i, row := range rows {
+		report.Rows[i].Row = row.line
+		if row.err == nil {
+			row.err = row.dto.ValidateService(services)
+		}
+		if row.err == nil {
+			row.err = row.dto.Validate()
+		}
+		if row.err == nil && !canAccess(r, row.dto.UserId) {
+			row.err = fmt.Errorf("Access to the subscribes of the user '%s' is forbidden", row.dto.UserId)
+		}
+		var subscribe *models.Subscribe
+		if row.err == nil {
+			subscribe = row.dto.ToDatabase()
+			overlapErr, err := findOverlap(repo, subscribe)
+			if err != nil {
+				errDto = models.NewFullExceptionDto(
+					http.StatusInternalServerError,
+					"Failed to check the overlapping subscribes",
+					err.Error(),
+				)
+				errDto.Write(w)
+				return
+			}
+			if overlapErr != nil && OverlapPolicy == OverlapWarn {
+				report.Rows[i].Warning = overlapErr.Error()
+			} else if overlapErr != nil {
+				row.err = overlapErr
+			}
+		}
+		if row.err != nil {
+			report.Rows[i].Error = row.err.Error()
+			report.Failed++
+			continue
+		}
+		valid = append(valid, subscribe)
+		validIdx = append(validIdx, i)
+	}
+
+	// the atomic import is rejected as a whole
+	if mode == "atomic" && report.Failed > 0 {
+		writeImportReport(w, http.StatusUnprocessableEntity, &report)
+		return
+	}
+
+	// create operation
+	if mode == "atomic" {
+		failed, err := repo.CreateAll(valid)
+		if err != nil {
+			if failed >= 0 {
+				report.Rows[validIdx[failed]].Error = err.Error()
+				report.Failed++
+				writeImportReport(w, http.StatusUnprocessableEntity, &report)
+				return
+			}
+			errDto = models.NewFullExceptionDto(
+				http.StatusInternalServerError,
+				"Failed to import the subscribes",
+				err.Error(),
+			)
+			errDto.Write(w)
+			return
+		}
+	} else {
+		for k, subscribe := range valid {
+			if err := repo.Create(subscribe); err != nil {
+				subscribe.ID = 0
+				report.Rows[validIdx[k]].Error = err.Error()
+				report.Failed++
+			}
+		}
+	}
+
+	// result
+	report.Committed = true
+	for k, subscribe := range valid {
+		if subscribe.ID != 0 {
+			report.Rows[validIdx[k]].ID = subscribe.ID
+			report.Created++
+		}
+	}
+	writeImportReport(w, http.StatusOK, &report)
+}
+
+func writeImportReport(w http.ResponseWriter, statusCode int, report *ImportReport) {
+	b, err := json.Marshal(report)
+	if err != nil {
+		errDto := models.NewFullExceptionDto(
+			http.StatusInternalServerError,
+			"Failed to marshal a response",
+			err.Error(),
+		)
+		errDto.Write(w)
+		return
+	}
+
+	w.WriteHeader(statusCode)
+	w.Write(b)
+}
@@ -0,0 +1,132 @@
+package rest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/pabloeclair/rest-subscription/internal/sbscrb/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func TestParseImportCSV(t *testing.T) {
+	t.Run("Success", func(t *testing.T) {
+		body := "service_name,price,user_id,start_date,end_date\n" +
+			"Kinopoisk,399,6061fee-2bf1-aef6f-763675gre,2025-07-26,2025-08-26\n" +
+			"Yandex Plus,199,708gr-26896-agrfrf-fr5655gre,2025-07-15T00:00:00Z,\n"
+
+		rows, err := parseImportCSV(strings.NewReader(body))
+		assert.NoError(t, err)
+		assert.Len(t, rows, 2)
+
+		assert.NoError(t, rows[0].err)
+		assert.Equal(t, 2, rows[0].line)
+		assert.Equal(t, "Kinopoisk", rows[0].dto.ServiceName)
+		assert.Equal(t, 399, *rows[0].dto.Price)
+		assert.Equal(t, time.Date(2025, time.July, 26, 0, 0, 0, 0, time.UTC), rows[0].dto.StartDate)
+		assert.Equal(t, time.Date(2025, time.August, 26, 0, 0, 0, 0, time.UTC), *rows[0].dto.EndDate)
+		assert.NoError(t, rows[0].dto.Validate())
+
+		assert.NoError(t, rows[1].err)
+		assert.Nil(t, rows[1].dto.EndDate)
+	})
+
+	t.Run("RowErrors", func(t *testing.T) {
+		body := "user_id,service_name,price,start_date\n" +
+			"6061fee-2bf1-aef6f-763675gre,Kinopoisk,abc,2025-07-26\n" +
+			"6061fee-2bf1-aef6f-763675gre,Kinopoisk,399,26.07.2025\n" +
+			"6061fee-2bf1-aef6f-763675gre,,399,2025-07-26\n"
+
+		rows, err := parseImportCSV(strings.NewReader(body))
+		assert.NoError(t, err)
+		assert.Len(t, rows, 3)
+		assert.ErrorContains(t, rows[0].err, "price")
+		assert.ErrorContains(t, rows[1].err, "start_date")
+		assert.NoError(t, rows[2].err)
+		assert.Error(t, rows[2].dto.Validate())
+	})
+
+	t.Run("TooManyMalformedRows", func(t *testing.T) {
+		body := "service_name,price,user_id,start_date\n" +
+			strings.Repeat("Kino\"poisk,399,6061fee-2bf1-aef6f-763675gre,2025-07-26\n", MaxImportRows+1)
+
+		_, err := parseImportCSV(strings.NewReader(body))
+		assert.ErrorContains(t, err, "limited")
+	})
+
+	t.Run("IncorrectHeader", func(t *testing.T) {
+		_, err := parseImportCSV(strings.NewReader("name,price\nKinopoisk,399\n"))
+		assert.Error(t, err)
+	})
+}
+
+func TestParseImportNDJSON(t *testing.T) {
+	body := `{"service_name":"Kinopoisk","price":399,"user_id":"6061fee-2bf1-aef6f-763675gre","start_date":"2025-07-26T00:00:00Z"}
+
+{"service_name":"Kinopoisk",
+`
+	rows, err := parseImportNDJSON(strings.NewReader(body))
+	assert.NoError(t, err)
+	assert.Len(t, rows, 2)
+	assert.NoError(t, rows[0].err)
+	assert.NoError(t, rows[0].dto.Validate())
+	assert.Equal(t, 3, rows[1].line)
+	assert.Error(t, rows[1].err)
+}
+
+// mockDB sets DB to a mocked database for the test.
+func mockDB(t *testing.T) sqlmock.Sqlmock {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	db, err := gorm.Open(postgres.New(postgres.Config{Conn: mockDB}), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	require.NoError(t, err)
+	DB = db
+	t.Cleanup(func() { DB = nil })
+	return mock
+}
+
+func TestImportReportThroughMiddlewares(t *testing.T) {
+	mock := mockDB(t)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /api/v1/subscribes/import", Import)
+	idempotencyRepo := &memoryIdempotencyRepository{keys: map[string]*models.IdempotencyKey{}}
+	// the chain of main without the authentication
+	var handler http.Handler = mux
+	handler = IdempotencyMiddleware(idempotencyRepo, handler)
+	handler = TimeoutMiddleware(HandlerTimeout, mux, handler)
+	handler = CacheControlMiddleware(CacheControl, mux, handler)
+	handler = BodyLimitMiddleware(BodyLimit, mux, handler)
+	handler = SecurityHeadersMiddleware(handler)
+	handler = RecoveryMiddleware(handler)
+	handler = LoggingMiddleware(handler)
+	handler = CompressionMiddleware(Compress, handler)
+	handler = RequestIDMiddleware(handler)
+
+	// the malformed row fails the atomic import before the database is used
+	body := "service_name,price,user_id,start_date\n" +
+		"Kinopoisk,abc,6061fee-2bf1-aef6f-763675gre,2025-07-26\n"
+	r := httptest.NewRequest(http.MethodPost, "/api/v1/subscribes/import", strings.NewReader(body))
+	r.Header.Set("Content-Type", "text/csv")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	var report ImportReport
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &report))
+	assert.Equal(t, 1, report.Failed)
+	assert.False(t, report.Committed)
+	require.Len(t, report.Rows, 1)
+	assert.Contains(t, report.Rows[0].Error, "price")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
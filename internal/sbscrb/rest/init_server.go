@@ -3,75 +3,161 @@ package rest
 import (
 	"errors"
 	"fmt"
-	"os"
-	"strconv"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/fatih/color"
+	"github.com/pabloeclair/rest-subscription/internal/sbscrb/auth"
+	"github.com/pabloeclair/rest-subscription/internal/sbscrb/config"
 	"github.com/pabloeclair/rest-subscription/internal/sbscrb/models"
+	"github.com/pabloeclair/rest-subscription/internal/sbscrb/ratelimit"
 )
 
-func Init() (time.Duration, time.Duration, string, error) {
-	var (
-		errs             error
-		shutdownDuration time.Duration
-		postgresTimeout  time.Duration
-		serverAddrs      string
-	)
+var (
+	ExpirationInterval = time.Minute
 
-	if len(os.Args) != 2 {
-		errs = errors.New("ERROR: please provide only one argument - server address (e.g. 'localhost:8080')")
-	}
+	OutboxPublishers        = []string{"log"}
+	OutboxHTTPURL           string
+	OutboxNATSURL           string
+	OutboxNATSSubjectPrefix = "rest-subscription."
+	OutboxRetention         = 7 * 24 * time.Hour
+)
 
-	shutdownDurationString := os.Getenv("SHUTDOWN_DURATION")
-	shutdownDurationInt, err := strconv.Atoi(shutdownDurationString)
+// Configure applies the configuration to the handlers and middlewares.
+func Configure(cfg *config.Config) error {
+	var errs error
 
-	if err != nil || shutdownDurationInt <= 0 {
-		errs = errors.Join(errs, errors.New("ERROR: the environment variable 'SHUTDOWN_DURATION' must be a positive number"))
-	} else {
-		shutdownDuration = time.Duration(shutdownDurationInt) * time.Second
+	models.NotificationInternalError = cfg.Server.NotificationInternalError
+	if models.NotificationInternalError == "" {
+		color.Yellow("WARN: the environment variable 'NOTIFICATION_INTERNAL_ERROR' is not found. " +
+			"This variable is optional, but you may want to send an extra notification when catching INTERNAL SERVER ERROR " +
+			"(e.g., 'Please notify the administrator.')")
 	}
 
-	postgresUser := os.Getenv("POSTGRES_USER")
-	if postgresUser == "" {
-		errs = errors.Join(errs, errors.New("ERROR: the environment variable 'POSTGRES_USER' is not found"))
+	HandlerTimeout.Default = time.Duration(cfg.Server.HandlerTimeout)
+	for pattern, timeoutString := range cfg.Server.HandlerTimeoutRoutes {
+		timeout, err := config.ParseDuration(timeoutString)
+		if err != nil {
+			errs = errors.Join(errs, fmt.Errorf("ERROR: incorrect timeout of the route '%s' in 'HANDLER_TIMEOUT_ROUTES': %w", pattern, err))
+			continue
+		}
+		HandlerTimeout.Routes[pattern] = time.Duration(timeout)
+	}
+	BodyLimit.Default = int64(cfg.Server.BodyLimit)
+	for pattern, limitString := range cfg.Server.BodyLimitRoutes {
+		limit, err := config.ParseSize(limitString)
+		if err != nil {
+			errs = errors.Join(errs, fmt.Errorf("ERROR: incorrect limit of the route '%s' in 'BODY_LIMIT_ROUTES': %w", pattern, err))
+			continue
+		}
+		BodyLimit.Routes[pattern] = int64(limit)
 	}
 
-	postgresPassword := os.Getenv("POSTGRES_PASSWORD")
-	if postgresPassword == "" {
-		errs = errors.Join(errs, errors.New("ERROR: the environment variable 'POSTGRES_PASSWORD' is not found"))
+	Compress.MinSize = int(cfg.Server.CompressionMinSize)
+	Compress.Encodings = nil
+	for _, encoding := range cfg.Server.CompressionEncodings {
+		if _, ok := encoderPools[encoding]; !ok {
+			errs = errors.Join(errs, fmt.Errorf("ERROR: unknown encoding '%s' in 'COMPRESSION_ENCODINGS'. "+
+				"The encodings can only be 'zstd', 'br' and 'gzip'", encoding))
+			continue
+		}
+		Compress.Encodings = append(Compress.Encodings, encoding)
 	}
 
-	postgresDatabase := os.Getenv("POSTGRES_DB")
-	if postgresDatabase == "" {
-		errs = errors.Join(errs, errors.New("ERROR: the environment variable 'POSTGRES_DB' is not found"))
+	CacheControl.Default = cfg.Server.CacheControl
+	for pattern, value := range cfg.Server.CacheControlRoutes {
+		CacheControl.Routes[pattern] = value
 	}
 
-	postgresHost := os.Getenv("POSTGRES_HOST")
-	if postgresHost == "" {
-		errs = errors.Join(errs, errors.New("ERROR: the environment variable 'POSTGRES_HOST' is not found"))
+	TrustedProxies = nil
+	for _, proxy := range cfg.Server.TrustedProxies {
+		prefix, err := config.ParsePrefix(proxy)
+		if err != nil {
+			errs = errors.Join(errs, fmt.Errorf("ERROR: %w in 'TRUSTED_PROXIES'", err))
+			continue
+		}
+		TrustedProxies = append(TrustedProxies, prefix)
 	}
 
-	postgresTimeoutString := os.Getenv("POSTGRES_TIMEOUT")
-	postgresTimeoutInt, err := strconv.Atoi(postgresTimeoutString)
-	if err != nil || postgresTimeoutInt <= 0 {
-		errs = errors.Join(errs, errors.New("ERROR: the environment variable 'POSTGRES_TIMEOUT' must be a positive number"))
+	CORS.Origins = cfg.CORS.AllowedOrigins
+	CORS.Methods = cfg.CORS.AllowedMethods
+	CORS.Headers = cfg.CORS.AllowedHeaders
+	CORS.Credentials = cfg.CORS.AllowCredentials
+	CORS.MaxAge = time.Duration(cfg.CORS.MaxAge)
+
+	ExpirationInterval = time.Duration(cfg.Subscribes.ExpirationInterval)
+	OverlapPolicy = cfg.Subscribes.OverlapPolicy
+	SubscribeCacheTTL = time.Duration(cfg.Subscribes.Cache.TTL)
+	IdempotencyTTL = time.Duration(cfg.Idempotency.TTL)
+	IdempotencyLockTimeout = time.Duration(cfg.Idempotency.LockTimeout)
+
+	OutboxPublishers = cfg.Outbox.Publishers
+	OutboxHTTPURL = cfg.Outbox.HTTPURL
+	OutboxNATSURL = cfg.Outbox.NATSURL
+	OutboxNATSSubjectPrefix = cfg.Outbox.NATSSubjectPrefix
+	OutboxRetention = time.Duration(cfg.Outbox.Retention)
+
+	if cfg.RateLimit.Default == "off" {
+		Limiter.Default = ratelimit.Limit{}
 	} else {
-		postgresTimeout = time.Duration(postgresTimeoutInt) * time.Second
+		limit, err := ratelimit.ParseLimit(cfg.RateLimit.Default)
+		if err != nil {
+			errs = errors.Join(errs, fmt.Errorf("ERROR: the environment variable 'RATE_LIMIT_DEFAULT': %w", err))
+		}
+		Limiter.Default = limit
+	}
+	for pattern, limitString := range cfg.RateLimit.Routes {
+		limit, err := ratelimit.ParseLimit(limitString)
+		if err != nil {
+			errs = errors.Join(errs, fmt.Errorf("ERROR: incorrect limit of the route '%s' in 'RATE_LIMIT_ROUTES': %w", pattern, err))
+			continue
+		}
+		Limiter.Routes[pattern] = limit
+	}
+	RateLimitStore = cfg.RateLimit.Store
+	if cfg.RateLimit.IP == "off" {
+		IPLimiter.Default = ratelimit.Limit{}
+	} else {
+		limit, err := ratelimit.ParseLimit(cfg.RateLimit.IP)
+		if err != nil {
+			errs = errors.Join(errs, fmt.Errorf("ERROR: the environment variable 'RATE_LIMIT_IP': %w", err))
+		}
+		IPLimiter.Default = limit
 	}
 
-	models.NotificationInternalError = os.Getenv("NOTIFICATION_INTERNAL_ERROR")
-	if models.NotificationInternalError == "" {
-		color.Yellow("WARN: the environment variable 'NOTIFICATION_INTERNAL_ERROR' is not found. " +
-			"This variable is optional, but you may want to send an extra notification when catching INTERNAL SERVER ERROR " +
-			"(e.g., 'Please notify the administrator.')")
+	Verifier.Secret = []byte(cfg.Auth.JWTSecret)
+	if cfg.Auth.JWKSFile != "" {
+		keys, err := auth.LoadJWKS(cfg.Auth.JWKSFile)
+		if err != nil {
+			errs = errors.Join(errs, fmt.Errorf("ERROR: failed to load 'JWT_JWKS_FILE': %w", err))
+		}
+		Verifier.Keys = keys
 	}
+	Verifier.Issuer = cfg.Auth.JWTIssuer
+	Verifier.Audience = cfg.Auth.JWTAudience
+	Verifier.Leeway = 30 * time.Second
 
-	if errs != nil {
-		return shutdownDuration, postgresTimeout, serverAddrs, errs
+	CertificateScopes = map[string][]string{}
+	for name, scopes := range cfg.Server.TLS.ClientScopes {
+		for _, scope := range strings.Split(scopes, ",") {
+			if scope = strings.TrimSpace(scope); !slices.Contains(models.APIKeyScopes, scope) {
+				errs = errors.Join(errs, fmt.Errorf("ERROR: unknown scope '%s' of the certificate '%s' in 'TLS_CLIENT_SCOPES'. The scopes can only be '%s'",
+					scope, name, strings.Join(models.APIKeyScopes, "', '")))
+				continue
+			}
+			CertificateScopes[name] = append(CertificateScopes[name], scope)
+		}
 	}
-	serverAddrs = os.Args[1]
-	DSN = fmt.Sprintf("host=%s port=5432 user=%s dbname=%s password=%s sslmode=disable",
-		postgresHost, postgresUser, postgresDatabase, postgresPassword)
-	return shutdownDuration, postgresTimeout, serverAddrs, nil
+
+	if cfg.Auth.PolicyFile != "" {
+		policy, err := auth.LoadPolicy(cfg.Auth.PolicyFile)
+		if err != nil {
+			errs = errors.Join(errs, fmt.Errorf("ERROR: failed to load 'RBAC_POLICY_FILE': %w", err))
+		} else {
+			Policy = policy
+		}
+	}
+
+	return errs
 }
@@ -0,0 +1,78 @@
+package rest
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/pabloeclair/rest-subscription/internal/sbscrb/models"
+	"github.com/pabloeclair/rest-subscription/internal/sbscrb/repositories"
+)
+
+const (
+	OverlapReject = "reject"
+	OverlapWarn   = "warn"
+	OverlapAllow  = "allow"
+)
+
+// OverlapPolicy decides what happens to a subscribe whose dates overlap
+// another subscribe of the same user and service.
+var OverlapPolicy = OverlapReject
+
+// findOverlap returns the error about the subscribe overlapping another one,
+// or nil if there is none or the policy allows overlaps.
+func findOverlap(repo repositories.SubscribeRepository, subscribe *models.Subscribe) (*repositories.OverlapError, error) {
+	if OverlapPolicy == OverlapAllow {
+		return nil, nil
+	}
+	conflicting, err := repo.FindOverlapping(subscribe)
+	if err != nil || conflicting == nil {
+		return nil, err
+	}
+	return &repositories.OverlapError{ConflictingID: conflicting.ID}, nil
+}
+
+// checkOverlap applies the overlap policy to the subscribe. It writes the
+// response and returns false if the subscribe must not be saved.
+func checkOverlap(w http.ResponseWriter, repo repositories.SubscribeRepository, subscribe *models.Subscribe) bool {
+	overlapErr, err := findOverlap(repo, subscribe)
+	if err != nil {
+		errDto := models.NewFullExceptionDto(
+			http.StatusInternalServerError,
+			"Failed to check the overlapping subscribes",
+			err.Error(),
+		)
+		errDto.Write(w)
+		return false
+	}
+	if overlapErr == nil {
+		return true
+	}
+	if OverlapPolicy == OverlapWarn {
+		log.Printf("WARN: the subscribe of the user '%s' to '%s' overlaps the subscribe with id = %d",
+			subscribe.UserId, subscribe.ServiceName, overlapErr.ConflictingID)
+		w.Header().Add("Warning", "299 - "+strconv.Quote(overlapErr.Error()))
+		return true
+	}
+	writeOverlapError(w, overlapErr)
+	return false
+}
+
+// writeOverlapError writes 409 if err is about overlapping subscribes,
+// which may also come from the database constraint.
+func writeOverlapError(w http.ResponseWriter, err error) bool {
+	var overlapErr *repositories.OverlapError
+	if !errors.As(err, &overlapErr) {
+		return false
+	}
+	message := "The subscribe overlaps another subscribe of the user to the same service"
+	if overlapErr.ConflictingID != 0 {
+		message = fmt.Sprintf("The subscribe overlaps the subscribe with id = %d of the user to the same service",
+			overlapErr.ConflictingID)
+	}
+	errDto := models.NewFullExceptionDto(http.StatusConflict, message, "")
+	errDto.Write(w)
+	return true
+}
@@ -0,0 +1,172 @@
+package rest
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pabloeclair/rest-subscription/internal/sbscrb/models"
+	"github.com/pabloeclair/rest-subscription/internal/sbscrb/ratelimit"
+)
+
+var (
+	// Limiter is configured by Init, the store is set in main
+	Limiter = &ratelimit.Limiter{
+		Default: ratelimit.Limit{Requests: 600, Period: time.Minute},
+		Routes:  map[string]ratelimit.Limit{},
+	}
+	RateLimitStore = "memory"
+	// TrustedProxies are set by Configure, the client IP is read from
+	// the forwarded headers of these peers only
+	TrustedProxies []netip.Prefix
+	// IPLimiter is configured by Init, it keeps its buckets in memory so a
+	// flood does not reach the database
+	IPLimiter = &ratelimit.Limiter{
+		Store:   ratelimit.NewMemoryStore(),
+		Default: ratelimit.Limit{Requests: 1200, Period: time.Minute},
+	}
+)
+
+// clientIP is the peer of the connection, or the address forwarded by the
+// trusted proxies. The 'Forwarded' or else the 'X-Forwarded-For' hops are
+// read from the right while they are trusted, so a client cannot spoof its
+// address by sending the headers itself.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil || !trustedProxy(addr.Unmap()) {
+		return host
+	}
+
+	hops := forwardedHops(r.Header)
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop, ok := parseHop(hops[i])
+		if !ok {
+			break
+		}
+		addr = hop
+		if !trustedProxy(addr) {
+			break
+		}
+	}
+	return addr.String()
+}
+
+func trustedProxy(addr netip.Addr) bool {
+	for _, prefix := range TrustedProxies {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// forwardedHops are the 'for' nodes of the 'Forwarded' headers, or the
+// addresses of the 'X-Forwarded-For' headers, in the order of the hops.
+func forwardedHops(header http.Header) []string {
+	hops := []string{}
+	if values := header.Values("Forwarded"); len(values) > 0 {
+		for _, value := range values {
+			for _, element := range strings.Split(value, ",") {
+				node := ""
+				for _, pair := range strings.Split(element, ";") {
+					key, value, _ := strings.Cut(strings.TrimSpace(pair), "=")
+					if strings.EqualFold(key, "for") {
+						node = value
+					}
+				}
+				hops = append(hops, node)
+			}
+		}
+		return hops
+	}
+	for _, value := range header.Values("X-Forwarded-For") {
+		hops = append(hops, strings.Split(value, ",")...)
+	}
+	return hops
+}
+
+// parseHop parses an IP with an optional port, the obfuscated and the
+// 'unknown' nodes are not parsed.
+func parseHop(s string) (netip.Addr, bool) {
+	s = strings.Trim(strings.TrimSpace(s), `"`)
+	if addrPort, err := netip.ParseAddrPort(s); err == nil {
+		return addrPort.Addr().Unmap(), true
+	}
+	addr, err := netip.ParseAddr(strings.Trim(s, "[]"))
+	if err != nil {
+		return netip.Addr{}, false
+	}
+	return addr.Unmap(), true
+}
+
+// rateLimitClient identifies the caller by the API key, the JWT subject or
+// the client IP.
+func rateLimitClient(r *http.Request) string {
+	if p := principal(r); p.Subject != "" {
+		if p.APIKey {
+			return p.Subject
+		}
+		return "sub:" + p.Subject
+	}
+	return "ip:" + clientIP(r)
+}
+
+// RateLimitMiddleware limits the requests of a client per route pattern of
+// the mux and responds with 429 when the bucket is empty. If the store
+// fails, the request is let through.
+func RateLimitMiddleware(limiter *ratelimit.Limiter, mux *http.ServeMux, handler http.Handler) http.Handler {
+	return rateLimit(limiter, func(r *http.Request) (string, string) {
+		_, pattern := mux.Handler(r)
+		return rateLimitClient(r), pattern
+	}, handler)
+}
+
+// IPRateLimitMiddleware limits the requests of a client IP on all the
+// routes. It must wrap AuthMiddleware, so the requests with bad
+// credentials are limited before their API keys are looked up.
+func IPRateLimitMiddleware(limiter *ratelimit.Limiter, handler http.Handler) http.Handler {
+	return rateLimit(limiter, func(r *http.Request) (string, string) {
+		return "addr:" + clientIP(r), ""
+	}, handler)
+}
+
+func rateLimit(limiter *ratelimit.Limiter, key func(r *http.Request) (client, pattern string), handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		client, pattern := key(r)
+		res, limit, limited, err := limiter.Take(r.Context(), client, pattern)
+		if err != nil {
+			log.Println(models.RedString("ERROR: rate limit: ", err.Error()))
+			handler.ServeHTTP(w, r)
+			return
+		}
+		if !limited {
+			handler.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Set("RateLimit-Limit", strconv.Itoa(limit.Requests))
+		w.Header().Set("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+		w.Header().Set("RateLimit-Reset", strconv.Itoa(int(res.Reset.Seconds())))
+		w.Header().Set("RateLimit-Policy", fmt.Sprintf("%d;w=%d", limit.Requests, int(limit.Period.Seconds())))
+		if !res.Allowed {
+			w.Header().Set("Retry-After", strconv.Itoa(int(res.RetryAfter.Seconds())))
+			errDto := models.NewFullExceptionDto(
+				http.StatusTooManyRequests,
+				fmt.Sprintf("Too many requests. The limit is %s, please retry after %d seconds", limit, int(res.RetryAfter.Seconds())),
+				"",
+			)
+			errDto.Write(w)
+			return
+		}
+		handler.ServeHTTP(w, r)
+	})
+}
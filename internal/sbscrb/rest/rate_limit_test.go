@@ -0,0 +1,123 @@
+package rest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/pabloeclair/rest-subscription/internal/sbscrb/ratelimit"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRateLimitMiddleware(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/v1/subscribe", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+	limiter := &ratelimit.Limiter{
+		Store:   ratelimit.NewMemoryStore(),
+		Default: ratelimit.Limit{Requests: 100, Period: time.Minute},
+		Routes:  map[string]ratelimit.Limit{"GET /api/v1/subscribe": {Requests: 2, Period: time.Minute}},
+	}
+	handler := RateLimitMiddleware(limiter, mux, mux)
+	serve := func(remoteAddr string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, "/api/v1/subscribe", nil)
+		r.RemoteAddr = remoteAddr
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w
+	}
+
+	w := serve("10.0.0.1:5000")
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Equal(t, "2", w.Header().Get("RateLimit-Limit"))
+	assert.Equal(t, "1", w.Header().Get("RateLimit-Remaining"))
+
+	// the port of the client does not matter
+	w = serve("10.0.0.1:5001")
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Equal(t, "0", w.Header().Get("RateLimit-Remaining"))
+
+	w = serve("10.0.0.1:5002")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "30", w.Header().Get("Retry-After"))
+
+	w = serve("10.0.0.2:5000")
+	assert.Equal(t, http.StatusNoContent, w.Code)
+}
+
+func TestIPRateLimitMiddleware(t *testing.T) {
+	limiter := &ratelimit.Limiter{
+		Store:   ratelimit.NewMemoryStore(),
+		Default: ratelimit.Limit{Requests: 2, Period: time.Minute},
+	}
+	authenticated := 0
+	// the limiter runs before the authentication rejects the request
+	handler := IPRateLimitMiddleware(limiter, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authenticated++
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	serve := func(path, remoteAddr string) int {
+		r := httptest.NewRequest(http.MethodGet, path, nil)
+		r.RemoteAddr = remoteAddr
+		r.Header.Set("X-API-Key", "sk_unknown")
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w.Code
+	}
+
+	assert.Equal(t, http.StatusUnauthorized, serve("/api/v1/subscribe", "10.0.0.1:5000"))
+	// the limit is shared by the routes
+	assert.Equal(t, http.StatusUnauthorized, serve("/api/v1/subscribes/1", "10.0.0.1:5001"))
+	assert.Equal(t, http.StatusTooManyRequests, serve("/api/v1/users/user-1", "10.0.0.1:5002"))
+	assert.Equal(t, 2, authenticated)
+
+	assert.Equal(t, http.StatusUnauthorized, serve("/api/v1/subscribe", "10.0.0.2:5000"))
+}
+
+func TestClientIP(t *testing.T) {
+	defer func(proxies []netip.Prefix) { TrustedProxies = proxies }(TrustedProxies)
+	TrustedProxies = []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8"), netip.MustParsePrefix("fd00::/8")}
+
+	clientIP := func(remoteAddr string, header http.Header) string {
+		r := httptest.NewRequest(http.MethodGet, "/api/v1/subscribe", nil)
+		r.RemoteAddr = remoteAddr
+		r.Header = header
+		return clientIP(r)
+	}
+
+	t.Run("UntrustedPeer", func(t *testing.T) {
+		header := http.Header{"X-Forwarded-For": {"203.0.113.7"}}
+		assert.Equal(t, "198.51.100.1", clientIP("198.51.100.1:5000", header))
+	})
+
+	t.Run("XForwardedFor", func(t *testing.T) {
+		// the spoofed leftmost hop is behind the first untrusted one
+		header := http.Header{"X-Forwarded-For": {"1.2.3.4, 203.0.113.7", "10.0.0.2"}}
+		assert.Equal(t, "203.0.113.7", clientIP("10.0.0.1:5000", header))
+	})
+
+	t.Run("Forwarded", func(t *testing.T) {
+		header := http.Header{
+			"Forwarded":       {`for=1.2.3.4, for="[2001:db8::17]:4711";proto=https, for=10.0.0.2;by=10.0.0.1`},
+			"X-Forwarded-For": {"5.6.7.8"},
+		}
+		assert.Equal(t, "2001:db8::17", clientIP("[fd00::1]:5000", header))
+	})
+
+	t.Run("AllTrusted", func(t *testing.T) {
+		header := http.Header{"X-Forwarded-For": {"10.0.0.3, 10.0.0.2"}}
+		assert.Equal(t, "10.0.0.3", clientIP("10.0.0.1:5000", header))
+	})
+
+	t.Run("UnknownHop", func(t *testing.T) {
+		header := http.Header{"Forwarded": {"for=203.0.113.7, for=unknown, for=10.0.0.2"}}
+		assert.Equal(t, "10.0.0.2", clientIP("10.0.0.1:5000", header))
+	})
+
+	t.Run("NoHeaders", func(t *testing.T) {
+		assert.Equal(t, "10.0.0.1", clientIP("10.0.0.1:5000", http.Header{}))
+	})
+}
@@ -0,0 +1,153 @@
+package rest
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"runtime/debug"
+	"sync/atomic"
+
+	"github.com/pabloeclair/rest-subscription/internal/sbscrb/cache"
+	"github.com/pabloeclair/rest-subscription/internal/sbscrb/models"
+)
+
+// HTTPMetrics are the counters of the HTTP server.
+type HTTPMetrics struct {
+	Panics atomic.Int64
+	// SubscribeCache counts while the subscribe cache is enabled
+	SubscribeCache cache.Metrics
+}
+
+// Metrics are served by 'GET /api/v1/admin/metrics'.
+var Metrics = &HTTPMetrics{}
+
+func (m *HTTPMetrics) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Panics         int64          `json:"panics"`
+		SubscribeCache *cache.Metrics `json:"subscribe_cache"`
+	}{
+		Panics:         m.Panics.Load(),
+		SubscribeCache: &m.SubscribeCache,
+	})
+}
+
+func GetMetrics(w http.ResponseWriter, r *http.Request) {
+	b, err := json.Marshal(Metrics)
+	if err != nil {
+		errDto := models.NewFullExceptionDto(
+			http.StatusInternalServerError,
+			"Failed to marshal a response",
+			err.Error(),
+		)
+		errDto.Write(w)
+		return
+	}
+
+	w.Write(b)
+}
+
+const maxRequestIDLength = 128
+
+type requestIDKey struct{}
+
+// RequestIDMiddleware puts the id of the request into the context and the
+// 'X-Request-ID' response header. The id of the client is kept if it is
+// a short printable string.
+func RequestIDMiddleware(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get("X-Request-ID")
+		if !validRequestID(id) {
+			b := make([]byte, 16)
+			rand.Read(b)
+			id = hex.EncodeToString(b)
+		}
+		w.Header().Set("X-Request-ID", id)
+		handler.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), requestIDKey{}, id)))
+	})
+}
+
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for _, c := range id {
+		if c < '!' || c > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+// RequestID returns the id set by RequestIDMiddleware.
+func RequestID(r *http.Request) string {
+	id, _ := r.Context().Value(requestIDKey{}).(string)
+	return id
+}
+
+// handlerPanic carries a panic with its stack from the goroutine of
+// TimeoutMiddleware to RecoveryMiddleware.
+type handlerPanic struct {
+	value any
+	stack []byte
+}
+
+// RecoveryMiddleware catches the panics of the handlers, logs them with
+// the stack and responds 500 if nothing has been written yet.
+func RecoveryMiddleware(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rw := &recoveryWriter{ResponseWriter: w}
+		defer func() {
+			p := recover()
+			if p == nil {
+				return
+			}
+			if p == http.ErrAbortHandler {
+				// the handler has aborted the response on purpose
+				panic(p)
+			}
+			stack := debug.Stack()
+			if hp, ok := p.(*handlerPanic); ok {
+				p, stack = hp.value, hp.stack
+			}
+
+			Metrics.Panics.Add(1)
+			log.Println(models.RedString(fmt.Sprintf("PANIC: %s %s (request id %s): %v\n%s",
+				r.Method, r.URL.Path, RequestID(r), p, stack)))
+			if rw.written {
+				return
+			}
+			errDto := models.NewFullExceptionDto(
+				http.StatusInternalServerError,
+				fmt.Sprintf("Failed to handle the request with id = %s", RequestID(r)),
+				fmt.Sprint(p),
+			)
+			errDto.Write(w)
+		}()
+		handler.ServeHTTP(rw, r)
+	})
+}
+
+// recoveryWriter remembers if the response has been started.
+type recoveryWriter struct {
+	http.ResponseWriter
+	written bool
+}
+
+// Unwrap lets http.ResponseController reach the Flusher of the wrapped writer.
+func (rw *recoveryWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
+
+func (rw *recoveryWriter) WriteHeader(code int) {
+	rw.written = true
+	rw.ResponseWriter.WriteHeader(code)
+}
+
+func (rw *recoveryWriter) Write(b []byte) (int, error) {
+	rw.written = true
+	return rw.ResponseWriter.Write(b)
+}
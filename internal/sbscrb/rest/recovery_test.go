@@ -0,0 +1,70 @@
+package rest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/pabloeclair/rest-subscription/internal/sbscrb/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecoveryMiddleware(t *testing.T) {
+	models.NotificationInternalError = "Please notify the administrator"
+	t.Cleanup(func() { models.NotificationInternalError = "" })
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/v1/subscribes/{id}", func(w http.ResponseWriter, r *http.Request) {
+		var dto *models.SubscribeDto
+		w.Write([]byte(dto.ServiceName))
+	})
+	// the streaming export is not buffered by TimeoutMiddleware
+	mux.HandleFunc("GET /api/v1/subscribes/export", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		panic("after the header")
+	})
+	mux.HandleFunc("GET /api/v1/subscribe", func(w http.ResponseWriter, r *http.Request) {
+		panic(http.ErrAbortHandler)
+	})
+	handler := RequestIDMiddleware(LoggingMiddleware(RecoveryMiddleware(TimeoutMiddleware(&HandlerTimeouts{Default: time.Second}, mux, mux))))
+
+	serve := func(path, requestID string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, path, nil)
+		r.Header.Set("X-Request-ID", requestID)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w
+	}
+
+	t.Run("NilDereference", func(t *testing.T) {
+		panics := Metrics.Panics.Load()
+		w := serve("/api/v1/subscribes/1", "req-1")
+
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+		assert.Equal(t, "req-1", w.Header().Get("X-Request-ID"))
+		var errDto models.ExceptionDto
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &errDto))
+		assert.Equal(t, "Failed to handle the request with id = req-1. Please notify the administrator", errDto.ErrorMessage)
+		assert.Equal(t, panics+1, Metrics.Panics.Load())
+	})
+
+	t.Run("AfterHeader", func(t *testing.T) {
+		w := serve("/api/v1/subscribes/export", "")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Len(t, w.Header().Get("X-Request-ID"), 32)
+	})
+
+	t.Run("Abort", func(t *testing.T) {
+		assert.PanicsWithValue(t, http.ErrAbortHandler, func() {
+			serve("/api/v1/subscribe", "")
+		})
+	})
+}
+
+func TestRequestID(t *testing.T) {
+	assert.True(t, validRequestID("0f8fad5b-d9cb-469f-a165-70867728950e"))
+	assert.False(t, validRequestID("with space"))
+	assert.False(t, validRequestID(string(make([]byte, maxRequestIDLength+1))))
+}
@@ -0,0 +1,364 @@
+package rest
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/pabloeclair/rest-subscription/internal/sbscrb/models"
+	"github.com/pabloeclair/rest-subscription/internal/sbscrb/repositories"
+	"gorm.io/gorm"
+)
+
+// validateService resolves the service of the subscribe in the catalogue
+// and writes the error response if it fails.
+func validateService(w http.ResponseWriter, db *gorm.DB, subscribeDto *models.SubscribeDto) error {
+	err := subscribeDto.ValidateService(&repositories.GormServiceRepository{Db: db})
+	if errors.Is(err, models.ErrServiceNotFound) {
+		errDto := models.NewFullExceptionDto(
+			http.StatusBadRequest,
+			err.Error(),
+			"",
+		)
+		errDto.Write(w)
+	} else if err != nil {
+		errDto := models.NewFullExceptionDto(
+			http.StatusInternalServerError,
+			"Failed to get the service of the subscribe",
+			err.Error(),
+		)
+		errDto.Write(w)
+	}
+	return err
+}
+
+func CreateService(w http.ResponseWriter, r *http.Request) {
+	var (
+		//the service from request
+		serviceDto models.ServiceDto
+		//the error for response
+		errDto models.FullExceptionDto
+	)
+
+	// headers validation
+	if r.Header.Get("Content-Type") != "application/json; charset=utf-8" {
+		errDto = models.NewFullExceptionDto(
+			http.StatusBadRequest,
+			"The request body must be in JSON format",
+			"",
+		)
+		errDto.Write(w)
+		return
+	}
+
+	d := json.NewDecoder(r.Body)
+	if err := d.Decode(&serviceDto); err != nil {
+		writeBodyError(w, "Incorrect JSON body", err)
+		return
+	}
+
+	// body validation
+	if err := serviceDto.Validate(); err != nil {
+		errDto = models.NewFullExceptionDto(
+			http.StatusBadRequest,
+			err.Error(),
+			"",
+		)
+		errDto.Write(w)
+		return
+	}
+
+	db, err := openDB(w, r)
+	if err != nil {
+		return
+	}
+	repo := repositories.GormServiceRepository{Db: db}
+
+	// create operation
+	serviceDb := serviceDto.ToDatabase()
+	serviceDb.ID = 0
+	err = repo.Create(serviceDb)
+	if errors.Is(err, repositories.ErrServiceAliasTaken) {
+		errDto = models.NewFullExceptionDto(
+			http.StatusConflict,
+			"The name or one of the aliases belongs to another service",
+			err.Error(),
+		)
+		errDto.Write(w)
+		return
+	} else if err != nil {
+		errDto = models.NewFullExceptionDto(
+			http.StatusInternalServerError,
+			"Failed to create the service",
+			err.Error(),
+		)
+		errDto.Write(w)
+		return
+	}
+
+	// result
+	b, err := json.Marshal(serviceDb.ToDto())
+	if err != nil {
+		errDto = models.NewFullExceptionDto(
+			http.StatusInternalServerError,
+			"Failed to marshal a response",
+			err.Error(),
+		)
+		errDto.Write(w)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	w.Write(b)
+}
+
+func GetServices(w http.ResponseWriter, r *http.Request) {
+	var (
+		//the services for response
+		servicesDto = []*models.ServiceDto{}
+		//the error for response
+		errDto models.FullExceptionDto
+	)
+
+	db, err := openDB(w, r)
+	if err != nil {
+		return
+	}
+	repo := repositories.GormServiceRepository{Db: db}
+
+	// find operation
+	services, err := repo.FindAll()
+	if err != nil {
+		errDto = models.NewFullExceptionDto(
+			http.StatusInternalServerError,
+			"Failed to get the service list",
+			err.Error(),
+		)
+		errDto.Write(w)
+		return
+	}
+
+	// result
+	for _, v := range services {
+		servicesDto = append(servicesDto, v.ToDto())
+	}
+
+	b, err := json.Marshal(&servicesDto)
+	if err != nil {
+		errDto = models.NewFullExceptionDto(
+			http.StatusInternalServerError,
+			"Failed to marshal a response",
+			err.Error(),
+		)
+		errDto.Write(w)
+		return
+	}
+
+	w.Write(b)
+}
+
+func GetServiceById(w http.ResponseWriter, r *http.Request) {
+	var (
+		//the error for response
+		errDto models.FullExceptionDto
+	)
+
+	// query validate
+	idInt, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		errDto = models.NewFullExceptionDto(
+			http.StatusBadRequest,
+			"Incorrect id in the URL path. Please specify a positive number",
+			err.Error(),
+		)
+		errDto.Write(w)
+		return
+	}
+
+	db, err := openDB(w, r)
+	if err != nil {
+		return
+	}
+	repo := repositories.GormServiceRepository{Db: db}
+
+	// find operation
+	serviceDb, err := repo.FindByID(uint(idInt))
+	if errors.Is(err, models.ErrServiceNotFound) {
+		errDto = models.NewFullExceptionDto(
+			http.StatusNotFound,
+			fmt.Sprintf("The service with id = %d is not found", idInt),
+			err.Error(),
+		)
+		errDto.Write(w)
+		return
+	} else if err != nil {
+		errDto = models.NewFullExceptionDto(
+			http.StatusInternalServerError,
+			fmt.Sprintf("Failed to get the service with id = %d", idInt),
+			err.Error(),
+		)
+		errDto.Write(w)
+		return
+	}
+
+	// result
+	b, err := json.Marshal(serviceDb.ToDto())
+	if err != nil {
+		errDto = models.NewFullExceptionDto(
+			http.StatusInternalServerError,
+			"Failed to marshal a response",
+			err.Error(),
+		)
+		errDto.Write(w)
+		return
+	}
+
+	w.Write(b)
+}
+
+func UpdateService(w http.ResponseWriter, r *http.Request) {
+	var (
+		//the service from request
+		serviceDto models.ServiceDto
+		//the error for response
+		errDto models.FullExceptionDto
+	)
+
+	// headers validate
+	if r.Header.Get("Content-Type") != "application/json; charset=utf-8" {
+		errDto = models.NewFullExceptionDto(
+			http.StatusBadRequest,
+			"The request body must be in JSON format",
+			"",
+		)
+		errDto.Write(w)
+		return
+	}
+
+	// query validate
+	idInt, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		errDto = models.NewFullExceptionDto(
+			http.StatusBadRequest,
+			"Incorrect id in the URL path. Please specify a positive number",
+			err.Error(),
+		)
+		errDto.Write(w)
+		return
+	}
+
+	// body unmarshal
+	d := json.NewDecoder(r.Body)
+	if err := d.Decode(&serviceDto); err != nil {
+		writeBodyError(w, "Incorrect JSON body", err)
+		return
+	}
+
+	// fields validate
+	if err = serviceDto.Validate(); err != nil {
+		errDto = models.NewFullExceptionDto(
+			http.StatusBadRequest,
+			err.Error(),
+			"",
+		)
+		errDto.Write(w)
+		return
+	}
+
+	db, err := openDB(w, r)
+	if err != nil {
+		return
+	}
+	repo := repositories.GormServiceRepository{Db: db}
+
+	// update operation, the subscribes of the service are renamed
+	err = repo.Update(uint(idInt), serviceDto.ToDatabase())
+	if errors.Is(err, models.ErrServiceNotFound) {
+		errDto = models.NewFullExceptionDto(
+			http.StatusNotFound,
+			fmt.Sprintf("The service with id = %d is not found", idInt),
+			"",
+		)
+		errDto.Write(w)
+		return
+	} else if errors.Is(err, repositories.ErrServiceAliasTaken) {
+		errDto = models.NewFullExceptionDto(
+			http.StatusConflict,
+			"The name or one of the aliases belongs to another service",
+			err.Error(),
+		)
+		errDto.Write(w)
+		return
+	} else if err != nil {
+		errDto = models.NewFullExceptionDto(
+			http.StatusInternalServerError,
+			fmt.Sprintf("Failed to update the service with id = %d", idInt),
+			err.Error(),
+		)
+		errDto.Write(w)
+		return
+	}
+
+	// result
+	w.WriteHeader(http.StatusNoContent)
+	w.Header().Del("Content-Type")
+}
+
+func DeleteService(w http.ResponseWriter, r *http.Request) {
+	var (
+		//the error for response
+		errDto models.FullExceptionDto
+	)
+
+	// query validate
+	idInt, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		errDto = models.NewFullExceptionDto(
+			http.StatusBadRequest,
+			"Incorrect id in the URL path. Please specify a positive number",
+			err.Error(),
+		)
+		errDto.Write(w)
+		return
+	}
+
+	db, err := openDB(w, r)
+	if err != nil {
+		return
+	}
+	repo := repositories.GormServiceRepository{Db: db}
+
+	// delete operation
+	err = repo.Delete(uint(idInt))
+	if errors.Is(err, models.ErrServiceNotFound) {
+		errDto = models.NewFullExceptionDto(
+			http.StatusNotFound,
+			fmt.Sprintf("The service with id = %d is not found", idInt),
+			"",
+		)
+		errDto.Write(w)
+		return
+	} else if errors.Is(err, repositories.ErrServiceInUse) {
+		errDto = models.NewFullExceptionDto(
+			http.StatusConflict,
+			fmt.Sprintf("The service with id = %d has subscribes and cannot be deleted", idInt),
+			err.Error(),
+		)
+		errDto.Write(w)
+		return
+	} else if err != nil {
+		errDto = models.NewFullExceptionDto(
+			http.StatusInternalServerError,
+			fmt.Sprintf("Failed to delete the service with id = %d", idInt),
+			err.Error(),
+		)
+		errDto.Write(w)
+		return
+	}
+
+	// result
+	w.WriteHeader(http.StatusNoContent)
+	w.Header().Del("Content-Type")
+}
@@ -0,0 +1,135 @@
+package rest
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"maps"
+	"net/http"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/pabloeclair/rest-subscription/internal/sbscrb/models"
+)
+
+type HandlerTimeouts struct {
+	// Default bounds a request in the handlers, 0 disables it
+	Default time.Duration
+	// Routes override the default by the route pattern
+	Routes map[string]time.Duration
+}
+
+// HandlerTimeout is configured by Configure.
+var HandlerTimeout = &HandlerTimeouts{
+	Default: 30 * time.Second,
+	Routes:  map[string]time.Duration{"POST /api/v1/subscribes/import": 5 * time.Minute},
+}
+
+func (t *HandlerTimeouts) For(pattern string) time.Duration {
+	if timeout, ok := t.Routes[pattern]; ok {
+		return timeout
+	}
+	return t.Default
+}
+
+// the streaming routes write the response while reading the database and
+// are bounded only by the write timeout of the server
+var timeoutExemptRoutes = map[string]bool{
+	"GET /api/v1/subscribes/export": true,
+}
+
+// TimeoutMiddleware cancels the context of the request after the timeout
+// and responds 503 if the handler has not finished by then. The response
+// of the handler is buffered until it finishes, like in http.TimeoutHandler.
+func TimeoutMiddleware(timeouts *HandlerTimeouts, mux *http.ServeMux, handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, pattern := mux.Handler(r)
+		timeout := timeouts.For(pattern)
+		if timeout <= 0 || timeoutExemptRoutes[pattern] {
+			handler.ServeHTTP(w, r)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+		r = r.WithContext(ctx)
+
+		tw := &timeoutWriter{header: w.Header().Clone(), code: http.StatusOK}
+		done := make(chan struct{})
+		panicked := make(chan any, 1)
+		go func() {
+			defer func() {
+				if p := recover(); p != nil {
+					if _, ok := p.(*handlerPanic); !ok && p != http.ErrAbortHandler {
+						p = &handlerPanic{value: p, stack: debug.Stack()}
+					}
+					panicked <- p
+				}
+			}()
+			handler.ServeHTTP(tw, r)
+			close(done)
+		}()
+
+		select {
+		case p := <-panicked:
+			panic(p)
+		case <-done:
+			tw.mu.Lock()
+			defer tw.mu.Unlock()
+			dst := w.Header()
+			clear(dst)
+			maps.Copy(dst, tw.header)
+			w.WriteHeader(tw.code)
+			w.Write(tw.buf.Bytes())
+		case <-ctx.Done():
+			tw.mu.Lock()
+			defer tw.mu.Unlock()
+			tw.timedOut = true
+			if !errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				// the client has gone
+				return
+			}
+			errDto := models.NewFullExceptionDto(
+				http.StatusServiceUnavailable,
+				"The request has timed out",
+				"the handler has not finished in "+timeout.String(),
+			)
+			errDto.Write(w)
+		}
+	})
+}
+
+// timeoutWriter buffers the response until the handler finishes and
+// discards it after the timeout.
+type timeoutWriter struct {
+	mu          sync.Mutex
+	header      http.Header
+	buf         bytes.Buffer
+	code        int
+	wroteHeader bool
+	timedOut    bool
+}
+
+func (tw *timeoutWriter) Header() http.Header {
+	return tw.header
+}
+
+func (tw *timeoutWriter) WriteHeader(code int) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.timedOut || tw.wroteHeader {
+		return
+	}
+	tw.code, tw.wroteHeader = code, true
+}
+
+func (tw *timeoutWriter) Write(b []byte) (int, error) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.timedOut {
+		return 0, http.ErrHandlerTimeout
+	}
+	tw.wroteHeader = true
+	return tw.buf.Write(b)
+}
@@ -0,0 +1,80 @@
+package rest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTimeoutMiddleware(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/v1/subscribe", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Del("Content-Type")
+		w.Header().Set("X-Total-Count", "1")
+		w.WriteHeader(http.StatusAccepted)
+		w.Write([]byte(`[]`))
+	})
+	mux.HandleFunc("GET /api/v1/subscribes/{id}", func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+		w.Write([]byte(`{}`))
+	})
+	mux.HandleFunc("GET /api/v1/subscribes/export", func(w http.ResponseWriter, r *http.Request) {
+		_, hasDeadline := r.Context().Deadline()
+		assert.False(t, hasDeadline)
+		w.WriteHeader(http.StatusNoContent)
+	})
+	mux.HandleFunc("POST /api/v1/subscribes/import", func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(40 * time.Millisecond)
+		w.WriteHeader(http.StatusOK)
+	})
+	mux.HandleFunc("DELETE /api/v1/subscribes/{id}", func(w http.ResponseWriter, r *http.Request) {
+		panic("failed")
+	})
+	handler := TimeoutMiddleware(&HandlerTimeouts{
+		Default: 20 * time.Millisecond,
+		Routes:  map[string]time.Duration{"POST /api/v1/subscribes/import": time.Second},
+	}, mux, mux)
+
+	serve := func(method, path string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, path, nil)
+		w := httptest.NewRecorder()
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		handler.ServeHTTP(w, r)
+		return w
+	}
+
+	t.Run("InTime", func(t *testing.T) {
+		w := serve(http.MethodGet, "/api/v1/subscribe")
+		assert.Equal(t, http.StatusAccepted, w.Code)
+		assert.Equal(t, "[]", w.Body.String())
+		assert.Equal(t, "1", w.Header().Get("X-Total-Count"))
+		assert.Empty(t, w.Header().Get("Content-Type"))
+	})
+
+	t.Run("TimedOut", func(t *testing.T) {
+		w := serve(http.MethodGet, "/api/v1/subscribes/1")
+		assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+		assert.Contains(t, w.Body.String(), "The request has timed out")
+	})
+
+	t.Run("RouteTimeout", func(t *testing.T) {
+		assert.Equal(t, http.StatusOK, serve(http.MethodPost, "/api/v1/subscribes/import").Code)
+	})
+
+	t.Run("Exempt", func(t *testing.T) {
+		assert.Equal(t, http.StatusNoContent, serve(http.MethodGet, "/api/v1/subscribes/export").Code)
+	})
+
+	t.Run("Panic", func(t *testing.T) {
+		defer func() {
+			hp, ok := recover().(*handlerPanic)
+			assert.True(t, ok)
+			assert.Equal(t, "failed", hp.value)
+			assert.Contains(t, string(hp.stack), "TestTimeoutMiddleware")
+		}()
+		serve(http.MethodDelete, "/api/v1/subscribes/1")
+	})
+}
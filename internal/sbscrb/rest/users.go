@@ -0,0 +1,243 @@
+package rest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/pabloeclair/rest-subscription/internal/sbscrb/models"
+	"github.com/pabloeclair/rest-subscription/internal/sbscrb/reports"
+)
+
+// the longest period of the spending report
+const maxSpendingMonths = 120
+
+func GetUserSpending(w http.ResponseWriter, r *http.Request) {
+	var (
+		//the error for response
+		errDto models.FullExceptionDto
+	)
+
+	// query validate, the last 12 months by default
+	userId := r.PathValue("user_id")
+	if !authorize(w, r, userId) {
+		return
+	}
+	queryParams := r.URL.Query()
+	to := reports.MonthOf(time.Now())
+	from := to.AddMonths(-11)
+	var err error
+	if v := queryParams.Get("to"); v != "" {
+		if to, err = reports.ParseMonth(v); err != nil {
+			errDto = models.NewFullExceptionDto(
+				http.StatusBadRequest,
+				"Incorrect the 'to' parameter: "+err.Error(),
+				"",
+			)
+			errDto.Write(w)
+			return
+		}
+		if queryParams.Get("from") == "" {
+			from = to.AddMonths(-11)
+		}
+	}
+	if v := queryParams.Get("from"); v != "" {
+		if from, err = reports.ParseMonth(v); err != nil {
+			errDto = models.NewFullExceptionDto(
+				http.StatusBadRequest,
+				"Incorrect the 'from' parameter: "+err.Error(),
+				"",
+			)
+			errDto.Write(w)
+			return
+		}
+	}
+	if to.Before(from) || from.MonthsUntil(to) > maxSpendingMonths {
+		errDto = models.NewFullExceptionDto(
+			http.StatusBadRequest,
+			fmt.Sprintf("The 'from' month must not be after the 'to' month and the period must not exceed %d months", maxSpendingMonths),
+			"",
+		)
+		errDto.Write(w)
+		return
+	}
+
+	repo, err := connectToDB(w, r)
+	if err != nil {
+		return
+	}
+
+	// find operation
+	subscribes, err := repo.FindByUserId(userId)
+	if err != nil {
+		errDto = models.NewFullExceptionDto(
+			http.StatusInternalServerError,
+			fmt.Sprintf("Failed to get the subscribes of the user '%s'", userId),
+			err.Error(),
+		)
+		errDto.Write(w)
+		return
+	}
+
+	// result
+	b, err := json.Marshal(reports.Spending(userId, subscribes, from, to))
+	if err != nil {
+		errDto = models.NewFullExceptionDto(
+			http.StatusInternalServerError,
+			"Failed to marshal a response",
+			err.Error(),
+		)
+		errDto.Write(w)
+		return
+	}
+
+	w.Write(b)
+}
+
+func GetUser(w http.ResponseWriter, r *http.Request) {
+	var (
+		//the error for response
+		errDto models.FullExceptionDto
+	)
+
+	userId := r.PathValue("user_id")
+	if !authorize(w, r, userId) {
+		return
+	}
+	repo, err := connectToDB(w, r)
+	if err != nil {
+		return
+	}
+
+	// summary operation
+	summary, err := repo.SummaryByUserId(userId, time.Now())
+	if err != nil {
+		errDto = models.NewFullExceptionDto(
+			http.StatusInternalServerError,
+			fmt.Sprintf("Failed to get the summary of the user '%s'", userId),
+			err.Error(),
+		)
+		errDto.Write(w)
+		return
+	}
+	if summary.Subscribes == 0 {
+		errDto = models.NewFullExceptionDto(
+			http.StatusNotFound,
+			fmt.Sprintf("The user '%s' has no subscribes", userId),
+			"",
+		)
+		errDto.Write(w)
+		return
+	}
+
+	// result
+	b, err := json.Marshal(summary)
+	if err != nil {
+		errDto = models.NewFullExceptionDto(
+			http.StatusInternalServerError,
+			"Failed to marshal a response",
+			err.Error(),
+		)
+		errDto.Write(w)
+		return
+	}
+
+	w.Write(b)
+}
+
+func GetUserSubscribes(w http.ResponseWriter, r *http.Request) {
+	var (
+		//the subscribes for response
+		subscribesDto = []*models.SubscribeDto{}
+		//the error for response
+		errDto models.FullExceptionDto
+	)
+
+	userId := r.PathValue("user_id")
+	if !authorize(w, r, userId) {
+		return
+	}
+	repo, err := connectToDB(w, r)
+	if err != nil {
+		return
+	}
+
+	// find operation
+	subscribes, err := repo.FindByUserId(userId)
+	if err != nil {
+		errDto = models.NewFullExceptionDto(
+			http.StatusInternalServerError,
+			fmt.Sprintf("Failed to get the subscribes of the user '%s'", userId),
+			err.Error(),
+		)
+		errDto.Write(w)
+		return
+	}
+
+	// result
+	for _, v := range subscribes {
+		subscribesDto = append(subscribesDto, v.ToDto())
+	}
+
+	b, err := json.Marshal(&subscribesDto)
+	if err != nil {
+		errDto = models.NewFullExceptionDto(
+			http.StatusInternalServerError,
+			"Failed to marshal a response",
+			err.Error(),
+		)
+		errDto.Write(w)
+		return
+	}
+
+	w.Write(b)
+}
+
+func CreateUserSubscribe(w http.ResponseWriter, r *http.Request) {
+	create(w, r, r.PathValue("user_id"))
+}
+
+// DeleteUserSubscribes removes all subscribes of the user and responds
+// with their number.
+func DeleteUserSubscribes(w http.ResponseWriter, r *http.Request) {
+	var (
+		//the error for response
+		errDto models.FullExceptionDto
+	)
+
+	userId := r.PathValue("user_id")
+	if !authorize(w, r, userId) {
+		return
+	}
+	repo, err := connectToDB(w, r)
+	if err != nil {
+		return
+	}
+
+	// delete operation
+	deleted, err := repo.DeleteByUserId(userId)
+	if err != nil {
+		errDto = models.NewFullExceptionDto(
+			http.StatusInternalServerError,
+			fmt.Sprintf("Failed to delete the subscribes of the user '%s'", userId),
+			err.Error(),
+		)
+		errDto.Write(w)
+		return
+	}
+
+	// result
+	b, err := json.Marshal(map[string]any{"user_id": userId, "deleted": deleted})
+	if err != nil {
+		errDto = models.NewFullExceptionDto(
+			http.StatusInternalServerError,
+			"Failed to marshal a response",
+			err.Error(),
+		)
+		errDto.Write(w)
+		return
+	}
+
+	w.Write(b)
+}
@@ -0,0 +1,406 @@
+package rest
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/pabloeclair/rest-subscription/internal/sbscrb/models"
+	"github.com/pabloeclair/rest-subscription/internal/sbscrb/repositories"
+	"gorm.io/gorm"
+)
+
+func newWebhookSecret() string {
+	b := make([]byte, 32)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func CreateWebhook(w http.ResponseWriter, r *http.Request) {
+	var (
+		//the webhook from request
+		webhookDto models.WebhookDto
+		//the error for response
+		errDto models.FullExceptionDto
+	)
+
+	// headers validation
+	if r.Header.Get("Content-Type") != "application/json; charset=utf-8" {
+		errDto = models.NewFullExceptionDto(
+			http.StatusBadRequest,
+			"The request body must be in JSON format",
+			"",
+		)
+		errDto.Write(w)
+		return
+	}
+
+	d := json.NewDecoder(r.Body)
+	if err := d.Decode(&webhookDto); err != nil {
+		writeBodyError(w, "Incorrect JSON body", err)
+		return
+	}
+
+	// body validation
+	if err := webhookDto.Validate(); err != nil {
+		errDto = models.NewFullExceptionDto(
+			http.StatusBadRequest,
+			err.Error(),
+			"",
+		)
+		errDto.Write(w)
+		return
+	}
+	if webhookDto.Secret == "" {
+		webhookDto.Secret = newWebhookSecret()
+	}
+
+	db, err := openDB(w, r)
+	if err != nil {
+		return
+	}
+	repo := repositories.GormWebhookRepository{Db: db}
+
+	// create operation
+	webhookDb := webhookDto.ToDatabase()
+	webhookDb.ID = 0
+	if err = repo.Create(webhookDb); err != nil {
+		errDto = models.NewFullExceptionDto(
+			http.StatusInternalServerError,
+			"Failed to create the webhook",
+			err.Error(),
+		)
+		errDto.Write(w)
+		return
+	}
+
+	// result, the secret is returned only once
+	resp := webhookDb.ToDto()
+	resp.Secret = webhookDb.Secret
+	b, err := json.Marshal(resp)
+	if err != nil {
+		errDto = models.NewFullExceptionDto(
+			http.StatusInternalServerError,
+			"Failed to marshal a response",
+			err.Error(),
+		)
+		errDto.Write(w)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	w.Write(b)
+}
+
+func GetWebhooks(w http.ResponseWriter, r *http.Request) {
+	var (
+		//the webhooks for response
+		webhooksDto = []*models.WebhookDto{}
+		//the error for response
+		errDto models.FullExceptionDto
+	)
+
+	db, err := openDB(w, r)
+	if err != nil {
+		return
+	}
+	repo := repositories.GormWebhookRepository{Db: db}
+
+	// find operation
+	webhooks, err := repo.FindAll()
+	if err != nil {
+		errDto = models.NewFullExceptionDto(
+			http.StatusInternalServerError,
+			"Failed to get the webhook list",
+			err.Error(),
+		)
+		errDto.Write(w)
+		return
+	}
+
+	// result
+	for _, v := range webhooks {
+		webhooksDto = append(webhooksDto, v.ToDto())
+	}
+
+	b, err := json.Marshal(&webhooksDto)
+	if err != nil {
+		errDto = models.NewFullExceptionDto(
+			http.StatusInternalServerError,
+			"Failed to marshal a response",
+			err.Error(),
+		)
+		errDto.Write(w)
+		return
+	}
+
+	w.Write(b)
+}
+
+func GetWebhookById(w http.ResponseWriter, r *http.Request) {
+	var (
+		//the error for response
+		errDto models.FullExceptionDto
+	)
+
+	// query validate
+	idInt, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		errDto = models.NewFullExceptionDto(
+			http.StatusBadRequest,
+			"Incorrect id in the URL path. Please specify a positive number",
+			err.Error(),
+		)
+		errDto.Write(w)
+		return
+	}
+
+	db, err := openDB(w, r)
+	if err != nil {
+		return
+	}
+	repo := repositories.GormWebhookRepository{Db: db}
+
+	// find operation
+	webhookDb, err := repo.FindByID(uint(idInt))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		errDto = models.NewFullExceptionDto(
+			http.StatusNotFound,
+			fmt.Sprintf("The webhook with id = %d is not found", idInt),
+			err.Error(),
+		)
+		errDto.Write(w)
+		return
+	} else if err != nil {
+		errDto = models.NewFullExceptionDto(
+			http.StatusInternalServerError,
+			fmt.Sprintf("Failed to get the webhook with id = %d", idInt),
+			err.Error(),
+		)
+		errDto.Write(w)
+		return
+	}
+
+	// result
+	b, err := json.Marshal(webhookDb.ToDto())
+	if err != nil {
+		errDto = models.NewFullExceptionDto(
+			http.StatusInternalServerError,
+			"Failed to marshal a response",
+			err.Error(),
+		)
+		errDto.Write(w)
+		return
+	}
+
+	w.Write(b)
+}
+
+func UpdateWebhook(w http.ResponseWriter, r *http.Request) {
+	var (
+		//the webhook from request
+		webhookDto models.WebhookDto
+		//the error for response
+		errDto models.FullExceptionDto
+	)
+
+	// headers validate
+	if r.Header.Get("Content-Type") != "application/json; charset=utf-8" {
+		errDto = models.NewFullExceptionDto(
+			http.StatusBadRequest,
+			"The request body must be in JSON format",
+			"",
+		)
+		errDto.Write(w)
+		return
+	}
+
+	// query validate
+	idInt, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		errDto = models.NewFullExceptionDto(
+			http.StatusBadRequest,
+			"Incorrect id in the URL path. Please specify a positive number",
+			err.Error(),
+		)
+		errDto.Write(w)
+		return
+	}
+
+	// body unmarshal
+	d := json.NewDecoder(r.Body)
+	if err := d.Decode(&webhookDto); err != nil {
+		writeBodyError(w, "Incorrect JSON body", err)
+		return
+	}
+
+	// fields validate
+	if err = webhookDto.Validate(); err != nil {
+		errDto = models.NewFullExceptionDto(
+			http.StatusBadRequest,
+			err.Error(),
+			"",
+		)
+		errDto.Write(w)
+		return
+	}
+
+	db, err := openDB(w, r)
+	if err != nil {
+		return
+	}
+	repo := repositories.GormWebhookRepository{Db: db}
+
+	// update operation, an empty secret keeps the current one
+	err = repo.Update(uint(idInt), webhookDto.ToDatabase())
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		errDto = models.NewFullExceptionDto(
+			http.StatusNotFound,
+			fmt.Sprintf("The webhook with id = %d is not found", idInt),
+			"",
+		)
+		errDto.Write(w)
+		return
+	} else if err != nil {
+		errDto = models.NewFullExceptionDto(
+			http.StatusInternalServerError,
+			fmt.Sprintf("Failed to update the webhook with id = %d", idInt),
+			err.Error(),
+		)
+		errDto.Write(w)
+		return
+	}
+
+	// result
+	w.WriteHeader(http.StatusNoContent)
+	w.Header().Del("Content-Type")
+}
+
+func DeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	var (
+		//the error for response
+		errDto models.FullExceptionDto
+	)
+
+	// query validate
+	idInt, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		errDto = models.NewFullExceptionDto(
+			http.StatusBadRequest,
+			"Incorrect id in the URL path. Please specify a positive number",
+			err.Error(),
+		)
+		errDto.Write(w)
+		return
+	}
+
+	db, err := openDB(w, r)
+	if err != nil {
+		return
+	}
+	repo := repositories.GormWebhookRepository{Db: db}
+
+	// delete operation
+	err = repo.Delete(uint(idInt))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		errDto = models.NewFullExceptionDto(
+			http.StatusNotFound,
+			fmt.Sprintf("The webhook with id = %d is not found", idInt),
+			"",
+		)
+		errDto.Write(w)
+		return
+	} else if err != nil {
+		errDto = models.NewFullExceptionDto(
+			http.StatusInternalServerError,
+			fmt.Sprintf("Failed to delete the webhook with id = %d", idInt),
+			err.Error(),
+		)
+		errDto.Write(w)
+		return
+	}
+
+	// result
+	w.WriteHeader(http.StatusNoContent)
+	w.Header().Del("Content-Type")
+}
+
+func GetWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	var (
+		//the deliveries for response
+		deliveriesDto = []*models.WebhookDeliveryDto{}
+		//the error for response
+		errDto models.FullExceptionDto
+	)
+
+	// query validate
+	idInt, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		errDto = models.NewFullExceptionDto(
+			http.StatusBadRequest,
+			"Incorrect id in the URL path. Please specify a positive number",
+			err.Error(),
+		)
+		errDto.Write(w)
+		return
+	}
+
+	db, err := openDB(w, r)
+	if err != nil {
+		return
+	}
+	webhookRepo := repositories.GormWebhookRepository{Db: db}
+	deliveryRepo := repositories.GormWebhookDeliveryRepository{Db: db}
+
+	// find operation
+	if _, err = webhookRepo.FindByID(uint(idInt)); errors.Is(err, gorm.ErrRecordNotFound) {
+		errDto = models.NewFullExceptionDto(
+			http.StatusNotFound,
+			fmt.Sprintf("The webhook with id = %d is not found", idInt),
+			err.Error(),
+		)
+		errDto.Write(w)
+		return
+	} else if err != nil {
+		errDto = models.NewFullExceptionDto(
+			http.StatusInternalServerError,
+			fmt.Sprintf("Failed to get the webhook with id = %d", idInt),
+			err.Error(),
+		)
+		errDto.Write(w)
+		return
+	}
+
+	deliveries, err := deliveryRepo.FindByWebhookID(uint(idInt))
+	if err != nil {
+		errDto = models.NewFullExceptionDto(
+			http.StatusInternalServerError,
+			fmt.Sprintf("Failed to get the deliveries of the webhook with id = %d", idInt),
+			err.Error(),
+		)
+		errDto.Write(w)
+		return
+	}
+
+	// result
+	for _, v := range deliveries {
+		deliveriesDto = append(deliveriesDto, v.ToDto())
+	}
+
+	b, err := json.Marshal(&deliveriesDto)
+	if err != nil {
+		errDto = models.NewFullExceptionDto(
+			http.StatusInternalServerError,
+			"Failed to marshal a response",
+			err.Error(),
+		)
+		errDto.Write(w)
+		return
+	}
+
+	w.Write(b)
+}
@@ -0,0 +1,138 @@
+package scheduler
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"hash/fnv"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type Locker interface {
+	// TryLock acquires the lock of the job and claims the tick for it.
+	// ok is false if another instance holds the lock or has already run the tick.
+	TryLock(ctx context.Context, name string, tick time.Time) (lock Lock, ok bool, err error)
+}
+
+type Lock interface {
+	// Lost is closed when the lock can no longer be guaranteed.
+	Lost() <-chan struct{}
+	Unlock() error
+}
+
+// PgLocker uses Postgres session-level advisory locks. Each lock pins a
+// connection from the pool of Db for as long as it is held.
+type PgLocker struct {
+	Db *gorm.DB
+	// CheckInterval is how often the held connection is checked
+	CheckInterval time.Duration
+}
+
+func LockKey(name string) int64 {
+	h := fnv.New64a()
+	h.Write([]byte("scheduler:" + name))
+	return int64(h.Sum64())
+}
+
+func (l *PgLocker) TryLock(ctx context.Context, name string, tick time.Time) (Lock, bool, error) {
+	sqlDB, err := l.Db.DB()
+	if err != nil {
+		return nil, false, err
+	}
+	conn, err := sqlDB.Conn(ctx)
+	if err != nil {
+		return nil, false, err
+	}
+
+	key := LockKey(name)
+	var ok bool
+	if err := conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock($1)", key).Scan(&ok); err != nil {
+		conn.Close()
+		return nil, false, err
+	}
+	if !ok {
+		conn.Close()
+		return nil, false, nil
+	}
+
+	lock := &pgLock{
+		conn: conn,
+		key:  key,
+		lost: make(chan struct{}),
+		stop: make(chan struct{}),
+	}
+
+	// the tick may already be run by an instance with a faster clock
+	res, err := conn.ExecContext(ctx,
+		`INSERT INTO scheduled_jobs (name, last_tick, updated_at) VALUES ($1, $2, $3)
+		ON CONFLICT (name) DO UPDATE SET last_tick = EXCLUDED.last_tick, updated_at = EXCLUDED.updated_at
+		WHERE scheduled_jobs.last_tick < EXCLUDED.last_tick`,
+		name, tick, time.Now(),
+	)
+	if err != nil {
+		lock.Unlock()
+		return nil, false, err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return nil, false, lock.Unlock()
+	}
+
+	interval := l.CheckInterval
+	if interval <= 0 {
+		interval = 5 * time.Second
+	}
+	go lock.watch(interval)
+	return lock, true, nil
+}
+
+type pgLock struct {
+	conn     *sql.Conn
+	key      int64
+	lost     chan struct{}
+	stop     chan struct{}
+	lostOnce sync.Once
+	stopOnce sync.Once
+}
+
+func (l *pgLock) Lost() <-chan struct{} {
+	return l.lost
+}
+
+func (l *pgLock) watch(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-l.stop:
+			return
+		case <-ticker.C:
+			ctx, cancel := context.WithTimeout(context.Background(), interval)
+			_, err := l.conn.ExecContext(ctx, "SELECT 1")
+			cancel()
+			if err != nil {
+				l.lostOnce.Do(func() { close(l.lost) })
+				return
+			}
+		}
+	}
+}
+
+func (l *pgLock) Unlock() error {
+	var err error
+	l.stopOnce.Do(func() {
+		close(l.stop)
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_, err = l.conn.ExecContext(ctx, "SELECT pg_advisory_unlock($1)", l.key)
+		if err != nil {
+			// the session must not go back to the pool still holding the lock,
+			// ErrBadConn makes database/sql discard it
+			l.conn.Raw(func(any) error { return driver.ErrBadConn })
+		}
+		l.conn.Close()
+	})
+	return err
+}
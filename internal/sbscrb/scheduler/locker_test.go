@@ -0,0 +1,123 @@
+package scheduler
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func NewMock() (*gorm.DB, sqlmock.Sqlmock, error) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	dialector := postgres.New(postgres.Config{
+		Conn: mockDB,
+	})
+
+	db, err := gorm.Open(dialector, &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return db, mock, nil
+}
+
+func TestPgLockerTryLock(t *testing.T) {
+	tick := time.Date(2025, time.July, 26, 0, 0, 0, 0, time.UTC)
+	key := LockKey("job")
+
+	t.Run("Success", func(t *testing.T) {
+		db, mock, err := NewMock()
+		assert.NoError(t, err)
+		locker := &PgLocker{Db: db, CheckInterval: time.Hour}
+
+		mock.ExpectQuery(`SELECT pg_try_advisory_lock\(\$1\)`).
+			WithArgs(key).
+			WillReturnRows(sqlmock.NewRows([]string{"pg_try_advisory_lock"}).AddRow(true))
+		mock.ExpectExec(`INSERT INTO scheduled_jobs`).
+			WithArgs("job", tick, sqlmock.AnyArg()).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(`SELECT pg_advisory_unlock\(\$1\)`).
+			WithArgs(key).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		lock, ok, err := locker.TryLock(context.Background(), "job", tick)
+		assert.NoError(t, err)
+		assert.True(t, ok)
+		assert.NoError(t, lock.Unlock())
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Locked", func(t *testing.T) {
+		db, mock, err := NewMock()
+		assert.NoError(t, err)
+		locker := &PgLocker{Db: db}
+
+		mock.ExpectQuery(`SELECT pg_try_advisory_lock\(\$1\)`).
+			WithArgs(key).
+			WillReturnRows(sqlmock.NewRows([]string{"pg_try_advisory_lock"}).AddRow(false))
+
+		lock, ok, err := locker.TryLock(context.Background(), "job", tick)
+		assert.NoError(t, err)
+		assert.False(t, ok)
+		assert.Nil(t, lock)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("TickAlreadyRun", func(t *testing.T) {
+		db, mock, err := NewMock()
+		assert.NoError(t, err)
+		locker := &PgLocker{Db: db}
+
+		mock.ExpectQuery(`SELECT pg_try_advisory_lock\(\$1\)`).
+			WithArgs(key).
+			WillReturnRows(sqlmock.NewRows([]string{"pg_try_advisory_lock"}).AddRow(true))
+		mock.ExpectExec(`INSERT INTO scheduled_jobs`).
+			WithArgs("job", tick, sqlmock.AnyArg()).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec(`SELECT pg_advisory_unlock\(\$1\)`).
+			WithArgs(key).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		lock, ok, err := locker.TryLock(context.Background(), "job", tick)
+		assert.NoError(t, err)
+		assert.False(t, ok)
+		assert.Nil(t, lock)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("LockLost", func(t *testing.T) {
+		db, mock, err := NewMock()
+		assert.NoError(t, err)
+		locker := &PgLocker{Db: db, CheckInterval: 10 * time.Millisecond}
+
+		mock.ExpectQuery(`SELECT pg_try_advisory_lock\(\$1\)`).
+			WithArgs(key).
+			WillReturnRows(sqlmock.NewRows([]string{"pg_try_advisory_lock"}).AddRow(true))
+		mock.ExpectExec(`INSERT INTO scheduled_jobs`).
+			WithArgs("job", tick, sqlmock.AnyArg()).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(`SELECT 1`).
+			WillReturnError(context.DeadlineExceeded)
+
+		lock, ok, err := locker.TryLock(context.Background(), "job", tick)
+		assert.NoError(t, err)
+		assert.True(t, ok)
+		select {
+		case <-lock.Lost():
+		case <-time.After(time.Second):
+			t.Fatal("the lock loss is not detected")
+		}
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
@@ -0,0 +1,212 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pabloeclair/rest-subscription/internal/sbscrb/models"
+)
+
+type JobFunc func(ctx context.Context) error
+
+type JobMetrics struct {
+	Name         string     `json:"name"`
+	Spec         string     `json:"spec"`
+	Runs         int        `json:"runs"`
+	Failures     int        `json:"failures"`
+	Skipped      int        `json:"skipped"`
+	LockErrors   int        `json:"lock_errors"`
+	LocksLost    int        `json:"locks_lost"`
+	LastRunAt    *time.Time `json:"last_run_at,omitempty"`
+	LastDuration string     `json:"last_duration,omitempty"`
+	LastError    string     `json:"last_error,omitempty"`
+	NextRunAt    *time.Time `json:"next_run_at,omitempty"`
+}
+
+type job struct {
+	name     string
+	spec     string
+	schedule Schedule
+	run      JobFunc
+
+	mu      sync.Mutex
+	metrics JobMetrics
+}
+
+type Scheduler struct {
+	Locker Locker
+	// Now is used instead of time.Now when set
+	Now func() time.Time
+
+	mu      sync.Mutex
+	jobs    map[string]*job
+	started bool
+}
+
+func New(locker Locker) *Scheduler {
+	return &Scheduler{
+		Locker: locker,
+		jobs:   map[string]*job{},
+	}
+}
+
+func (s *Scheduler) Register(name, spec string, run JobFunc) error {
+	schedule, err := Parse(spec)
+	if err != nil {
+		return fmt.Errorf("job '%s': %w", name, err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.started {
+		return errors.New("the scheduler is already started")
+	}
+	if _, ok := s.jobs[name]; ok {
+		return fmt.Errorf("the job '%s' is already registered", name)
+	}
+	s.jobs[name] = &job{
+		name:     name,
+		spec:     spec,
+		schedule: schedule,
+		run:      run,
+		metrics:  JobMetrics{Name: name, Spec: spec},
+	}
+	return nil
+}
+
+// Run starts every registered job and blocks until ctx is done and
+// the running jobs have returned.
+func (s *Scheduler) Run(ctx context.Context) {
+	s.mu.Lock()
+	s.started = true
+	jobs := make([]*job, 0, len(s.jobs))
+	for _, j := range s.jobs {
+		jobs = append(jobs, j)
+	}
+	s.mu.Unlock()
+
+	var wg sync.WaitGroup
+	for _, j := range jobs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.loop(ctx, j)
+		}()
+	}
+	wg.Wait()
+	log.Println("Scheduler stopped")
+}
+
+func (s *Scheduler) Metrics() []JobMetrics {
+	s.mu.Lock()
+	jobs := make([]*job, 0, len(s.jobs))
+	for _, j := range s.jobs {
+		jobs = append(jobs, j)
+	}
+	s.mu.Unlock()
+
+	metrics := make([]JobMetrics, 0, len(jobs))
+	for _, j := range jobs {
+		j.mu.Lock()
+		metrics = append(metrics, j.metrics)
+		j.mu.Unlock()
+	}
+	sort.Slice(metrics, func(i, k int) bool { return metrics[i].Name < metrics[k].Name })
+	return metrics
+}
+
+func (s *Scheduler) now() time.Time {
+	if s.Now != nil {
+		return s.Now()
+	}
+	return time.Now()
+}
+
+func (s *Scheduler) loop(ctx context.Context, j *job) {
+	for {
+		next := j.schedule.Next(s.now())
+		if next.IsZero() {
+			log.Println(models.RedString("ERROR: scheduler: job '", j.name, "' has no next run"))
+			return
+		}
+		j.mu.Lock()
+		j.metrics.NextRunAt = &next
+		j.mu.Unlock()
+
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+		s.RunTick(ctx, j.name, next)
+	}
+}
+
+// RunTick runs the job for the tick if the lock is acquired.
+func (s *Scheduler) RunTick(ctx context.Context, name string, tick time.Time) {
+	s.mu.Lock()
+	j, ok := s.jobs[name]
+	s.mu.Unlock()
+	if !ok {
+		return
+	}
+
+	lock, ok, err := s.Locker.TryLock(ctx, j.name, tick)
+	if err != nil {
+		j.mu.Lock()
+		j.metrics.LockErrors++
+		j.mu.Unlock()
+		log.Println(models.RedString("ERROR: scheduler: job '", j.name, "': lock: ", err.Error()))
+		return
+	}
+	if !ok {
+		j.mu.Lock()
+		j.metrics.Skipped++
+		j.mu.Unlock()
+		return
+	}
+
+	jobCtx, cancel := context.WithCancel(ctx)
+	lockLost := make(chan struct{})
+	go func() {
+		select {
+		case <-lock.Lost():
+			close(lockLost)
+			cancel()
+		case <-jobCtx.Done():
+		}
+	}()
+
+	start := s.now()
+	err = j.run(jobCtx)
+	duration := s.now().Sub(start)
+	cancel()
+	if err := lock.Unlock(); err != nil {
+		log.Println(models.RedString("ERROR: scheduler: job '", j.name, "': unlock: ", err.Error()))
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.metrics.Runs++
+	j.metrics.LastRunAt = &start
+	j.metrics.LastDuration = duration.String()
+	j.metrics.LastError = ""
+	select {
+	case <-lockLost:
+		j.metrics.LocksLost++
+		log.Println(models.RedString("ERROR: scheduler: job '", j.name, "': the lock was lost"))
+	default:
+	}
+	if err != nil {
+		j.metrics.Failures++
+		j.metrics.LastError = err.Error()
+		log.Println(models.RedString("ERROR: scheduler: job '", j.name, "': ", err.Error()))
+	}
+}
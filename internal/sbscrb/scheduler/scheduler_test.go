@@ -0,0 +1,134 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeLock struct {
+	lost     chan struct{}
+	unlocked bool
+}
+
+func (l *fakeLock) Lost() <-chan struct{} { return l.lost }
+
+func (l *fakeLock) Unlock() error {
+	l.unlocked = true
+	return nil
+}
+
+type fakeLocker struct {
+	lock *fakeLock
+	ok   bool
+	err  error
+}
+
+func (l *fakeLocker) TryLock(ctx context.Context, name string, tick time.Time) (Lock, bool, error) {
+	if l.err != nil || !l.ok {
+		return nil, false, l.err
+	}
+	return l.lock, true, nil
+}
+
+func TestSchedulerRegister(t *testing.T) {
+	s := New(&fakeLocker{})
+	assert.NoError(t, s.Register("job", "@every 1m", func(ctx context.Context) error { return nil }))
+	assert.Error(t, s.Register("job", "@every 1m", func(ctx context.Context) error { return nil }))
+	assert.Error(t, s.Register("bad", "* *", func(ctx context.Context) error { return nil }))
+}
+
+func TestSchedulerRunTick(t *testing.T) {
+	tick := time.Date(2025, time.July, 26, 0, 0, 0, 0, time.UTC)
+
+	t.Run("Success", func(t *testing.T) {
+		lock := &fakeLock{lost: make(chan struct{})}
+		s := New(&fakeLocker{lock: lock, ok: true})
+		runs := 0
+		s.Register("job", "@every 1m", func(ctx context.Context) error {
+			runs++
+			return nil
+		})
+
+		s.RunTick(context.Background(), "job", tick)
+		assert.Equal(t, 1, runs)
+		assert.True(t, lock.unlocked)
+		m := s.Metrics()[0]
+		assert.Equal(t, 1, m.Runs)
+		assert.Equal(t, 0, m.Failures)
+	})
+
+	t.Run("Skipped", func(t *testing.T) {
+		s := New(&fakeLocker{ok: false})
+		runs := 0
+		s.Register("job", "@every 1m", func(ctx context.Context) error {
+			runs++
+			return nil
+		})
+
+		s.RunTick(context.Background(), "job", tick)
+		assert.Equal(t, 0, runs)
+		assert.Equal(t, 1, s.Metrics()[0].Skipped)
+	})
+
+	t.Run("LockError", func(t *testing.T) {
+		s := New(&fakeLocker{err: errors.New("connection refused")})
+		s.Register("job", "@every 1m", func(ctx context.Context) error { return nil })
+
+		s.RunTick(context.Background(), "job", tick)
+		assert.Equal(t, 1, s.Metrics()[0].LockErrors)
+	})
+
+	t.Run("Failure", func(t *testing.T) {
+		s := New(&fakeLocker{lock: &fakeLock{lost: make(chan struct{})}, ok: true})
+		s.Register("job", "@every 1m", func(ctx context.Context) error { return errors.New("boom") })
+
+		s.RunTick(context.Background(), "job", tick)
+		m := s.Metrics()[0]
+		assert.Equal(t, 1, m.Failures)
+		assert.Equal(t, "boom", m.LastError)
+	})
+
+	t.Run("LockLost", func(t *testing.T) {
+		lock := &fakeLock{lost: make(chan struct{})}
+		s := New(&fakeLocker{lock: lock, ok: true})
+		s.Register("job", "@every 1m", func(ctx context.Context) error {
+			close(lock.lost)
+			<-ctx.Done()
+			return ctx.Err()
+		})
+
+		s.RunTick(context.Background(), "job", tick)
+		m := s.Metrics()[0]
+		assert.Equal(t, 1, m.LocksLost)
+		assert.Equal(t, 1, m.Failures)
+	})
+}
+
+func TestSchedulerRun(t *testing.T) {
+	s := New(&fakeLocker{lock: &fakeLock{lost: make(chan struct{})}, ok: true})
+	runs := make(chan struct{}, 10)
+	s.Register("job", "@every 1s", func(ctx context.Context) error {
+		runs <- struct{}{}
+		return nil
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		s.Run(ctx)
+		close(done)
+	}()
+
+	select {
+	case <-runs:
+	case <-time.After(3 * time.Second):
+		t.Fatal("the job has not run")
+	}
+	cancel()
+	<-done
+	assert.Error(t, s.Register("late", "@every 1m", func(ctx context.Context) error { return nil }))
+}
@@ -0,0 +1,63 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse(t *testing.T) {
+	t.Run("Every", func(t *testing.T) {
+		s, err := Parse("@every 30s")
+		assert.NoError(t, err)
+		now := time.Date(2025, time.July, 26, 10, 0, 10, 0, time.UTC)
+		assert.Equal(t, time.Date(2025, time.July, 26, 10, 0, 30, 0, time.UTC), s.Next(now))
+	})
+
+	t.Run("Cron", func(t *testing.T) {
+		cases := []struct {
+			spec     string
+			now      time.Time
+			expected time.Time
+		}{
+			{
+				"*/15 * * * *",
+				time.Date(2025, time.July, 26, 10, 7, 0, 0, time.UTC),
+				time.Date(2025, time.July, 26, 10, 15, 0, 0, time.UTC),
+			},
+			{
+				"30 3 * * *",
+				time.Date(2025, time.July, 26, 4, 0, 0, 0, time.UTC),
+				time.Date(2025, time.July, 27, 3, 30, 0, 0, time.UTC),
+			},
+			{
+				"0 0 1 1,7 *",
+				time.Date(2025, time.July, 26, 0, 0, 0, 0, time.UTC),
+				time.Date(2026, time.January, 1, 0, 0, 0, 0, time.UTC),
+			},
+			{
+				"0 9 * * 1-5",
+				time.Date(2025, time.July, 26, 12, 0, 0, 0, time.UTC), // saturday
+				time.Date(2025, time.July, 28, 9, 0, 0, 0, time.UTC),
+			},
+			{
+				"@daily",
+				time.Date(2025, time.July, 26, 0, 0, 0, 0, time.UTC),
+				time.Date(2025, time.July, 27, 0, 0, 0, 0, time.UTC),
+			},
+		}
+		for _, c := range cases {
+			s, err := Parse(c.spec)
+			assert.NoError(t, err, c.spec)
+			assert.Equal(t, c.expected, s.Next(c.now), c.spec)
+		}
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		for _, spec := range []string{"", "* * * *", "60 * * * *", "*/0 * * * *", "5-1 * * * *", "@every 1ms", "@every x"} {
+			_, err := Parse(spec)
+			assert.Error(t, err, spec)
+		}
+	})
+}
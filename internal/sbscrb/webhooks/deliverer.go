@@ -0,0 +1,161 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/pabloeclair/rest-subscription/internal/sbscrb/models"
+	"github.com/pabloeclair/rest-subscription/internal/sbscrb/repositories"
+	"gorm.io/gorm"
+)
+
+const (
+	HeaderEvent     = "X-Webhook-Event"
+	HeaderEventID   = "X-Webhook-Id"
+	HeaderTimestamp = "X-Webhook-Timestamp"
+	HeaderSignature = "X-Webhook-Signature"
+)
+
+// Sign returns the value of the signature header: the hex encoded
+// HMAC-SHA256 of "<timestamp>.<body>" keyed with the webhook secret.
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+type Deliverer struct {
+	Webhooks   repositories.WebhookRepository
+	Deliveries repositories.WebhookDeliveryRepository
+	Client     *http.Client
+
+	// a delivery is dead-lettered after MaxAttempts failed attempts
+	MaxAttempts int
+	// the delay before the n-th retry is BaseDelay * 2^(n-1), at most MaxDelay
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	BatchSize int
+	Lease     time.Duration
+	Now       func() time.Time
+}
+
+func NewDeliverer(webhooks repositories.WebhookRepository, deliveries repositories.WebhookDeliveryRepository) *Deliverer {
+	return &Deliverer{
+		Webhooks:    webhooks,
+		Deliveries:  deliveries,
+		Client:      &http.Client{Timeout: 10 * time.Second},
+		MaxAttempts: 8,
+		BaseDelay:   10 * time.Second,
+		MaxDelay:    time.Hour,
+		BatchSize:   50,
+		Lease:       time.Minute,
+		Now:         time.Now,
+	}
+}
+
+// Job delivers every due delivery; it matches scheduler.JobFunc.
+func (d *Deliverer) Job(ctx context.Context) error {
+	deliveries, err := d.Deliveries.ClaimDue(d.Now(), d.Lease, d.BatchSize)
+	if err != nil {
+		return err
+	}
+
+	webhooks := map[uint]*models.Webhook{}
+	for _, delivery := range deliveries {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		wh, ok := webhooks[delivery.WebhookID]
+		if !ok {
+			wh, err = d.Webhooks.FindByID(delivery.WebhookID)
+			if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+				return err
+			}
+			webhooks[delivery.WebhookID] = wh
+		}
+
+		if wh == nil {
+			delivery.Status = models.DeliveryDead
+			delivery.LastError = "the webhook is deleted"
+		} else {
+			d.attempt(ctx, wh, delivery)
+		}
+
+		if err := d.Deliveries.Save(delivery); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *Deliverer) attempt(ctx context.Context, wh *models.Webhook, delivery *models.WebhookDelivery) {
+	now := d.Now()
+	delivery.Attempts++
+	delivery.LastStatusCode = 0
+	delivery.LastError = ""
+
+	statusCode, err := d.send(ctx, wh, delivery, now)
+	delivery.LastStatusCode = statusCode
+	if err == nil {
+		delivery.Status = models.DeliverySucceeded
+		return
+	}
+
+	delivery.LastError = err.Error()
+	if delivery.Attempts >= d.MaxAttempts {
+		delivery.Status = models.DeliveryDead
+		log.Println(models.RedString(fmt.Sprintf(
+			"ERROR: webhooks: delivery %d to webhook %d is dead after %d attempts: %s",
+			delivery.ID, wh.ID, delivery.Attempts, err.Error(),
+		)))
+		return
+	}
+	delivery.NextAttemptAt = now.Add(d.backoff(delivery.Attempts))
+}
+
+func (d *Deliverer) send(ctx context.Context, wh *models.Webhook, delivery *models.WebhookDelivery, now time.Time) (int, error) {
+	body := []byte(delivery.Payload)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, wh.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	timestamp := now.Unix()
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	req.Header.Set(HeaderEvent, delivery.EventType)
+	req.Header.Set(HeaderEventID, delivery.EventID)
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(HeaderSignature, Sign(wh.Secret, timestamp, body))
+
+	resp, err := d.Client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("unexpected response status %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
+
+func (d *Deliverer) backoff(attempts int) time.Duration {
+	delay := d.BaseDelay
+	for i := 1; i < attempts && delay < d.MaxDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, d.MaxDelay)
+}
@@ -0,0 +1,45 @@
+package webhooks
+
+import (
+	"encoding/json"
+
+	"github.com/pabloeclair/rest-subscription/internal/sbscrb/events"
+	"github.com/pabloeclair/rest-subscription/internal/sbscrb/models"
+	"github.com/pabloeclair/rest-subscription/internal/sbscrb/repositories"
+)
+
+// Dispatcher queues a delivery for every webhook interested in an event.
+type Dispatcher struct {
+	Webhooks   repositories.WebhookRepository
+	Deliveries repositories.WebhookDeliveryRepository
+}
+
+// Dispatch matches events.Handler. The event may be dispatched more than
+// once, Enqueue skips deliveries already queued for the same event.
+func (d *Dispatcher) Dispatch(e events.Event) error {
+	webhooks, err := d.Webhooks.FindAll()
+	if err != nil {
+		return err
+	}
+
+	payload, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	deliveries := []*models.WebhookDelivery{}
+	for _, wh := range webhooks {
+		if !wh.Accepts(string(e.Type)) {
+			continue
+		}
+		deliveries = append(deliveries, &models.WebhookDelivery{
+			WebhookID:     wh.ID,
+			EventID:       e.ID,
+			EventType:     string(e.Type),
+			Payload:       string(payload),
+			Status:        models.DeliveryPending,
+			NextAttemptAt: e.OccurredAt,
+		})
+	}
+	return d.Deliveries.Enqueue(deliveries)
+}
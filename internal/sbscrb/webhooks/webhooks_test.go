@@ -0,0 +1,185 @@
+package webhooks
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/pabloeclair/rest-subscription/internal/sbscrb/events"
+	"github.com/pabloeclair/rest-subscription/internal/sbscrb/models"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+type memWebhookRepository struct {
+	webhooks []*models.Webhook
+}
+
+func (r *memWebhookRepository) Create(webhook *models.Webhook) error {
+	webhook.ID = uint(len(r.webhooks) + 1)
+	r.webhooks = append(r.webhooks, webhook)
+	return nil
+}
+
+func (r *memWebhookRepository) FindAll() ([]*models.Webhook, error) {
+	return r.webhooks, nil
+}
+
+func (r *memWebhookRepository) FindByID(id uint) (*models.Webhook, error) {
+	for _, wh := range r.webhooks {
+		if wh.ID == id {
+			return wh, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (r *memWebhookRepository) Update(id uint, webhook *models.Webhook) error { return nil }
+
+func (r *memWebhookRepository) Delete(id uint) error { return nil }
+
+type memDeliveryRepository struct {
+	deliveries []*models.WebhookDelivery
+}
+
+func (r *memDeliveryRepository) Enqueue(deliveries []*models.WebhookDelivery) error {
+	for _, d := range deliveries {
+		d.ID = uint(len(r.deliveries) + 1)
+		r.deliveries = append(r.deliveries, d)
+	}
+	return nil
+}
+
+func (r *memDeliveryRepository) ClaimDue(now time.Time, lease time.Duration, limit int) ([]*models.WebhookDelivery, error) {
+	due := []*models.WebhookDelivery{}
+	for _, d := range r.deliveries {
+		if d.Status == models.DeliveryPending && !d.NextAttemptAt.After(now) && len(due) < limit {
+			copied := *d
+			d.NextAttemptAt = now.Add(lease)
+			due = append(due, &copied)
+		}
+	}
+	return due, nil
+}
+
+func (r *memDeliveryRepository) Save(delivery *models.WebhookDelivery) error {
+	r.deliveries[delivery.ID-1] = delivery
+	return nil
+}
+
+func (r *memDeliveryRepository) FindByWebhookID(webhookId uint) ([]*models.WebhookDelivery, error) {
+	return nil, nil
+}
+
+type receiver struct {
+	status   int
+	requests []*http.Request
+	bodies   [][]byte
+}
+
+func (rc *receiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	b, _ := io.ReadAll(r.Body)
+	rc.requests = append(rc.requests, r)
+	rc.bodies = append(rc.bodies, b)
+	w.WriteHeader(rc.status)
+}
+
+func TestDispatch(t *testing.T) {
+	webhookRepo := &memWebhookRepository{}
+	webhookRepo.Create(&models.Webhook{URL: "http://a", Secret: "a", EventTypes: "subscribe.created,subscribe.deleted"})
+	webhookRepo.Create(&models.Webhook{URL: "http://b", Secret: "b", EventTypes: "subscribe.expired"})
+	deliveryRepo := &memDeliveryRepository{}
+	dispatcher := &Dispatcher{Webhooks: webhookRepo, Deliveries: deliveryRepo}
+
+	e := events.New(events.SubscribeCreated, &models.SubscribeDto{ID: 1})
+	assert.NoError(t, dispatcher.Dispatch(e))
+
+	assert.Len(t, deliveryRepo.deliveries, 1)
+	delivery := deliveryRepo.deliveries[0]
+	assert.Equal(t, uint(1), delivery.WebhookID)
+	assert.Equal(t, e.ID, delivery.EventID)
+	assert.Equal(t, models.DeliveryPending, delivery.Status)
+}
+
+func TestDeliverer(t *testing.T) {
+	now := time.Date(2025, time.July, 26, 0, 0, 0, 0, time.UTC)
+
+	setup := func(status int) (*receiver, *memDeliveryRepository, *Deliverer, func()) {
+		rc := &receiver{status: status}
+		srv := httptest.NewServer(rc)
+
+		webhookRepo := &memWebhookRepository{}
+		webhookRepo.Create(&models.Webhook{URL: srv.URL, Secret: "secret", EventTypes: "subscribe.created"})
+		deliveryRepo := &memDeliveryRepository{}
+		dispatcher := &Dispatcher{Webhooks: webhookRepo, Deliveries: deliveryRepo}
+		e := events.New(events.SubscribeCreated, &models.SubscribeDto{ID: 1})
+		e.OccurredAt = now
+		dispatcher.Dispatch(e)
+
+		deliverer := NewDeliverer(webhookRepo, deliveryRepo)
+		deliverer.MaxAttempts = 3
+		deliverer.Now = func() time.Time { return now }
+		return rc, deliveryRepo, deliverer, srv.Close
+	}
+
+	t.Run("Success", func(t *testing.T) {
+		rc, deliveryRepo, deliverer, cleanup := setup(http.StatusOK)
+		defer cleanup()
+
+		assert.NoError(t, deliverer.Job(context.Background()))
+		assert.Len(t, rc.requests, 1)
+
+		req := rc.requests[0]
+		assert.Equal(t, "subscribe.created", req.Header.Get(HeaderEvent))
+		assert.Equal(t, deliveryRepo.deliveries[0].EventID, req.Header.Get(HeaderEventID))
+		timestamp, err := strconv.ParseInt(req.Header.Get(HeaderTimestamp), 10, 64)
+		assert.NoError(t, err)
+		assert.Equal(t, Sign("secret", timestamp, rc.bodies[0]), req.Header.Get(HeaderSignature))
+
+		delivery := deliveryRepo.deliveries[0]
+		assert.Equal(t, models.DeliverySucceeded, delivery.Status)
+		assert.Equal(t, 1, delivery.Attempts)
+		assert.Equal(t, http.StatusOK, delivery.LastStatusCode)
+	})
+
+	t.Run("RetryAndDeadLetter", func(t *testing.T) {
+		rc, deliveryRepo, deliverer, cleanup := setup(http.StatusInternalServerError)
+		defer cleanup()
+
+		assert.NoError(t, deliverer.Job(context.Background()))
+		delivery := deliveryRepo.deliveries[0]
+		assert.Equal(t, models.DeliveryPending, delivery.Status)
+		assert.Equal(t, now.Add(10*time.Second), delivery.NextAttemptAt)
+
+		// not due yet
+		assert.NoError(t, deliverer.Job(context.Background()))
+		assert.Len(t, rc.requests, 1)
+
+		now = now.Add(10 * time.Second)
+		assert.NoError(t, deliverer.Job(context.Background()))
+		delivery = deliveryRepo.deliveries[0]
+		assert.Equal(t, 2, delivery.Attempts)
+		assert.Equal(t, now.Add(20*time.Second), delivery.NextAttemptAt)
+
+		now = now.Add(20 * time.Second)
+		assert.NoError(t, deliverer.Job(context.Background()))
+		delivery = deliveryRepo.deliveries[0]
+		assert.Equal(t, 3, delivery.Attempts)
+		assert.Equal(t, models.DeliveryDead, delivery.Status)
+		assert.Equal(t, http.StatusInternalServerError, delivery.LastStatusCode)
+		assert.Len(t, rc.requests, 3)
+	})
+}
+
+func TestBackoff(t *testing.T) {
+	d := &Deliverer{BaseDelay: time.Second, MaxDelay: 5 * time.Second}
+	assert.Equal(t, time.Second, d.backoff(1))
+	assert.Equal(t, 2*time.Second, d.backoff(2))
+	assert.Equal(t, 4*time.Second, d.backoff(3))
+	assert.Equal(t, 5*time.Second, d.backoff(4))
+	assert.Equal(t, 5*time.Second, d.backoff(30))
+}
@@ -0,0 +1,61 @@
+package workers
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/pabloeclair/rest-subscription/internal/sbscrb/models"
+	"github.com/pabloeclair/rest-subscription/internal/sbscrb/repositories"
+)
+
+type ExpirationStatus struct {
+	Runs        int        `json:"runs"`
+	LastRunAt   *time.Time `json:"last_run_at,omitempty"`
+	LastExpired int        `json:"last_expired"`
+	LastError   string     `json:"last_error,omitempty"`
+}
+
+type ExpirationWorker struct {
+	Repo repositories.SubscribeRepository
+
+	mu     sync.RWMutex
+	status ExpirationStatus
+}
+
+func NewExpirationWorker(repo repositories.SubscribeRepository) *ExpirationWorker {
+	return &ExpirationWorker{Repo: repo}
+}
+
+// Job adapts the worker to scheduler.JobFunc.
+func (w *ExpirationWorker) Job(ctx context.Context) error {
+	_, err := w.RunOnce(time.Now())
+	return err
+}
+
+func (w *ExpirationWorker) RunOnce(now time.Time) (int, error) {
+	subscribes, err := w.Repo.MarkExpired(now)
+
+	w.mu.Lock()
+	w.status.Runs++
+	w.status.LastRunAt = &now
+	w.status.LastExpired = len(subscribes)
+	w.status.LastError = ""
+	if err != nil {
+		w.status.LastError = err.Error()
+	}
+	w.mu.Unlock()
+
+	if err != nil {
+		log.Println(models.RedString("ERROR: expiration worker: ", err.Error()))
+		return 0, err
+	}
+	return len(subscribes), nil
+}
+
+func (w *ExpirationWorker) Status() ExpirationStatus {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return w.status
+}
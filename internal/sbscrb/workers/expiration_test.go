@@ -0,0 +1,59 @@
+package workers
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/pabloeclair/rest-subscription/internal/sbscrb/models"
+	"github.com/pabloeclair/rest-subscription/internal/sbscrb/repositories"
+	"github.com/stretchr/testify/assert"
+)
+
+type stubRepository struct {
+	repositories.SubscribeRepository
+	expired []*models.Subscribe
+	err     error
+}
+
+func (r *stubRepository) MarkExpired(now time.Time) ([]*models.Subscribe, error) {
+	return r.expired, r.err
+}
+
+func TestExpirationWorkerRunOnce(t *testing.T) {
+	t.Run("Success", func(t *testing.T) {
+		repo := &stubRepository{expired: []*models.Subscribe{{ID: 1}, {ID: 2}}}
+		worker := NewExpirationWorker(repo)
+		now := time.Date(2025, time.September, 1, 0, 0, 0, 0, time.Local)
+
+		n, err := worker.RunOnce(now)
+		assert.NoError(t, err)
+		assert.Equal(t, 2, n)
+
+		status := worker.Status()
+		assert.Equal(t, 1, status.Runs)
+		assert.Equal(t, 2, status.LastExpired)
+		if assert.NotNil(t, status.LastRunAt) {
+			assert.Equal(t, now, *status.LastRunAt)
+		}
+		assert.Empty(t, status.LastError)
+	})
+
+	t.Run("Error", func(t *testing.T) {
+		repo := &stubRepository{err: errors.New("connection refused")}
+		worker := NewExpirationWorker(repo)
+
+		_, err := worker.RunOnce(time.Now())
+		assert.Error(t, err)
+		assert.Equal(t, "connection refused", worker.Status().LastError)
+	})
+
+	t.Run("NotRun", func(t *testing.T) {
+		worker := NewExpirationWorker(&stubRepository{})
+
+		body, err := json.Marshal(worker.Status())
+		assert.NoError(t, err)
+		assert.NotContains(t, string(body), "last_run_at")
+	})
+}